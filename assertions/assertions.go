@@ -70,6 +70,7 @@ var assertMap = map[string]AssertFunc{
 	"ShouldBeArray":                ShouldBeArray,
 	"ShouldBeMap":                  ShouldBeMap,
 	"ShouldMatchRegex":             ShouldMatchRegex,
+	"ShouldMatchScreenshot":        ShouldMatchScreenshot,
 }
 
 func Get(s string) (AssertFunc, bool) {
@@ -167,6 +168,44 @@ func ShouldMatchRegex(actual interface{}, expected ...interface{}) error {
 	return nil
 }
 
+// ShouldMatchScreenshot receives the comparison of a screenshot with its baseline, done by the web executor,
+// and ensures the screenshot matches.
+//
+// Example of testsuite file:
+//
+//	name: Assertions testsuite
+//	testcases:
+//	- name: test assertion
+//	  steps:
+//	  - type: web
+//	    action:
+//	      navigate:
+//	        url: https://www.ovh.com
+//	    matchScreenshot:
+//	      baseline: screenshots/home.png
+//	    assertions:
+//	    - result.screenshot ShouldMatchScreenshot
+func ShouldMatchScreenshot(actual interface{}, expected ...interface{}) error {
+	if err := need(0, expected); err != nil {
+		return err
+	}
+	comparison, err := cast.ToStringMapE(actual)
+	if err != nil {
+		return fmt.Errorf("expected: %v to be a screenshot comparison but was not", actual)
+	}
+	match, err := cast.ToBoolE(comparison["match"])
+	if err != nil {
+		return fmt.Errorf("expected: %v to be a screenshot comparison but was not", actual)
+	}
+	if match {
+		return nil
+	}
+	if message := cast.ToString(comparison["message"]); message != "" {
+		return fmt.Errorf("%s", message)
+	}
+	return fmt.Errorf("screenshot does not match baseline %v", comparison["baseline"])
+}
+
 // ShouldNotEqual receives exactly two parameters and does an inequality check.
 func ShouldNotEqual(actual interface{}, expected ...interface{}) error {
 	if err := ShouldEqual(actual, expected...); err == nil {
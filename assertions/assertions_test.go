@@ -2168,6 +2168,44 @@ func TestShouldMatchRegex(t *testing.T) {
 	}
 }
 
+func TestShouldMatchScreenshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		actual  interface{}
+		wantErr string
+	}{
+		{
+			name:   "match",
+			actual: map[string]interface{}{"match": true, "baseline": "home.png"},
+		},
+		{
+			name:    "mismatch",
+			actual:  map[string]interface{}{"match": false, "baseline": "home.png", "message": "screenshot differs from baseline home.png: 12 pixels (0.01%)"},
+			wantErr: "screenshot differs from baseline home.png: 12 pixels (0.01%)",
+		},
+		{
+			name:    "mismatch without message",
+			actual:  map[string]interface{}{"match": false, "baseline": "home.png"},
+			wantErr: "screenshot does not match baseline home.png",
+		},
+		{
+			name:    "not a comparison",
+			actual:  "home.png",
+			wantErr: "expected: home.png to be a screenshot comparison but was not",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ShouldMatchScreenshot(tt.actual)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestShouldJSONEqual(t *testing.T) {
 	type args struct {
 		actual   interface{}
@@ -25,30 +25,33 @@ var (
 	path []string
 	v    *venom.Venom
 
-	variables     []string
-	secrets       []string
-	format        string = "xml" // Set the default value for formatFlag
-	varFiles      []string
-	outputDir     string
-	libDir        string
-	htmlReport    bool
-	stopOnFailure bool
-	verbose       int = 0 // Set the default value for verboseFlag
-
-	variablesFlag     *[]string
-	formatFlag        *string
-	varFilesFlag      *[]string
-	outputDirFlag     *string
-	libDirFlag        *string
-	stopOnFailureFlag *bool
-	htmlReportFlag    *bool
-	verboseFlag       *int
+	variables         []string
+	secrets           []string
+	format            string = "xml" // Set the default value for formatFlag
+	varFiles          []string
+	outputDir         string
+	libDir            string
+	htmlReport        bool
+	stopOnFailure     bool
+	updateScreenshots bool
+	verbose           int = 0 // Set the default value for verboseFlag
+
+	variablesFlag         *[]string
+	formatFlag            *string
+	varFilesFlag          *[]string
+	outputDirFlag         *string
+	libDirFlag            *string
+	stopOnFailureFlag     *bool
+	htmlReportFlag        *bool
+	updateScreenshotsFlag *bool
+	verboseFlag           *int
 )
 
 func init() {
 	formatFlag = Cmd.Flags().String("format", "xml", "--format:json, tap, xml, yaml")
 	stopOnFailureFlag = Cmd.Flags().Bool("stop-on-failure", false, "Stop running Test Suite on first Test Case failure")
 	htmlReportFlag = Cmd.Flags().Bool("html-report", false, "Generate HTML Report")
+	updateScreenshotsFlag = Cmd.Flags().Bool("update-screenshots", false, "Rewrite the baselines of the screenshots compared by the web executor")
 	verboseFlag = Cmd.Flags().CountP("verbose", "v", "verbose. -v (INFO level in venom.log file), -vv to very verbose (DEBUG level) and -vvv to very verbose with CPU Profiling")
 	varFilesFlag = Cmd.Flags().StringSlice("var-from-file", []string{""}, "--var-from-file filename.yaml --var-from-file filename2.yaml: yaml, must contains a dictionary")
 	variablesFlag = Cmd.Flags().StringArray("var", nil, "--var cds='cds -f config.json' --var cds2='cds -f config.json'")
@@ -89,6 +92,10 @@ func initFromCommandArguments(f *pflag.Flag) {
 		if htmlReportFlag != nil {
 			htmlReport = *htmlReportFlag
 		}
+	case "update-screenshots":
+		if updateScreenshotsFlag != nil {
+			updateScreenshots = *updateScreenshotsFlag
+		}
 	case "output-dir":
 		if outputDirFlag != nil {
 			outputDir = *outputDirFlag
@@ -152,15 +159,16 @@ func initFromConfigFile() error {
 }
 
 type ConfigFileData struct {
-	Format         *string   `json:"format,omitempty" yaml:"format,omitempty"`
-	LibDir         *string   `json:"lib_dir,omitempty" yaml:"lib_dir,omitempty"`
-	OutputDir      *string   `json:"output_dir,omitempty" yaml:"output_dir,omitempty"`
-	StopOnFailure  *bool     `json:"stop_on_failure,omitempty" yaml:"stop_on_failure,omitempty"`
-	HtmlReport     *bool     `json:"html_report,omitempty" yaml:"html_report,omitempty"`
-	Variables      *[]string `json:"variables,omitempty" yaml:"variables,omitempty"`
-	Secrets        *[]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
-	VariablesFiles *[]string `json:"variables_files,omitempty" yaml:"variables_files,omitempty"`
-	Verbosity      *int      `json:"verbosity,omitempty" yaml:"verbosity,omitempty"`
+	Format            *string   `json:"format,omitempty" yaml:"format,omitempty"`
+	LibDir            *string   `json:"lib_dir,omitempty" yaml:"lib_dir,omitempty"`
+	OutputDir         *string   `json:"output_dir,omitempty" yaml:"output_dir,omitempty"`
+	StopOnFailure     *bool     `json:"stop_on_failure,omitempty" yaml:"stop_on_failure,omitempty"`
+	HtmlReport        *bool     `json:"html_report,omitempty" yaml:"html_report,omitempty"`
+	UpdateScreenshots *bool     `json:"update_screenshots,omitempty" yaml:"update_screenshots,omitempty"`
+	Variables         *[]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Secrets           *[]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	VariablesFiles    *[]string `json:"variables_files,omitempty" yaml:"variables_files,omitempty"`
+	Verbosity         *int      `json:"verbosity,omitempty" yaml:"verbosity,omitempty"`
 }
 
 // Configuration file overrides the environment variables.
@@ -191,6 +199,9 @@ func initFromReaderConfigFile(reader io.Reader) error {
 	if configFileData.HtmlReport != nil {
 		htmlReport = *configFileData.HtmlReport
 	}
+	if configFileData.UpdateScreenshots != nil {
+		updateScreenshots = *configFileData.UpdateScreenshots
+	}
 	if configFileData.Variables != nil {
 		for _, varFromFile := range *configFileData.Variables {
 			variables = mergeVariables(varFromFile, variables)
@@ -265,6 +276,13 @@ func initFromEnv(environ []string) ([]string, error) {
 			return nil, fmt.Errorf("invalid value for VENOM_HTML_REPORT")
 		}
 	}
+	if os.Getenv("VENOM_UPDATE_SCREENSHOTS") != "" {
+		var err error
+		updateScreenshots, err = strconv.ParseBool(os.Getenv("VENOM_UPDATE_SCREENSHOTS"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for VENOM_UPDATE_SCREENSHOTS")
+		}
+	}
 	if os.Getenv("VENOM_LIB_DIR") != "" {
 		libDir = os.Getenv("VENOM_LIB_DIR")
 	}
@@ -297,6 +315,7 @@ func displayArg(ctx context.Context) {
 	venom.Debug(ctx, "option outputDir=%v", outputDir)
 	venom.Debug(ctx, "option stopOnFailure=%v", stopOnFailure)
 	venom.Debug(ctx, "option htmlReport=%v", htmlReport)
+	venom.Debug(ctx, "option updateScreenshots=%v", updateScreenshots)
 	venom.Debug(ctx, "option varFiles=%v", strings.Join(varFiles, " "))
 	venom.Debug(ctx, "option verbose=%v", verbose)
 }
@@ -337,6 +356,7 @@ var Cmd = &cobra.Command{
 		v.OutputFormat = format
 		v.StopOnFailure = stopOnFailure
 		v.HtmlReport = htmlReport
+		v.UpdateScreenshots = updateScreenshots
 		v.Verbose = verbose
 
 		if err := v.InitLogger(); err != nil {
@@ -124,6 +124,30 @@ func (e *Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, e
 				return nil, err
 			}
 
+		case "bulkGet":
+			results[index], err = e.doBulkGet(ctx, action, cluster)
+			if err != nil {
+				return nil, err
+			}
+
+		case "lookupIn":
+			results[index], err = e.doLookupIn(ctx, action, cluster)
+			if err != nil {
+				return nil, err
+			}
+
+		case "mutateIn":
+			results[index], err = e.doMutateIn(ctx, action, cluster)
+			if err != nil {
+				return nil, err
+			}
+
+		case "query":
+			results[index], err = e.doQuery(ctx, action, cluster)
+			if err != nil {
+				return nil, err
+			}
+
 		default:
 			return nil, fmt.Errorf("action type %q not supported", actionType)
 		}
@@ -488,6 +512,415 @@ func (e *Executor) doGet(ctx context.Context,
 	return results, nil
 }
 
+// bulkGetAction represents a get of many documents in a single batch in couchbase
+type bulkGetAction struct {
+	baseAction
+
+	IDs []string `json:"ids" yaml:"ids" mapstructure:"ids"`
+}
+
+func (e *Executor) doBulkGet(ctx context.Context,
+	rawAction any,
+	cluster *gocb.Cluster,
+) (any, error) {
+	var action bulkGetAction
+
+	if err := mapstructure.Decode(rawAction, &action); err != nil {
+		return nil, fmt.Errorf("unable to decode bulkGet action: %w", err)
+	}
+
+	collection, err := e.getCollection(ctx, cluster,
+		action.Bucket, action.Collection, action.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]gocb.BulkOp, len(action.IDs))
+	for i, id := range action.IDs {
+		ops[i] = &gocb.GetOp{ID: id}
+	}
+
+	if err := collection.Do(ops, nil); err != nil {
+		return nil, fmt.Errorf("unable to perform bulkGet operation: %w", err)
+	}
+
+	results := map[string]map[string]any{}
+
+	for _, op := range ops {
+		getOp := op.(*gocb.GetOp)
+
+		var (
+			data  any
+			found = true
+		)
+
+		if errors.Is(getOp.Err, gocb.ErrDocumentNotFound) {
+			found = false
+		} else if getOp.Err != nil {
+			return nil, getOp.Err
+		} else if terr := getOp.Result.Content(&data); terr != nil {
+			return nil, fmt.Errorf("error while transcoding content of entry id=%q: %w", getOp.ID, terr)
+		}
+
+		results[getOp.ID] = map[string]any{
+			"found": found,
+			"data":  data,
+		}
+	}
+
+	return results, nil
+}
+
+// lookupInSpec represents a single sub-document lookup
+type lookupInSpec struct {
+	Type string `json:"type" yaml:"type" mapstructure:"type"`
+	Path string `json:"path" yaml:"path" mapstructure:"path"`
+}
+
+// lookupInAction represents a sub-document lookup in couchbase
+type lookupInAction struct {
+	baseAction
+
+	IDs   []string       `json:"ids"   yaml:"ids"   mapstructure:"ids"`
+	Specs []lookupInSpec `json:"specs" yaml:"specs" mapstructure:"specs"`
+}
+
+func (e *Executor) doLookupIn(ctx context.Context,
+	rawAction any,
+	cluster *gocb.Cluster,
+) (any, error) {
+	var action lookupInAction
+
+	if err := mapstructure.Decode(rawAction, &action); err != nil {
+		return nil, fmt.Errorf("unable to decode lookupIn action: %w", err)
+	}
+
+	collection, err := e.getCollection(ctx, cluster,
+		action.Bucket, action.Collection, action.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make([]gocb.LookupInSpec, len(action.Specs))
+	for i, spec := range action.Specs {
+		switch spec.Type {
+		case "get":
+			specs[i] = gocb.GetSpec(spec.Path, nil)
+		case "exists":
+			specs[i] = gocb.ExistsSpec(spec.Path, nil)
+		case "count":
+			specs[i] = gocb.CountSpec(spec.Path, nil)
+		default:
+			return nil, fmt.Errorf("invalid lookupIn spec type %q (valid values are %v)",
+				spec.Type, []string{"get", "exists", "count"})
+		}
+	}
+
+	results := map[string]map[string]any{}
+
+	for _, id := range action.IDs {
+		found := true
+		paths := make([]map[string]any, len(action.Specs))
+
+		docOut, err := collection.LookupIn(id, specs, nil)
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			found = false
+		} else if err != nil {
+			return nil, err
+		}
+
+		for i, spec := range action.Specs {
+			pathResult := map[string]any{
+				"path":   spec.Path,
+				"exists": false,
+			}
+
+			if found {
+				var data any
+
+				exists := docOut.Exists(uint(i))
+				if spec.Type == "get" || spec.Type == "count" {
+					if terr := docOut.ContentAt(uint(i), &data); terr != nil && !errors.Is(terr, gocb.ErrPathNotFound) {
+						return nil, fmt.Errorf("error while decoding path %q of entry id=%q: %w", spec.Path, id, terr)
+					}
+				}
+
+				pathResult["exists"] = exists
+				pathResult["data"] = data
+			}
+
+			paths[i] = pathResult
+		}
+
+		results[id] = map[string]any{
+			"found": found,
+			"paths": paths,
+		}
+	}
+
+	return results, nil
+}
+
+// mutateInSpec represents a single sub-document mutation
+type mutateInSpec struct {
+	Type       string `json:"type"                  yaml:"type"                  mapstructure:"type"`
+	Path       string `json:"path"                  yaml:"path"                  mapstructure:"path"`
+	Value      any    `json:"value,omitempty"       yaml:"value,omitempty"       mapstructure:"value"`
+	Delta      int64  `json:"delta,omitempty"       yaml:"delta,omitempty"       mapstructure:"delta"`
+	CreatePath bool   `json:"create_path,omitempty" yaml:"create_path,omitempty" mapstructure:"create_path"`
+}
+
+// mutateInAction represents a sub-document mutation in couchbase
+type mutateInAction struct {
+	baseAction
+
+	StoreSemantic  string         `json:"store_semantic,omitempty"  yaml:"store_semantic,omitempty"  mapstructure:"store_semantic"`
+	PreserveExpiry bool           `json:"preserve_expiry,omitempty" yaml:"preserve_expiry,omitempty" mapstructure:"preserve_expiry"`
+	Expiry         *float64       `json:"expiry,omitempty"          yaml:"expiry,omitempty"          mapstructure:"expiry"`
+	IDs            []string       `json:"ids"                       yaml:"ids"                       mapstructure:"ids"`
+	Specs          []mutateInSpec `json:"specs"                     yaml:"specs"                     mapstructure:"specs"`
+}
+
+func (e *Executor) doMutateIn(ctx context.Context,
+	rawAction any,
+	cluster *gocb.Cluster,
+) (any, error) {
+	var action mutateInAction
+
+	if err := mapstructure.Decode(rawAction, &action); err != nil {
+		return nil, fmt.Errorf("unable to decode mutateIn action: %w", err)
+	}
+
+	collection, err := e.getCollection(ctx, cluster,
+		action.Bucket, action.Collection, action.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &gocb.MutateInOptions{
+		PreserveExpiry: action.PreserveExpiry,
+	}
+
+	if expiry, ok := e.tryGetExpiry(action.Expiry); ok {
+		opts.Expiry = expiry
+		opts.PreserveExpiry = false
+	}
+
+	switch action.StoreSemantic {
+	case "", "replace":
+		opts.StoreSemantic = gocb.StoreSemanticsReplace
+	case "upsert":
+		opts.StoreSemantic = gocb.StoreSemanticsUpsert
+	case "insert":
+		opts.StoreSemantic = gocb.StoreSemanticsInsert
+	default:
+		return nil, fmt.Errorf("invalid mutateIn store semantic %q (valid values are %v)",
+			action.StoreSemantic, []string{"replace", "upsert", "insert"})
+	}
+
+	specs := make([]gocb.MutateInSpec, len(action.Specs))
+	for i, spec := range action.Specs {
+		specs[i], err = toMutateInSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := map[string]map[string]any{}
+
+	for _, id := range action.IDs {
+		mutated := true
+
+		docOut, err := collection.MutateIn(id, specs, opts)
+		if errors.Is(err, gocb.ErrDocumentNotFound) || errors.Is(err, gocb.ErrDocumentExists) {
+			mutated = false
+		} else if err != nil {
+			return nil, err
+		}
+
+		mutateResult := map[string]any{
+			"mutated": mutated,
+		}
+
+		if mutated {
+			counters := map[string]any{}
+
+			for i, spec := range action.Specs {
+				if spec.Type != "increment" && spec.Type != "decrement" {
+					continue
+				}
+
+				var value int64
+				if terr := docOut.ContentAt(uint(i), &value); terr != nil {
+					return nil, fmt.Errorf("error while decoding counter %q of entry id=%q: %w", spec.Path, id, terr)
+				}
+
+				counters[spec.Path] = value
+			}
+
+			if len(counters) > 0 {
+				mutateResult["counters"] = counters
+			}
+		}
+
+		results[id] = mutateResult
+	}
+
+	return results, nil
+}
+
+func toMutateInSpec(spec mutateInSpec) (gocb.MutateInSpec, error) {
+	switch spec.Type {
+	case "insert":
+		return gocb.InsertSpec(spec.Path, spec.Value,
+			&gocb.InsertSpecOptions{CreatePath: spec.CreatePath}), nil
+	case "upsert":
+		return gocb.UpsertSpec(spec.Path, spec.Value,
+			&gocb.UpsertSpecOptions{CreatePath: spec.CreatePath}), nil
+	case "replace":
+		return gocb.ReplaceSpec(spec.Path, spec.Value, nil), nil
+	case "remove":
+		return gocb.RemoveSpec(spec.Path, nil), nil
+	case "arrayAppend":
+		return gocb.ArrayAppendSpec(spec.Path, spec.Value,
+			&gocb.ArrayAppendSpecOptions{CreatePath: spec.CreatePath}), nil
+	case "arrayPrepend":
+		return gocb.ArrayPrependSpec(spec.Path, spec.Value,
+			&gocb.ArrayPrependSpecOptions{CreatePath: spec.CreatePath}), nil
+	case "arrayAddUnique":
+		return gocb.ArrayAddUniqueSpec(spec.Path, spec.Value,
+			&gocb.ArrayAddUniqueSpecOptions{CreatePath: spec.CreatePath}), nil
+	case "increment":
+		return gocb.IncrementSpec(spec.Path, spec.Delta,
+			&gocb.CounterSpecOptions{CreatePath: spec.CreatePath}), nil
+	case "decrement":
+		return gocb.DecrementSpec(spec.Path, spec.Delta,
+			&gocb.CounterSpecOptions{CreatePath: spec.CreatePath}), nil
+	}
+
+	return gocb.MutateInSpec{}, fmt.Errorf("invalid mutateIn spec type %q (valid values are %v)",
+		spec.Type, []string{"insert", "upsert", "replace", "remove",
+			"arrayAppend", "arrayPrepend", "arrayAddUnique", "increment", "decrement"})
+}
+
+// queryAction represents a SQL++ (N1QL) query in couchbase
+type queryAction struct {
+	baseAction
+
+	Statement            string         `json:"statement"                       yaml:"statement"                       mapstructure:"statement"`
+	NamedParameters      map[string]any `json:"named_parameters,omitempty"      yaml:"named_parameters,omitempty"      mapstructure:"named_parameters"`
+	PositionalParameters []any          `json:"positional_parameters,omitempty" yaml:"positional_parameters,omitempty" mapstructure:"positional_parameters"`
+	ScanConsistency      string         `json:"scan_consistency,omitempty"      yaml:"scan_consistency,omitempty"      mapstructure:"scan_consistency"`
+	Readonly             bool           `json:"readonly,omitempty"              yaml:"readonly,omitempty"              mapstructure:"readonly"`
+}
+
+func (e *Executor) doQuery(ctx context.Context,
+	rawAction any,
+	cluster *gocb.Cluster,
+) (any, error) {
+	var action queryAction
+
+	if err := mapstructure.Decode(rawAction, &action); err != nil {
+		return nil, fmt.Errorf("unable to decode query action: %w", err)
+	}
+
+	if action.Statement == "" {
+		return nil, errors.New("unable to perform query operation: missing 'statement'")
+	}
+
+	opts := &gocb.QueryOptions{
+		NamedParameters:      action.NamedParameters,
+		PositionalParameters: action.PositionalParameters,
+		Readonly:             action.Readonly,
+		Metrics:              true,
+		Context:              ctx,
+	}
+
+	switch action.ScanConsistency {
+	case "":
+	case "not_bounded":
+		opts.ScanConsistency = gocb.QueryScanConsistencyNotBounded
+	case "request_plus":
+		opts.ScanConsistency = gocb.QueryScanConsistencyRequestPlus
+	default:
+		return nil, fmt.Errorf("invalid query scan consistency %q (valid values are %v)",
+			action.ScanConsistency, []string{"not_bounded", "request_plus"})
+	}
+
+	var (
+		queryOut *gocb.QueryResult
+		err      error
+	)
+
+	// a query is scoped only when the action asks for it, otherwise
+	// the statement must use fully qualified keyspaces.
+	if action.Scope != "" {
+		bucket, berr := e.getBucket(ctx, cluster, action.Bucket)
+		if berr != nil {
+			return nil, berr
+		}
+
+		venom.Debug(ctx, "run query on scope %q from bucket %q", action.Scope, bucket.Name())
+
+		queryOut, err = bucket.Scope(action.Scope).Query(action.Statement, opts)
+	} else {
+		queryOut, err = cluster.Query(action.Statement, opts)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform query operation: %w", err)
+	}
+
+	defer queryOut.Close()
+
+	rows := []any{}
+
+	for queryOut.Next() {
+		var row any
+		if err := queryOut.Row(&row); err != nil {
+			return nil, fmt.Errorf("error while decoding query row: %w", err)
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := queryOut.Err(); err != nil {
+		return nil, fmt.Errorf("unable to perform query operation: %w", err)
+	}
+
+	metaData, err := queryOut.MetaData()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read query metadata: %w", err)
+	}
+
+	warnings := make([]map[string]any, len(metaData.Warnings))
+	for i, warning := range metaData.Warnings {
+		warnings[i] = map[string]any{
+			"code":    warning.Code,
+			"message": warning.Message,
+		}
+	}
+
+	metrics := metaData.Metrics
+
+	return map[string]any{
+		"rows":     rows,
+		"count":    len(rows),
+		"status":   string(metaData.Status),
+		"warnings": warnings,
+		"metrics": map[string]any{
+			"elapsed_time":   metrics.ElapsedTime.Seconds(),
+			"execution_time": metrics.ExecutionTime.Seconds(),
+			"result_count":   metrics.ResultCount,
+			"result_size":    metrics.ResultSize,
+			"mutation_count": metrics.MutationCount,
+			"sort_count":     metrics.SortCount,
+			"error_count":    metrics.ErrorCount,
+			"warning_count":  metrics.WarningCount,
+		},
+	}, nil
+}
+
 // upsertAction represents a upsert/insert or update in couchbase
 type upsertAction struct {
 	baseAction
@@ -0,0 +1,301 @@
+package dbdiff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/ovh/venom"
+)
+
+// Name of the executor.
+const Name = "dbdiff"
+
+const (
+	actionSnapshot = "snapshot"
+	actionDiff     = "diff"
+
+	defaultSnapshot = "default"
+)
+
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
+// New returns a new executor that can snapshot databases and diff them against their snapshot
+func New() venom.Executor {
+	return &Executor{}
+}
+
+// Executor is a venom executor that can snapshot the rows of SQL tables or mongo collections,
+// and list the rows inserted, updated and deleted since the snapshot.
+type Executor struct {
+	// Action is snapshot or diff
+	Action string `json:"action" yaml:"action"`
+	// Snapshot is the name of the snapshot. Default "default"
+	Snapshot string `json:"snapshot,omitempty" yaml:"snapshot,omitempty"`
+	// Driver and DSN of a SQL database, as in the sql executor
+	Driver string   `json:"driver,omitempty" yaml:"driver,omitempty"`
+	DSN    string   `json:"dsn,omitempty" yaml:"dsn,omitempty"`
+	Tables []string `json:"tables,omitempty" yaml:"tables,omitempty"`
+	// URI and Database of a mongo database, as in the mongo executor
+	URI         string   `json:"uri,omitempty" yaml:"uri,omitempty"`
+	Database    string   `json:"database,omitempty" yaml:"database,omitempty"`
+	Collections []string `json:"collections,omitempty" yaml:"collections,omitempty"`
+	// Keys are the columns identifying the rows, by table. Default id, or _id for mongo
+	Keys map[string][]string `json:"keys,omitempty" yaml:"keys,omitempty"`
+	// IgnoreColumns are not compared, such as the update dates
+	IgnoreColumns []string `json:"ignore_columns,omitempty" yaml:"ignore_columns,omitempty" mapstructure:"ignore_columns"`
+}
+
+// Row represents a row of a table, or a document of a collection
+type Row map[string]interface{}
+
+// UpdatedRow represents a row changed since the snapshot
+type UpdatedRow struct {
+	Key    Row `json:"key" yaml:"key"`
+	Before Row `json:"before" yaml:"before"`
+	After  Row `json:"after" yaml:"after"`
+	// Columns are the names of the changed columns
+	Columns []string `json:"columns" yaml:"columns"`
+}
+
+// Table represents the changes of a table since the snapshot: the number of rows of the table,
+// and the inserted, updated and deleted rows. It is a map so that the assertions use the name
+// of the table, such as result.tables.users.inserted
+type Table map[string]interface{}
+
+// Result represents a step result.
+type Result struct {
+	Snapshot string           `json:"snapshot" yaml:"snapshot"`
+	Tables   map[string]Table `json:"tables" yaml:"tables"`
+	// Inserted, Updated and Deleted are the number of changed rows of all the tables
+	Inserted int `json:"inserted" yaml:"inserted"`
+	Updated  int `json:"updated" yaml:"updated"`
+	Deleted  int `json:"deleted" yaml:"deleted"`
+}
+
+// Run implements the venom.Executor interface for Executor.
+func (e Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, error) {
+	if err := mapstructure.Decode(step, &e); err != nil {
+		return nil, err
+	}
+	diffCtx := getDiffCtx(ctx)
+	if diffCtx == nil {
+		return nil, fmt.Errorf("dbdiff executor is not set up")
+	}
+	if e.Snapshot == "" {
+		e.Snapshot = defaultSnapshot
+	}
+
+	switch e.Action {
+	case actionSnapshot:
+		s, err := e.takeSnapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		diffCtx.snapshots.set(e.Snapshot, s)
+		result := Result{Snapshot: e.Snapshot, Tables: map[string]Table{}}
+		for name, t := range s.tables {
+			result.Tables[name] = Table{"rows": t.count}
+		}
+		return result, nil
+	case actionDiff:
+		before, ok := diffCtx.snapshots.get(e.Snapshot)
+		if !ok {
+			return nil, fmt.Errorf("snapshot %q not found", e.Snapshot)
+		}
+		// the database and its tables are those of the snapshot
+		after, err := before.source.takeSnapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return diff(e.Snapshot, before, after), nil
+	}
+	return nil, fmt.Errorf("unknown action %q: expected %s or %s", e.Action, actionSnapshot, actionDiff)
+}
+
+// ZeroValueResult return an empty implementation of this executor result
+func (Executor) ZeroValueResult() interface{} {
+	return Result{}
+}
+
+// GetDefaultAssertions return the default assertions of the executor.
+func (e Executor) GetDefaultAssertions() venom.StepAssertions {
+	return venom.StepAssertions{Assertions: []venom.Assertion{}}
+}
+
+// snapshot holds the rows of the tables, and the step used to read them again
+type snapshot struct {
+	source Executor
+	tables map[string]table
+}
+
+// table holds the rows of a table by key. Several rows have the same key in a table without a key column,
+// when they are identical: they are all kept so that adding or removing a duplicate is a diff.
+type table struct {
+	rows  map[string][]Row
+	count int
+}
+
+// takeSnapshot reads the rows of all the tables of the step
+func (e Executor) takeSnapshot(ctx context.Context) (snapshot, error) {
+	var (
+		rows map[string][]Row
+		err  error
+	)
+	switch {
+	case e.URI != "":
+		rows, err = e.readCollections(ctx)
+	case e.Driver != "":
+		rows, err = e.readTables(ctx)
+	default:
+		return snapshot{}, fmt.Errorf("missing driver and dsn, or uri")
+	}
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	s := snapshot{source: e, tables: make(map[string]table, len(rows))}
+	for name, tableRows := range rows {
+		t := table{rows: make(map[string][]Row, len(tableRows)), count: len(tableRows)}
+		for _, r := range tableRows {
+			key, err := e.rowKey(name, r)
+			if err != nil {
+				return snapshot{}, err
+			}
+			t.rows[key] = append(t.rows[key], r)
+		}
+		s.tables[name] = t
+	}
+	return s, nil
+}
+
+// keyColumns returns the columns identifying the rows of a table
+func (e Executor) keyColumns(tableName string, r Row) []string {
+	if keys, ok := e.Keys[tableName]; ok {
+		return keys
+	}
+	defaultKey := "id"
+	if e.URI != "" {
+		defaultKey = "_id"
+	}
+	if _, ok := r[defaultKey]; ok {
+		return []string{defaultKey}
+	}
+	// without a key, the whole row identifies it: an update is reported as a deletion and an insertion
+	columns := make([]string, 0, len(r))
+	for c := range r {
+		if !e.ignored(c) {
+			columns = append(columns, c)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func (e Executor) rowKey(tableName string, r Row) (string, error) {
+	columns := e.keyColumns(tableName, r)
+	values := make([]interface{}, len(columns))
+	for i, c := range columns {
+		v, ok := r[c]
+		if !ok {
+			return "", fmt.Errorf("key column %q not found in table %q", c, tableName)
+		}
+		values[i] = v
+	}
+	btes, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("unable to compute the key of a row of table %q: %w", tableName, err)
+	}
+	return string(btes), nil
+}
+
+func (e Executor) ignored(column string) bool {
+	for _, c := range e.IgnoreColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// diff lists the rows inserted, updated and deleted between two snapshots
+func diff(name string, before, after snapshot) Result {
+	e := before.source
+
+	result := Result{Snapshot: name, Tables: make(map[string]Table, len(after.tables))}
+	for tableName, a := range after.tables {
+		b := before.tables[tableName]
+		inserted, updated, deleted := []Row{}, []UpdatedRow{}, []Row{}
+		for _, key := range sortedKeys(a.rows) {
+			newRows, oldRows := a.rows[key], b.rows[key]
+			// the rows with the same key are compared in their order, the others are inserted or deleted
+			for i, newRow := range newRows {
+				if i >= len(oldRows) {
+					inserted = append(inserted, newRow)
+					continue
+				}
+				oldRow := oldRows[i]
+				columns := e.diffColumns(oldRow, newRow)
+				if len(columns) == 0 {
+					continue
+				}
+				keyRow := Row{}
+				for _, c := range e.keyColumns(tableName, newRow) {
+					keyRow[c] = newRow[c]
+				}
+				updated = append(updated, UpdatedRow{Key: keyRow, Before: oldRow, After: newRow, Columns: columns})
+			}
+		}
+		for _, key := range sortedKeys(b.rows) {
+			if oldRows, n := b.rows[key], len(a.rows[key]); len(oldRows) > n {
+				deleted = append(deleted, oldRows[n:]...)
+			}
+		}
+		result.Inserted += len(inserted)
+		result.Updated += len(updated)
+		result.Deleted += len(deleted)
+		result.Tables[tableName] = Table{
+			"rows":     a.count,
+			"inserted": inserted,
+			"updated":  updated,
+			"deleted":  deleted,
+		}
+	}
+	return result
+}
+
+// diffColumns returns the sorted names of the columns whose values differ between two rows
+func (e Executor) diffColumns(before, after Row) []string {
+	var columns []string
+	for c, v := range after {
+		if e.ignored(c) {
+			continue
+		}
+		if old, ok := before[c]; !ok || !reflect.DeepEqual(old, v) {
+			columns = append(columns, c)
+		}
+	}
+	for c := range before {
+		if _, ok := after[c]; !ok && !e.ignored(c) {
+			columns = append(columns, c)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func sortedKeys(rows map[string][]Row) []string {
+	keys := make([]string, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,178 @@
+package dbdiff
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ovh/venom"
+	"github.com/ovh/venom/executors/sql"
+)
+
+func exec(t *testing.T, dsn string, commands ...interface{}) {
+	_, err := sql.New().Run(context.Background(), venom.TestStep{
+		"driver":   "sqlite",
+		"dsn":      dsn,
+		"exec":     true,
+		"commands": commands,
+	})
+	require.NoError(t, err)
+}
+
+func TestExecutor_Run(t *testing.T) {
+	venom.InitTestLogger(t)
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	exec(t, dsn,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, updated_at TEXT)",
+		"CREATE TABLE tags (name TEXT)",
+		"INSERT INTO users VALUES (1, 'foo', 'monday'), (2, 'bar', 'monday'), (3, 'baz', 'monday')",
+		"INSERT INTO tags VALUES ('a'), ('b')",
+	)
+
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+
+	res, err := e.Run(ctx, venom.TestStep{
+		"action":         "snapshot",
+		"driver":         "sqlite",
+		"dsn":            dsn,
+		"tables":         []interface{}{"users", "tags"},
+		"ignore_columns": []interface{}{"updated_at"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.(Result).Tables["users"]["rows"])
+
+	exec(t, dsn,
+		"INSERT INTO users VALUES (4, 'qux', 'tuesday')",
+		"UPDATE users SET name = 'foo2', updated_at = 'tuesday' WHERE id = 1",
+		"UPDATE users SET updated_at = 'tuesday' WHERE id = 3",
+		"DELETE FROM users WHERE id = 2",
+		"UPDATE tags SET name = 'c' WHERE name = 'b'",
+	)
+
+	res, err = e.Run(ctx, venom.TestStep{"action": "diff"})
+	require.NoError(t, err)
+	result := res.(Result)
+	assert.Equal(t, 2, result.Inserted)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 2, result.Deleted)
+
+	users := result.Tables["users"]
+	assert.Equal(t, 3, users["rows"])
+	inserted := users["inserted"].([]Row)
+	require.Len(t, inserted, 1)
+	assert.Equal(t, "qux", inserted[0]["name"])
+	updated := users["updated"].([]UpdatedRow)
+	require.Len(t, updated, 1)
+	assert.Equal(t, Row{"id": json.Number("1")}, updated[0].Key)
+	assert.Equal(t, []string{"name"}, updated[0].Columns)
+	assert.Equal(t, "foo", updated[0].Before["name"])
+	assert.Equal(t, "foo2", updated[0].After["name"])
+	deleted := users["deleted"].([]Row)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, "bar", deleted[0]["name"])
+
+	// without a key, an update is a deletion and an insertion
+	tags := result.Tables["tags"]
+	assert.Equal(t, []Row{{"name": "c"}}, tags["inserted"])
+	assert.Equal(t, []Row{{"name": "b"}}, tags["deleted"])
+
+	dump, err := venom.Dump(result)
+	require.NoError(t, err)
+	assert.Equal(t, "foo2", dump["result.tables.users.updated.updated0.after.name"])
+	assert.Equal(t, "name", dump["result.tables.users.updated.updated0.columns.columns0"])
+	assert.Equal(t, 1, dump["result.tables.users.inserted.__Len__"])
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "diff", "snapshot": "unknown"})
+	assert.Error(t, err)
+}
+
+func TestExecutor_Run_Keys(t *testing.T) {
+	venom.InitTestLogger(t)
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	exec(t, dsn,
+		"CREATE TABLE stocks (shop TEXT, product TEXT, quantity INTEGER)",
+		"INSERT INTO stocks VALUES ('paris', 'apple', 1), ('lyon', 'apple', 2)",
+	)
+
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+
+	_, err = e.Run(ctx, venom.TestStep{
+		"action":   "snapshot",
+		"snapshot": "stocks",
+		"driver":   "sqlite",
+		"dsn":      dsn,
+		"tables":   []interface{}{"stocks"},
+		"keys":     map[string]interface{}{"stocks": []interface{}{"shop", "product"}},
+	})
+	require.NoError(t, err)
+
+	exec(t, dsn, "UPDATE stocks SET quantity = 3 WHERE shop = 'lyon'")
+
+	res, err := e.Run(ctx, venom.TestStep{"action": "diff", "snapshot": "stocks"})
+	require.NoError(t, err)
+	updated := res.(Result).Tables["stocks"]["updated"].([]UpdatedRow)
+	require.Len(t, updated, 1)
+	assert.Equal(t, Row{"shop": "lyon", "product": "apple"}, updated[0].Key)
+	assert.Equal(t, []string{"quantity"}, updated[0].Columns)
+	assert.Equal(t, json.Number("3"), updated[0].After["quantity"])
+}
+
+func TestExecutor_Run_Duplicates(t *testing.T) {
+	venom.InitTestLogger(t)
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	exec(t, dsn,
+		"CREATE TABLE events (name TEXT)",
+		"INSERT INTO events VALUES ('created'), ('paid'), ('paid')",
+	)
+
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+
+	res, err := e.Run(ctx, venom.TestStep{
+		"action": "snapshot",
+		"driver": "sqlite",
+		"dsn":    dsn,
+		"tables": []interface{}{"events"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.(Result).Tables["events"]["rows"])
+
+	// the identical rows are counted: a duplicate added or removed is a diff
+	exec(t, dsn,
+		"INSERT INTO events VALUES ('created')",
+		"DELETE FROM events WHERE rowid = (SELECT MAX(rowid) FROM events WHERE name = 'paid')",
+	)
+
+	res, err = e.Run(ctx, venom.TestStep{"action": "diff"})
+	require.NoError(t, err)
+	result := res.(Result)
+	events := result.Tables["events"]
+	assert.Equal(t, 3, events["rows"])
+	assert.Equal(t, []Row{{"name": "created"}}, events["inserted"])
+	assert.Equal(t, []Row{{"name": "paid"}}, events["deleted"])
+	assert.Empty(t, events["updated"])
+	assert.Equal(t, 1, result.Inserted)
+	assert.Equal(t, 1, result.Deleted)
+}
+
+func TestDocumentRow(t *testing.T) {
+	id, err := primitive.ObjectIDFromHex("5f1b2c3d4e5f6a7b8c9d0e1f")
+	require.NoError(t, err)
+	r, err := documentRow(map[string]interface{}{"_id": id, "count": int32(2), "tags": []interface{}{"a"}})
+	require.NoError(t, err)
+	assert.Equal(t, Row{
+		"_id":   map[string]interface{}{"$oid": "5f1b2c3d4e5f6a7b8c9d0e1f"},
+		"count": json.Number("2"),
+		"tags":  []interface{}{"a"},
+	}, r)
+}
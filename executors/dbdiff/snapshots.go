@@ -0,0 +1,68 @@
+package dbdiff
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/ovh/venom"
+)
+
+// ContextKey is the key used to store the snapshots in the context
+const ContextKey = venom.ContextKey("dbdiffContext")
+
+// snapshots holds the snapshots by name
+type snapshots struct {
+	mutex     sync.Mutex
+	snapshots map[string]snapshot
+}
+
+type diffContext struct {
+	snapshots *snapshots
+}
+
+// Setup gets the snapshots shared by the testcases of the testsuite
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	if s := venom.TestSuiteResource(ctx, string(ContextKey), func() io.Closer { return newSnapshots() }); s != nil {
+		return context.WithValue(ctx, ContextKey, &diffContext{snapshots: s.(*snapshots)}), nil
+	}
+	return context.WithValue(ctx, ContextKey, &diffContext{snapshots: newSnapshots()}), nil
+}
+
+// TearDown has nothing to release, the snapshots are kept until the end of the testsuite
+func (Executor) TearDown(ctx context.Context) error {
+	return nil
+}
+
+func getDiffCtx(ctx context.Context) *diffContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*diffContext)
+}
+
+func newSnapshots() *snapshots {
+	return &snapshots{snapshots: map[string]snapshot{}}
+}
+
+func (s *snapshots) get(name string) (snapshot, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snap, ok := s.snapshots[name]
+	return snap, ok
+}
+
+func (s *snapshots) set(name string, snap snapshot) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snapshots[name] = snap
+}
+
+// Close releases the snapshots
+func (s *snapshots) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snapshots = map[string]snapshot{}
+	return nil
+}
@@ -0,0 +1,86 @@
+package dbdiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/ovh/venom"
+	"github.com/ovh/venom/executors/mongo"
+	"github.com/ovh/venom/executors/sql"
+)
+
+// readTables reads the rows of the SQL tables with the sql executor, so the values have the same types
+func (e Executor) readTables(ctx context.Context) (map[string][]Row, error) {
+	if len(e.Tables) == 0 {
+		return nil, fmt.Errorf("missing tables")
+	}
+	commands := make([]interface{}, len(e.Tables))
+	for i, t := range e.Tables {
+		commands[i] = "SELECT * FROM " + sql.QuoteIdentifier(e.Driver, t)
+	}
+	res, err := sql.New().Run(ctx, venom.TestStep{
+		"driver":   e.Driver,
+		"dsn":      e.DSN,
+		"commands": commands,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string][]Row, len(e.Tables))
+	for i, q := range res.(sql.Result).Queries {
+		tableRows := make([]Row, len(q.Rows))
+		for j, r := range q.Rows {
+			tableRows[j] = Row(r)
+		}
+		rows[e.Tables[i]] = tableRows
+	}
+	return rows, nil
+}
+
+// readCollections reads the documents of the mongo collections with the mongo executor
+func (e Executor) readCollections(ctx context.Context) (map[string][]Row, error) {
+	if len(e.Collections) == 0 {
+		return nil, fmt.Errorf("missing collections")
+	}
+	rows := make(map[string][]Row, len(e.Collections))
+	for _, c := range e.Collections {
+		res, err := mongo.New().Run(ctx, venom.TestStep{
+			"uri":        e.URI,
+			"database":   e.Database,
+			"collection": c,
+			"actions":    []map[string]interface{}{{"type": "find"}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		documents, _ := res.(mongo.Result).Actions[0]["results"].([]map[string]interface{})
+		tableRows := make([]Row, len(documents))
+		for i, d := range documents {
+			if tableRows[i], err = documentRow(d); err != nil {
+				return nil, fmt.Errorf("unable to read a document of collection %q: %w", c, err)
+			}
+		}
+		rows[c] = tableRows
+	}
+	return rows, nil
+}
+
+// documentRow converts a mongo document to relaxed extended JSON values, so they can be compared and asserted
+func documentRow(document map[string]interface{}) (Row, error) {
+	btes, err := bson.MarshalExtJSON(document, false, false)
+	if err != nil {
+		return nil, err
+	}
+	var r Row
+	decoder := json.NewDecoder(bytes.NewReader(btes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
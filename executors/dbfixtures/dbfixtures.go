@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	fixtures "github.com/go-testfixtures/testfixtures/v3"
 	"github.com/mitchellh/mapstructure"
@@ -13,16 +16,34 @@ import (
 	migrate "github.com/rubenv/sql-migrate"
 
 	// SQL drivers.
+	_ "github.com/ClickHouse/clickhouse-go/v2"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/microsoft/go-mssqldb"
 
 	"github.com/ovh/venom"
+	sqlexecutor "github.com/ovh/venom/executors/sql"
 )
 
 // Name of the executor.
 const Name = "dbfixtures"
 
+// ContextKey is the key used to store the tables to clean at the end of the testcase
+const ContextKey = venom.ContextKey("dbfixturesContext")
+
+// Isolation modes of the fixtures.
+const (
+	// IsolationNone keeps the fixtures in the database
+	IsolationNone = "none"
+	// IsolationTransaction runs the testcase in a transaction rolled back at the end of the testcase
+	IsolationTransaction = "transaction"
+	// IsolationTruncate truncates the fixtures tables at the end of the testcase
+	IsolationTruncate = "truncate"
+)
+
+var _ venom.ExecutorWithSetup = new(Executor)
+
 // New returns a new executor that can load
 // database fixtures.
 func New() venom.Executor {
@@ -40,11 +61,73 @@ type Executor struct {
 	Migrations         string   `json:"migrations" yaml:"migrations"`
 	MigrationsTable    string   `json:"migrationsTable" yaml:"migrationsTable"`
 	SkipResetSequences bool     `json:"skipResetSequences" yaml:"skipResetSequences"`
+	// Isolation cleans the fixtures at the end of the testcase: none (default), transaction or truncate
+	Isolation string `json:"isolation,omitempty" yaml:"isolation,omitempty"`
+	// Keys returns the primary keys of the fixtures tables, it is implied by PrimaryKeys
+	Keys bool `json:"keys,omitempty" yaml:"keys,omitempty"`
+	// PrimaryKeys is the primary key column of the fixtures tables, id by default
+	PrimaryKeys map[string]string `json:"primaryKeys,omitempty" yaml:"primaryKeys,omitempty"`
 }
 
 // Result represents a step result.
 type Result struct {
 	Executor Executor `json:"executor,omitempty" yaml:"executor,omitempty"`
+	// Keys are the primary keys of the rows of each fixtures table, in ascending order
+	Keys map[string][]interface{} `json:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+type dbfixturesContext struct {
+	isolation *sqlexecutor.Isolation
+	mutex     sync.Mutex
+	truncates []truncate
+}
+
+// truncate represents the tables of a database to truncate at the end of the testcase
+type truncate struct {
+	database string
+	dsn      string
+	tables   []string
+}
+
+// Setup prepares the isolation of the testcase, the sql steps use its transactions
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	isolation := sqlexecutor.NewIsolation()
+	ctx = sqlexecutor.WithIsolation(ctx, isolation)
+	return context.WithValue(ctx, ContextKey, &dbfixturesContext{isolation: isolation}), nil
+}
+
+// TearDown rolls back the transactions and truncates the tables of the testcase
+func (Executor) TearDown(ctx context.Context) error {
+	dbfCtx := getDBFixturesCtx(ctx)
+	if dbfCtx == nil {
+		return nil
+	}
+	var errs []string
+	if err := dbfCtx.isolation.Close(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	dbfCtx.mutex.Lock()
+	defer dbfCtx.mutex.Unlock()
+	for i := len(dbfCtx.truncates) - 1; i >= 0; i-- {
+		t := dbfCtx.truncates[i]
+		venom.Debug(ctx, "truncating tables %v of database %s\n", t.tables, t.database)
+		if err := t.run(ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	dbfCtx.truncates = nil
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to clean fixtures: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func getDBFixturesCtx(ctx context.Context) *dbfixturesContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*dbfixturesContext)
 }
 
 // Run implements the venom.Executor interface for Executor.
@@ -53,16 +136,45 @@ func (e Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, er
 	if err := mapstructure.Decode(step, &e); err != nil {
 		return nil, err
 	}
-	// Connect to the database and ping it.
-	venom.Debug(ctx, "connecting to database %s, %s\n", e.Database, e.DSN)
+	dialect := getDialect(e.Database, e.SkipResetSequences)
+	if dialect == nil && (e.Folder != "" || len(e.Files) != 0) {
+		return nil, fmt.Errorf("unable to load fixtures in database %q: unsupported database", e.Database)
+	}
+	dbfCtx := getDBFixturesCtx(ctx)
+	switch e.Isolation {
+	case "", IsolationNone:
+	case IsolationTransaction, IsolationTruncate:
+		if dbfCtx == nil {
+			return nil, fmt.Errorf("isolation %q is only available in a testcase", e.Isolation)
+		}
+		if e.Isolation == IsolationTransaction && e.Database == "clickhouse" {
+			return nil, fmt.Errorf("isolation %q is not supported by database %q", e.Isolation, e.Database)
+		}
+	default:
+		return nil, fmt.Errorf("invalid isolation %q (valid values are %v)",
+			e.Isolation, []string{IsolationNone, IsolationTransaction, IsolationTruncate})
+	}
 
-	db, err := sql.Open(e.Database, e.DSN)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to connect to database")
+	// Connect to the database and ping it, in the transaction of the testcase if it is isolated.
+	var db *sql.DB
+	if e.Isolation == IsolationTransaction {
+		xdb, err := dbfCtx.isolation.Open(ctx, e.Database, e.DSN)
+		if err != nil {
+			return nil, err
+		}
+		db = xdb.DB
+	} else {
+		venom.Debug(ctx, "connecting to database %s, %s\n", e.Database, e.DSN)
+
+		var err error
+		db, err = sql.Open(e.Database, e.DSN)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to database")
+		}
+		defer db.Close()
 	}
-	defer db.Close()
 
-	if err = db.Ping(); err != nil {
+	if err := db.Ping(); err != nil {
 		return nil, errors.Wrapf(err, "failed to ping database")
 	}
 
@@ -78,8 +190,15 @@ func (e Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, er
 			if errs != nil {
 				return nil, errs
 			}
-			if _, err = db.Exec(string(sbytes)); err != nil {
-				return nil, errors.Wrapf(err, "failed to exec schema from file %q", s)
+			statements := []string{string(sbytes)}
+			if e.Database == "clickhouse" {
+				// ClickHouse runs a single statement by query
+				statements = splitStatements(string(sbytes))
+			}
+			for _, statement := range statements {
+				if _, err := db.Exec(statement); err != nil {
+					return nil, errors.Wrapf(err, "failed to exec schema from file %q", s)
+				}
 			}
 		}
 	} else if e.Migrations != "" {
@@ -93,18 +212,38 @@ func (e Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, er
 		migrations := &migrate.FileMigrationSource{
 			Dir: dir,
 		}
-		n, errMigrate := migrate.Exec(db, e.Database, migrations, migrate.Up)
+		dialect, err := migrateDialect(e.Database)
+		if err != nil {
+			return nil, err
+		}
+		n, errMigrate := migrate.Exec(db, dialect, migrations, migrate.Up)
 		if errMigrate != nil {
 			return nil, fmt.Errorf("failed to apply up migrations: %s", errMigrate)
 		}
 		venom.Debug(ctx, "applied %d migrations\n", n)
 	}
 
+	tables, err := fixturesTables(e.Files, e.Folder, workdir)
+	if err != nil {
+		return nil, err
+	}
+
 	// Load fixtures in the databases.
-	if err = loadFixtures(ctx, db, e.Files, e.Folder, getDialect(e.Database, e.SkipResetSequences), workdir); err != nil {
+	if err = loadFixtures(ctx, db, e.Files, e.Folder, dialect, workdir); err != nil {
 		return nil, err
 	}
+	if e.Isolation == IsolationTruncate && len(tables) != 0 {
+		dbfCtx.mutex.Lock()
+		dbfCtx.truncates = append(dbfCtx.truncates, truncate{database: e.Database, dsn: e.DSN, tables: tables})
+		dbfCtx.mutex.Unlock()
+	}
+
 	r := Result{Executor: e}
+	if e.Keys || len(e.PrimaryKeys) > 0 {
+		if r.Keys, err = e.loadKeys(ctx, db, tables); err != nil {
+			return nil, err
+		}
+	}
 
 	return r, nil
 }
@@ -119,6 +258,156 @@ func (e Executor) GetDefaultAssertions() venom.StepAssertions {
 	return venom.StepAssertions{Assertions: []venom.Assertion{}}
 }
 
+// fixturesTables returns the tables of the fixtures, named after the fixtures files.
+// As for the fixtures, the files found in folder have priority over the list of files.
+func fixturesTables(files []string, folder string, workdir string) ([]string, error) {
+	if folder != "" {
+		entries, err := os.ReadDir(path.Join(workdir, folder))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read fixtures folder %q", path.Join(workdir, folder))
+		}
+		files = nil
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if !entry.IsDir() && (ext == ".yml" || ext == ".yaml") {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+	tables := make([]string, len(files))
+	for i, f := range files {
+		tables[i] = strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+	}
+	return tables, nil
+}
+
+// loadKeys reads the primary keys of the fixtures tables, with the same types as the sql executor.
+// The tables without primary key column are skipped, unless the column is given in PrimaryKeys.
+func (e Executor) loadKeys(ctx context.Context, db *sql.DB, tables []string) (map[string][]interface{}, error) {
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	queries := make([]string, len(tables))
+	for i, t := range tables {
+		queries[i] = "SELECT * FROM " + sqlexecutor.QuoteIdentifier(e.Database, t) + " WHERE 1 = 0"
+	}
+	results, err := sqlexecutor.Query(ctx, db, e.Database, queries...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the columns of the fixtures tables")
+	}
+
+	keyTables, keyColumns := []string{}, []string{}
+	queries = []string{}
+	for i, t := range tables {
+		column, explicit := e.PrimaryKeys[t]
+		if !explicit {
+			column = "id"
+		}
+		found := false
+		for _, c := range results[i].Columns {
+			found = found || c.Name == column
+		}
+		if !found {
+			if explicit {
+				return nil, fmt.Errorf("primary key %q not found in table %q", column, t)
+			}
+			venom.Debug(ctx, "table %s has no column %s, its keys are skipped\n", t, column)
+			continue
+		}
+		quoted := sqlexecutor.QuoteIdentifier(e.Database, column)
+		keyTables = append(keyTables, t)
+		keyColumns = append(keyColumns, column)
+		queries = append(queries, "SELECT "+quoted+" FROM "+sqlexecutor.QuoteIdentifier(e.Database, t)+" ORDER BY "+quoted)
+	}
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	if results, err = sqlexecutor.Query(ctx, db, e.Database, queries...); err != nil {
+		return nil, errors.Wrapf(err, "failed to read the primary keys of the fixtures tables")
+	}
+
+	keys := make(map[string][]interface{}, len(keyTables))
+	for i, t := range keyTables {
+		keys[t] = make([]interface{}, len(results[i].Rows))
+		for j, row := range results[i].Rows {
+			keys[t][j] = row[keyColumns[i]]
+		}
+	}
+	return keys, nil
+}
+
+// run removes the rows of the tables, the tables referenced by the others must be loaded first
+func (t truncate) run(ctx context.Context) error {
+	db, err := sql.Open(t.database, t.dsn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to database")
+	}
+	defer db.Close()
+
+	// the statements must run on the same connection, to keep the session settings
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to database")
+	}
+	defer conn.Close()
+	for _, s := range truncateStatements(t.database, t.tables) {
+		if _, err := conn.ExecContext(ctx, s); err != nil {
+			return errors.Wrapf(err, "failed to truncate tables of database %q", t.database)
+		}
+	}
+	return nil
+}
+
+// truncateStatements returns the statements removing the rows of the tables of the database
+func truncateStatements(database string, tables []string) []string {
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = sqlexecutor.QuoteIdentifier(database, table)
+	}
+
+	var statements []string
+	switch database {
+	case "postgres":
+		// a single statement truncates the tables referencing each other
+		statements = []string{"TRUNCATE TABLE " + strings.Join(quoted, ", ")}
+	case "mysql":
+		statements = append(statements, "SET FOREIGN_KEY_CHECKS = 0")
+		for _, table := range quoted {
+			statements = append(statements, "TRUNCATE TABLE "+table)
+		}
+		statements = append(statements, "SET FOREIGN_KEY_CHECKS = 1")
+	case "clickhouse":
+		for _, table := range quoted {
+			statements = append(statements, "TRUNCATE TABLE "+table)
+		}
+	default:
+		// SQL Server can't truncate a referenced table, and Sqlite has no TRUNCATE
+		for i := len(quoted) - 1; i >= 0; i-- {
+			statements = append(statements, "DELETE FROM "+quoted[i])
+		}
+	}
+	return statements
+}
+
+// splitStatements splits a SQL script on the semicolons ending a line
+func splitStatements(script string) []string {
+	var statements []string
+	var b strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		b.WriteString(line + "\n")
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if statement := strings.TrimSpace(b.String()); statement != ";" {
+				statements = append(statements, statement)
+			}
+			b.Reset()
+		}
+	}
+	if statement := strings.TrimSpace(b.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
 // loadFixtures loads the fixtures in the database.
 // It gives priority to the fixtures files found in folder,
 // and switch to the list of files if no folder was specified.
@@ -183,6 +472,21 @@ func getDialect(name string, skipResetSequences bool) func(*fixtures.Loader) err
 		return fixtures.Dialect("mysql")
 	case "sqlite3":
 		return fixtures.Dialect("sqlite3")
+	case "sqlserver", "mssql":
+		return fixtures.Dialect("sqlserver")
+	case "clickhouse":
+		return fixtures.Dialect("clickhouse")
 	}
 	return nil
 }
+
+// migrateDialect returns the name of the sql-migrate dialect of a database.
+func migrateDialect(name string) (string, error) {
+	if name == "sqlserver" {
+		name = "mssql"
+	}
+	if _, ok := migrate.MigrationDialects[name]; !ok {
+		return "", fmt.Errorf("migrations are not supported on database %s", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,202 @@
+package dbfixtures
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+	sqlexecutor "github.com/ovh/venom/executors/sql"
+)
+
+func newTestDir(t *testing.T) (context.Context, string) {
+	venom.InitTestLogger(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"schema.sql":          "CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT); CREATE TABLE tags (name TEXT);",
+		"fixtures/users.yml":  "- name: foo\n- name: bar\n",
+		"fixtures/tags.yml":   "- name: baz\n",
+		"fixtures/README.txt": "not a fixtures file",
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "fixtures"), 0o755))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.testsuite.workdir"), dir)
+	return ctx, filepath.Join(dir, "test.db")
+}
+
+func count(t *testing.T, ctx context.Context, dsn string) int64 {
+	res, err := sqlexecutor.New().Run(ctx, venom.TestStep{
+		"driver":   "sqlite3",
+		"dsn":      dsn,
+		"commands": []interface{}{"SELECT count(*) AS n FROM users"},
+	})
+	require.NoError(t, err)
+	n, err := res.(sqlexecutor.Result).Queries[0].Rows[0]["n"].(json.Number).Int64()
+	require.NoError(t, err)
+	return n
+}
+
+func TestExecutor_Run_Keys(t *testing.T) {
+	ctx, dsn := newTestDir(t)
+
+	res, err := New().Run(ctx, venom.TestStep{
+		"database": "sqlite3",
+		"dsn":      dsn,
+		"schemas":  []string{"schema.sql"},
+		"folder":   "fixtures",
+		"keys":     true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]interface{}{
+		"users": {json.Number("1"), json.Number("2")},
+	}, res.(Result).Keys)
+
+	// the keys are only read when asked
+	res, err = New().Run(ctx, venom.TestStep{
+		"database": "sqlite3",
+		"dsn":      dsn,
+		"files":    []string{"fixtures/users.yml"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, res.(Result).Keys)
+
+	_, err = New().Run(ctx, venom.TestStep{
+		"database":    "sqlite3",
+		"dsn":         dsn,
+		"files":       []string{"fixtures/tags.yml"},
+		"primaryKeys": map[string]string{"tags": "id"},
+	})
+	assert.Error(t, err)
+}
+
+func TestExecutor_Run_Transaction(t *testing.T) {
+	ctx, dsn := newTestDir(t)
+	_, err := New().Run(ctx, venom.TestStep{"database": "sqlite3", "dsn": dsn, "schemas": []string{"schema.sql"}})
+	require.NoError(t, err)
+
+	e := New().(*Executor)
+	ctx, err = e.Setup(ctx, venom.H{})
+	require.NoError(t, err)
+	_, err = e.Run(ctx, venom.TestStep{
+		"database":  "sqlite3",
+		"dsn":       dsn,
+		"folder":    "fixtures",
+		"isolation": "transaction",
+	})
+	require.NoError(t, err)
+	// the sql steps of the testcase see the fixtures
+	assert.EqualValues(t, 2, count(t, ctx, dsn))
+
+	require.NoError(t, e.TearDown(ctx))
+	assert.EqualValues(t, 0, count(t, context.Background(), dsn))
+}
+
+func TestExecutor_Run_Truncate(t *testing.T) {
+	ctx, dsn := newTestDir(t)
+
+	_, err := New().Run(ctx, venom.TestStep{"database": "sqlite3", "dsn": dsn, "folder": "fixtures", "isolation": "truncate"})
+	assert.Error(t, err)
+
+	e := New().(*Executor)
+	ctx, err = e.Setup(ctx, venom.H{})
+	require.NoError(t, err)
+	_, err = e.Run(ctx, venom.TestStep{
+		"database":  "sqlite3",
+		"dsn":       dsn,
+		"schemas":   []string{"schema.sql"},
+		"folder":    "fixtures",
+		"isolation": "truncate",
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count(t, ctx, dsn))
+
+	require.NoError(t, e.TearDown(ctx))
+	assert.EqualValues(t, 0, count(t, ctx, dsn))
+}
+
+func TestTruncateStatements(t *testing.T) {
+	tables := []string{"users", "dbo.posts"}
+	tests := []struct {
+		database string
+		want     []string
+	}{
+		{"postgres", []string{`TRUNCATE TABLE "users", "dbo"."posts"`}},
+		{"mysql", []string{"SET FOREIGN_KEY_CHECKS = 0", "TRUNCATE TABLE `users`", "TRUNCATE TABLE `dbo`.`posts`", "SET FOREIGN_KEY_CHECKS = 1"}},
+		{"clickhouse", []string{"TRUNCATE TABLE `users`", "TRUNCATE TABLE `dbo`.`posts`"}},
+		// the referencing tables are emptied first
+		{"sqlserver", []string{"DELETE FROM [dbo].[posts]", "DELETE FROM [users]"}},
+		{"mssql", []string{"DELETE FROM [dbo].[posts]", "DELETE FROM [users]"}},
+		{"sqlite3", []string{`DELETE FROM "dbo"."posts"`, `DELETE FROM "users"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.database, func(t *testing.T) {
+			assert.Equal(t, tt.want, truncateStatements(tt.database, tables))
+		})
+	}
+}
+
+func TestMigrateDialect(t *testing.T) {
+	tests := []struct {
+		database string
+		want     string
+		wantErr  string
+	}{
+		{database: "postgres", want: "postgres"},
+		{database: "mysql", want: "mysql"},
+		{database: "sqlite3", want: "sqlite3"},
+		{database: "sqlserver", want: "mssql"},
+		{database: "mssql", want: "mssql"},
+		{database: "clickhouse", wantErr: "migrations are not supported on database clickhouse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.database, func(t *testing.T) {
+			dialect, err := migrateDialect(tt.database)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, dialect)
+		})
+	}
+}
+
+func TestGetDialect(t *testing.T) {
+	for _, database := range []string{"postgres", "mysql", "sqlite3", "sqlserver", "mssql", "clickhouse"} {
+		assert.NotNil(t, getDialect(database, false), database)
+	}
+	assert.Nil(t, getDialect("oracle", false))
+}
+
+func TestExecutor_Run_ClickHouseTransaction(t *testing.T) {
+	ctx, _ := newTestDir(t)
+	e := New().(*Executor)
+	ctx, err := e.Setup(ctx, venom.H{})
+	require.NoError(t, err)
+	defer e.TearDown(ctx) // nolint
+
+	// ClickHouse has no transactions, the fixtures can only be truncated
+	_, err = e.Run(ctx, venom.TestStep{
+		"database":  "clickhouse",
+		"dsn":       "clickhouse://localhost:9000/default",
+		"folder":    "fixtures",
+		"isolation": "transaction",
+	})
+	assert.EqualError(t, err, `isolation "transaction" is not supported by database "clickhouse"`)
+}
+
+func TestSplitStatements(t *testing.T) {
+	assert.Equal(t, []string{
+		"DROP TABLE IF EXISTS users;",
+		"CREATE TABLE users (\n\tid UInt32\n\t,name String DEFAULT 'a;b'\n) ENGINE = MergeTree ORDER BY id;",
+		"SELECT 1",
+	}, splitStatements("DROP TABLE IF EXISTS users;\n\nCREATE TABLE users (\n\tid UInt32\n\t,name String DEFAULT 'a;b'\n) ENGINE = MergeTree ORDER BY id;\nSELECT 1\n"))
+	assert.Empty(t, splitStatements("\n\n"))
+}
@@ -1,25 +1,44 @@
 package exec
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/fsamin/go-dump"
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/ovh/venom"
 )
 
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
 // Name for test exec
 const Name = "exec"
 
+// ContextKey is the key used to store the background processes in the context
+const ContextKey = venom.ContextKey("execContext")
+
+const (
+	// waitDelay is the time left to the processes to close their output after being killed
+	waitDelay = 5 * time.Second
+	// stopTimeout is the time left to the background processes to stop, before being killed
+	stopTimeout = 5 * time.Second
+)
+
 // New returns a new Test Exec
 func New() venom.Executor {
 	return &Executor{}
@@ -30,6 +49,14 @@ type Executor struct {
 	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
 	Stdin   *string  `json:"stdin,omitempty" yaml:"stdin,omitempty"`
 	Script  *string  `json:"script,omitempty" yaml:"script,omitempty"`
+	// StdinFile is read as standard input, relative to the directory of the testsuite
+	StdinFile string `json:"stdin_file,omitempty" yaml:"stdin_file,omitempty" mapstructure:"stdin_file"`
+	// Env variables are added to the environment of venom
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	// WorkDir is relative to the directory of the testsuite. Default is the directory of the testsuite
+	WorkDir string `json:"workdir,omitempty" yaml:"workdir,omitempty"`
+	// Background starts the process without waiting for it, it is stopped at the end of the testcase
+	Background bool `json:"background,omitempty" yaml:"background,omitempty"`
 }
 
 // Result represents a step result
@@ -40,7 +67,29 @@ type Result struct {
 	SystemerrJSON interface{} `json:"systemerrjson,omitempty" yaml:"systemerrjson,omitempty"`
 	Err           string      `json:"err,omitempty" yaml:"err,omitempty"`
 	Code          string      `json:"code,omitempty" yaml:"code,omitempty"`
-	TimeSeconds   float64     `json:"timeseconds,omitempty" yaml:"timeseconds,omitempty"`
+	// Pid of the background process
+	Pid         int     `json:"pid,omitempty" yaml:"pid,omitempty"`
+	TimeSeconds float64 `json:"timeseconds,omitempty" yaml:"timeseconds,omitempty"`
+}
+
+// execContext holds the background processes started during the testcase
+type execContext struct {
+	mutex     sync.Mutex
+	processes []*backgroundProcess
+}
+
+// backgroundProcess is a process started by a step with background, done is closed when it exits
+type backgroundProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+func getExecCtx(ctx context.Context) *execContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*execContext)
 }
 
 // ZeroValueResult return an empty implementation of this executor result
@@ -53,6 +102,41 @@ func (Executor) GetDefaultAssertions() *venom.StepAssertions {
 	return &venom.StepAssertions{Assertions: []venom.Assertion{"result.code ShouldEqual 0"}}
 }
 
+// Setup prepares the holder of the background processes started during the testcase
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	return context.WithValue(ctx, ContextKey, &execContext{}), nil
+}
+
+// TearDown stops the background processes started during the testcase, they are killed if they don't stop in time
+func (Executor) TearDown(ctx context.Context) error {
+	execCtx := getExecCtx(ctx)
+	if execCtx == nil {
+		return nil
+	}
+	execCtx.mutex.Lock()
+	defer execCtx.mutex.Unlock()
+	for _, p := range execCtx.processes {
+		select {
+		case <-p.done:
+			continue
+		default:
+		}
+		venom.Debug(ctx, "stopping background process %d", p.cmd.Process.Pid)
+		if err := terminateProcessGroup(p.cmd); err != nil {
+			venom.Debug(ctx, "unable to terminate background process %d: %v", p.cmd.Process.Pid, err)
+		}
+		select {
+		case <-p.done:
+		case <-time.After(stopTimeout):
+			venom.Warn(ctx, "background process %d is still running after %s, killing it", p.cmd.Process.Pid, stopTimeout)
+			killProcessGroup(p.cmd) // nolint
+			<-p.done
+		}
+	}
+	execCtx.processes = nil
+	return nil
+}
+
 // Run execute TestStep of type exec
 func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, error) {
 	var e Executor
@@ -66,10 +150,20 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 	if e.Script != nil && *e.Script != "" && len(e.Command) != 0 {
 		return nil, fmt.Errorf("cannot use both 'script' and 'command'")
 	}
+	if e.Stdin != nil && e.StdinFile != "" {
+		return nil, fmt.Errorf("cannot use both 'stdin' and 'stdin_file'")
+	}
+	execCtx := getExecCtx(ctx)
+	if e.Background && execCtx == nil {
+		return nil, fmt.Errorf("background processes can only be started in a testcase")
+	}
+	workdir := venom.StringVarFromCtx(ctx, "venom.testsuite.workdir")
 
 	var (
 		command string
 		opts    []string
+		// cleanup is called when the process exits
+		cleanup = func() {}
 	)
 
 	if len(e.Command) != 0 {
@@ -132,7 +226,7 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 			scriptPath = oldPath
 			opts = append(opts, scriptPath)
 		}
-		defer os.Remove(scriptPath)
+		cleanup = func() { os.Remove(scriptPath) }
 
 		// Chmod file
 		if err := os.Chmod(scriptPath, 0o700); err != nil {
@@ -142,88 +236,97 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 		command = shell
 	}
 
-	start := time.Now()
-
-	cmd := exec.CommandContext(ctx, command, opts...)
-	venom.Debug(ctx, "teststep exec '%s %s'", command, strings.Join(opts, " "))
-	cmd.Dir = venom.StringVarFromCtx(ctx, "venom.testsuite.workdir")
+	var stdin io.Reader
 	if e.Stdin != nil {
-		cmd.Stdin = strings.NewReader(*e.Stdin)
+		stdin = strings.NewReader(*e.Stdin)
 	}
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("runScriptAction: Cannot get stdout pipe: %s", err)
+	if e.StdinFile != "" {
+		f, err := os.Open(absolutePath(workdir, e.StdinFile))
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("cannot open stdin file: %s", err)
+		}
+		defer f.Close()
+		stdin = f
 	}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, fmt.Errorf("runScriptAction: Cannot get stderr pipe: %s", err)
+	start := time.Now()
+
+	// The background processes are not stopped with the step, but at the end of the testcase.
+	// The process runs in its own group, so that its children are killed with it when the step times out.
+	cmd := exec.Command(command, opts...)
+	if !e.Background {
+		cmd = exec.CommandContext(ctx, command, opts...)
+		cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	}
+	venom.Debug(ctx, "teststep exec '%s %s'", command, strings.Join(opts, " "))
+	cmd.Dir = workdir
+	if e.WorkDir != "" {
+		cmd.Dir = absolutePath(workdir, e.WorkDir)
 	}
+	if len(e.Env) > 0 {
+		cmd.Env = append(os.Environ(), environ(e.Env)...)
+	}
+	cmd.Stdin = stdin
+	cmd.WaitDelay = waitDelay
+	setProcessGroup(cmd)
 
 	result := Result{}
-	outchan := make(chan bool)
-
-	go func() {
-		var sb strings.Builder
-		sb.Grow(1024 * 1024) // Pre-allocate 1MB
-
-		// For efficiency, read in larger chunks
-		buf := make([]byte, 64*1024) // 64KB buffer
-		for {
-			n, err := stdout.Read(buf)
-			if n > 0 {
-				sb.Write(buf[:n])
-			}
-			if err != nil {
-				break
-			}
-		}
-
-		result.Systemout = sb.String()
-		close(outchan)
-	}()
-
-	errchan := make(chan bool)
-	go func() {
-		var sb strings.Builder
-		sb.Grow(64 * 1024) // Pre-allocate 64KB for stderr
-
-		buf := make([]byte, 8*1024) // 8KB buffer for stderr
-		for {
-			n, err := stderr.Read(buf)
-			if n > 0 {
-				chunk := buf[:n]
-				sb.Write(chunk)
-				venom.Debug(ctx, "%s", string(chunk))
-			}
-			if err != nil {
-				break
-			}
-		}
-
-		result.Systemerr = sb.String()
-		stderr.Close()
-		close(errchan)
-	}()
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if e.Background {
+		cmd.Stdout = &debugWriter{ctx: ctx, prefix: "stdout"}
+		cmd.Stderr = &debugWriter{ctx: ctx, prefix: "stderr"}
+	} else {
+		cmd.Stdout = stdout
+		cmd.Stderr = io.MultiWriter(stderr, &debugWriter{ctx: ctx})
+	}
 
 	if err := cmd.Start(); err != nil {
+		cleanup()
 		result.Err = err.Error()
 		result.Code = "127"
 		venom.Debug(ctx, "error on cmd.Start: %v", err.Error())
-		return dump.ToMap(e, dump.WithDefaultLowerCaseFormatter())
+		return result, nil
 	}
 
-	<-outchan
-	<-errchan
+	if e.Background {
+		p := &backgroundProcess{cmd: cmd, done: make(chan struct{})}
+		go func() {
+			err := cmd.Wait()
+			venom.Debug(ctx, "background process %d exited: %v", cmd.Process.Pid, err)
+			cleanup()
+			close(p.done)
+		}()
+		execCtx.mutex.Lock()
+		execCtx.processes = append(execCtx.processes, p)
+		execCtx.mutex.Unlock()
+		venom.Debug(ctx, "background process %d started", cmd.Process.Pid)
+		result.Code = "0"
+		result.Pid = cmd.Process.Pid
+		result.TimeSeconds = time.Since(start).Seconds()
+		return result, nil
+	}
 
+	err := cmd.Wait()
+	cleanup()
 	result.Code = "0"
-	if err := cmd.Wait(); err != nil {
+	if err != nil {
 		if exiterr, ok := err.(*exec.ExitError); ok {
 			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
 				result.Code = strconv.Itoa(status.ExitStatus())
+				if status.Signaled() {
+					result.Code = strconv.Itoa(128 + int(status.Signal()))
+				}
 			}
 		}
 	}
+	// The output written before the process was killed is kept
+	if ctx.Err() != nil {
+		result.Err = fmt.Sprintf("process killed: %v", ctx.Err())
+	}
+	result.Systemout = stdout.String()
+	result.Systemerr = stderr.String()
 
 	elapsed := time.Since(start)
 	result.TimeSeconds = elapsed.Seconds()
@@ -243,3 +346,36 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 
 	return result, nil
 }
+
+// absolutePath returns the path, relative to the directory if it isn't absolute
+func absolutePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// environ returns the variables formatted as key=value, sorted by key
+func environ(env map[string]string) []string {
+	vars := make([]string, 0, len(env))
+	for k, v := range env {
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// debugWriter logs the output of a process
+type debugWriter struct {
+	ctx    context.Context
+	prefix string
+}
+
+func (w *debugWriter) Write(p []byte) (int, error) {
+	if w.prefix != "" {
+		venom.Debug(w.ctx, "%s: %s", w.prefix, string(p))
+	} else {
+		venom.Debug(w.ctx, "%s", string(p))
+	}
+	return len(p), nil
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fullstorydev/grpcurl"
@@ -42,6 +43,10 @@ type Executor struct {
 	JSONDefaultFields    bool                   `json:"default_fields" yaml:"default_fields"`
 	IncludeTextSeparator bool                   `json:"include_text_separator" yaml:"include_text_separator"`
 	Data                 map[string]interface{} `json:"data" yaml:"data"`
+	Messages             []interface{}          `json:"messages" yaml:"messages"`
+	ProtoFiles           []string               `json:"proto_files" yaml:"proto_files" mapstructure:"proto_files"`
+	ImportPaths          []string               `json:"import_paths" yaml:"import_paths" mapstructure:"import_paths"`
+	Protoset             []string               `json:"protoset" yaml:"protoset"`
 	Headers              map[string]string      `json:"headers" yaml:"headers"`
 	ConnectTimeout       *int64                 `json:"connect_timeout" yaml:"connect_timeout"`
 	TLSClientCert        string                 `json:"tls_client_cert" yaml:"tls_client_cert" mapstructure:"tls_client_cert"`
@@ -59,9 +64,15 @@ type Result struct {
 	Err           string        `json:"err,omitempty" yaml:"err,omitempty"`
 	Code          string        `json:"code,omitempty" yaml:"code,omitempty"`
 	Details       []interface{} `json:"details,omitempty" yaml:"details,omitempty"`
+	Responses     []interface{} `json:"responses,omitempty" yaml:"responses,omitempty"`
+	Headers       Metadata      `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Trailers      Metadata      `json:"trailers,omitempty" yaml:"trailers,omitempty"`
 	TimeSeconds   float64       `json:"timeseconds,omitempty" yaml:"timeseconds,omitempty"`
 }
 
+// Metadata represents gRPC headers or trailers, multiple values are joined with a comma
+type Metadata map[string]string
+
 type customHandler struct {
 	formatter grpcurl.Formatter
 	target    *Result
@@ -75,9 +86,12 @@ func (*customHandler) OnResolveMethod(m *desc.MethodDescriptor) {}
 func (*customHandler) OnSendHeaders(metadata.MD) {}
 
 // OnReceiveHeaders is called when response headers have been received.
-func (*customHandler) OnReceiveHeaders(m metadata.MD) {}
+func (c *customHandler) OnReceiveHeaders(m metadata.MD) {
+	c.target.Headers = newMetadata(m)
+}
 
 // OnReceiveResponse is called for each response message received.
+// Systemout contains the last response, all the responses are kept in Responses.
 func (c *customHandler) OnReceiveResponse(msg proto.Message) {
 	res, err := c.formatter(msg)
 	if err != nil || c.err != nil {
@@ -85,10 +99,17 @@ func (c *customHandler) OnReceiveResponse(msg proto.Message) {
 		return
 	}
 	c.target.Systemout = res
+
+	var resJSON interface{}
+	if err := venom.JSONUnmarshal([]byte(res), &resJSON); err != nil {
+		resJSON = res
+	}
+	c.target.Responses = append(c.target.Responses, resJSON)
 }
 
 // OnReceiveTrailers is called when response trailers and final RPC status have been received.
 func (c *customHandler) OnReceiveTrailers(stat *status.Status, met metadata.MD) {
+	c.target.Trailers = newMetadata(met)
 	if err := stat.Err(); err != nil {
 		c.target.Systemerr = err.Error()
 
@@ -127,17 +148,19 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 	}
 
 	// prepare headers
-	headers := make([]string, len(e.Headers))
+	headers := make([]string, 0, len(e.Headers))
 	for k, v := range e.Headers {
 		headers = append(headers, fmt.Sprintf("%s: %s", k, v))
 	}
 
-	// prepare data
-	data, err := json.Marshal(e.Data)
+	// prepare data: a single request message, or a stream of messages for client-streaming and bidi calls
+	data, err := e.requestData()
 	if err != nil {
 		return nil, fmt.Errorf("runGrpcurl: Cannot marshal request data: %s", err)
 	}
 
+	workdir := venom.StringVarFromCtx(ctx, "venom.testsuite.workdir")
+
 	result := Result{}
 	start := time.Now()
 
@@ -152,8 +175,6 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 
 		var creds credentials.TransportCredentials
 
-		workdir := venom.StringVarFromCtx(ctx, "venom.testsuite.workdir")
-
 		// connect to a TLS server
 		if e.TLSRootCA != "" {
 			TLSRootCAFilepath := e.TLSRootCA
@@ -231,29 +252,47 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 		return cc, nil
 	}
 
-	var cc *grpc.ClientConn
 	var descSource grpcurl.DescriptorSource
-	var refClient *grpcreflect.Client
-	md := grpcurl.MetadataFromHeaders(headers)
-	refCtx := metadata.NewOutgoingContext(ctx, md)
-	cc, err = dial()
+	switch {
+	case len(e.ProtoFiles) > 0 && len(e.Protoset) > 0:
+		return nil, errors.New("can only use one of 'proto_files' and 'protoset'")
+	case len(e.ProtoFiles) > 0:
+		importPaths := make([]string, 0, len(e.ImportPaths)+1)
+		for _, p := range e.ImportPaths {
+			importPaths = append(importPaths, absPath(workdir, p))
+		}
+		if len(importPaths) == 0 {
+			importPaths = append(importPaths, workdir)
+		}
+		descSource, err = grpcurl.DescriptorSourceFromProtoFiles(importPaths, e.ProtoFiles...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process proto source files: %w", err)
+		}
+	case len(e.Protoset) > 0:
+		protosets := make([]string, 0, len(e.Protoset))
+		for _, p := range e.Protoset {
+			protosets = append(protosets, absPath(workdir, p))
+		}
+		descSource, err = grpcurl.DescriptorSourceFromProtoSets(protosets...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process protoset files: %w", err)
+		}
+	}
+
+	cc, err := dial()
 	if err != nil {
 		return Result{Err: err.Error()}, fmt.Errorf("grpc dial error: %w", err)
 	}
-	refClient = grpcreflect.NewClientAuto(refCtx, cc)
-	descSource = grpcurl.DescriptorSourceFromServer(ctx, refClient)
-
 	// arrange for the RPCs to be cleanly shutdown
-	defer func() {
-		if refClient != nil {
-			refClient.Reset()
-			refClient = nil
-		}
-		if cc != nil {
-			_ = cc.Close()
-			cc = nil
-		}
-	}()
+	defer cc.Close() // nolint
+
+	if descSource == nil {
+		md := grpcurl.MetadataFromHeaders(headers)
+		refCtx := metadata.NewOutgoingContext(ctx, md)
+		refClient := grpcreflect.NewClientAuto(refCtx, cc)
+		defer refClient.Reset()
+		descSource = grpcurl.DescriptorSourceFromServer(ctx, refClient)
+	}
 
 	// prepare request and send
 	in := bytes.NewReader(data)
@@ -312,3 +351,41 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 
 	return result, nil
 }
+
+// requestData returns the JSON stream of request messages read by grpcurl
+func (e Executor) requestData() ([]byte, error) {
+	if len(e.Messages) == 0 {
+		return json.Marshal(e.Data)
+	}
+	if e.Data != nil {
+		return nil, errors.New("can only use one of 'data' and 'messages'")
+	}
+	var buf bytes.Buffer
+	for _, m := range e.Messages {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func newMetadata(md metadata.MD) Metadata {
+	if len(md) == 0 {
+		return nil
+	}
+	m := make(Metadata, len(md))
+	for k, v := range md {
+		m[k] = strings.Join(v, ",")
+	}
+	return m
+}
+
+func absPath(workdir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workdir, path)
+}
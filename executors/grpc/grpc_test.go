@@ -0,0 +1,247 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/ovh/venom"
+)
+
+// startEchoServer starts a gRPC server implementing testdata/echo.proto, without server reflection
+func startEchoServer(t *testing.T) (string, protoreflect.FileDescriptor) {
+	venom.InitTestLogger(t)
+
+	fds, err := protoparse.Parser{ImportPaths: []string{"testdata"}}.ParseFiles("echo.proto")
+	require.NoError(t, err)
+	fd := fds[0].UnwrapFile()
+	service := fd.Services().ByName("Echo")
+	request := fd.Messages().ByName("EchoRequest")
+	response := fd.Messages().ByName("EchoResponse")
+
+	recv := func(stream grpc.ServerStream) (string, int32, error) {
+		msg := dynamicpb.NewMessage(request)
+		if err := stream.RecvMsg(msg); err != nil {
+			return "", 0, err
+		}
+		return msg.Get(request.Fields().ByName("message")).String(), int32(msg.Get(request.Fields().ByName("times")).Int()), nil
+	}
+	send := func(stream grpc.ServerStream, s string) error {
+		msg := dynamicpb.NewMessage(response)
+		msg.Set(response.Fields().ByName("message"), protoreflect.ValueOfString(s))
+		return stream.SendMsg(msg)
+	}
+
+	handlers := map[string]grpc.StreamHandler{
+		"Say": func(_ interface{}, stream grpc.ServerStream) error {
+			s, _, err := recv(stream)
+			if err != nil {
+				return err
+			}
+			return send(stream, s)
+		},
+		"Collect": func(_ interface{}, stream grpc.ServerStream) error {
+			var all []string
+			for {
+				s, _, err := recv(stream)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				all = append(all, s)
+			}
+			return send(stream, strings.Join(all, " "))
+		},
+		"Repeat": func(_ interface{}, stream grpc.ServerStream) error {
+			s, times, err := recv(stream)
+			if err != nil {
+				return err
+			}
+			for i := int32(0); i < times; i++ {
+				if err := send(stream, fmt.Sprintf("%s #%d", s, i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		"Chat": func(_ interface{}, stream grpc.ServerStream) error {
+			for {
+				s, _, err := recv(stream)
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := send(stream, "re: "+s); err != nil {
+					return err
+				}
+			}
+		},
+	}
+
+	sd := grpc.ServiceDesc{ServiceName: string(service.FullName()), HandlerType: (*interface{})(nil)}
+	for i := 0; i < service.Methods().Len(); i++ {
+		m := service.Methods().Get(i)
+		handler := handlers[string(m.Name())]
+		sd.Streams = append(sd.Streams, grpc.StreamDesc{
+			StreamName: string(m.Name()),
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				_ = stream.SetHeader(metadata.Pairs("x-method", string(m.Name())))
+				stream.SetTrailer(metadata.Pairs("x-trailer", "done", "x-trailer", "again"))
+				return handler(srv, stream)
+			},
+			ServerStreams: m.IsStreamingServer(),
+			ClientStreams: m.IsStreamingClient(),
+		})
+	}
+
+	srv := grpc.NewServer()
+	srv.RegisterService(&sd, struct{}{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String(), fd
+}
+
+func run(t *testing.T, step venom.TestStep) Result {
+	ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.testsuite.workdir"), "testdata")
+	res, err := New().Run(ctx, step)
+	require.NoError(t, err)
+	return res.(Result)
+}
+
+func TestExecutor_Run_ProtoFiles(t *testing.T) {
+	url, _ := startEchoServer(t)
+
+	result := run(t, venom.TestStep{
+		"url":         url,
+		"proto_files": []interface{}{"echo.proto"},
+		"service":     "venom.test.Echo",
+		"method":      "Say",
+		"data":        map[string]interface{}{"message": "hello"},
+	})
+	assert.Equal(t, "0", result.Code)
+	assert.Equal(t, map[string]interface{}{"message": "hello"}, result.SystemoutJSON)
+	assert.Equal(t, []interface{}{map[string]interface{}{"message": "hello"}}, result.Responses)
+	assert.Equal(t, "Say", result.Headers["x-method"])
+	assert.Equal(t, "done,again", result.Trailers["x-trailer"])
+}
+
+func TestExecutor_Run_Streaming(t *testing.T) {
+	url, _ := startEchoServer(t)
+
+	result := run(t, venom.TestStep{
+		"url":         url,
+		"proto_files": []interface{}{"echo.proto"},
+		"service":     "venom.test.Echo",
+		"method":      "Collect",
+		"messages": []interface{}{
+			map[string]interface{}{"message": "hello"},
+			map[string]interface{}{"message": "world"},
+		},
+	})
+	assert.Equal(t, "0", result.Code)
+	assert.Equal(t, map[string]interface{}{"message": "hello world"}, result.SystemoutJSON)
+
+	result = run(t, venom.TestStep{
+		"url":         url,
+		"proto_files": []interface{}{"echo.proto"},
+		"service":     "venom.test.Echo",
+		"method":      "Repeat",
+		"data":        map[string]interface{}{"message": "hi", "times": 3},
+	})
+	assert.Equal(t, "0", result.Code)
+	require.Len(t, result.Responses, 3)
+	assert.Equal(t, map[string]interface{}{"message": "hi #2"}, result.Responses[2])
+	assert.Equal(t, map[string]interface{}{"message": "hi #2"}, result.SystemoutJSON)
+
+	result = run(t, venom.TestStep{
+		"url":         url,
+		"proto_files": []interface{}{"echo.proto"},
+		"service":     "venom.test.Echo",
+		"method":      "Chat",
+		"messages": []interface{}{
+			map[string]interface{}{"message": "a"},
+			map[string]interface{}{"message": "b"},
+		},
+	})
+	assert.Equal(t, "0", result.Code)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"message": "re: a"},
+		map[string]interface{}{"message": "re: b"},
+	}, result.Responses)
+}
+
+func TestExecutor_Run_Protoset(t *testing.T) {
+	url, fd := startEchoServer(t)
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(fd)}}
+	b, err := proto.Marshal(set)
+	require.NoError(t, err)
+	protoset := filepath.Join(t.TempDir(), "echo.protoset")
+	require.NoError(t, os.WriteFile(protoset, b, 0o644))
+
+	result := run(t, venom.TestStep{
+		"url":      url,
+		"protoset": []interface{}{protoset},
+		"service":  "venom.test.Echo",
+		"method":   "Say",
+		"data":     map[string]interface{}{"message": "from protoset"},
+	})
+	assert.Equal(t, "0", result.Code)
+	assert.Equal(t, map[string]interface{}{"message": "from protoset"}, result.SystemoutJSON)
+}
+
+func TestExecutor_Run_InvalidDescriptors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.testsuite.workdir"), "testdata")
+	// the options are checked before dialing the unreachable server
+	_, err := New().Run(ctx, venom.TestStep{
+		"url":         "127.0.0.1:1",
+		"proto_files": []interface{}{"echo.proto"},
+		"protoset":    []interface{}{"echo.protoset"},
+		"service":     "venom.test.Echo",
+		"method":      "Say",
+	})
+	assert.EqualError(t, err, "can only use one of 'proto_files' and 'protoset'")
+
+	_, err = New().Run(ctx, venom.TestStep{
+		"url":         "127.0.0.1:1",
+		"proto_files": []interface{}{"unknown.proto"},
+		"service":     "venom.test.Echo",
+		"method":      "Say",
+	})
+	assert.ErrorContains(t, err, "failed to process proto source files")
+}
+
+func TestExecutor_requestData(t *testing.T) {
+	_, err := Executor{
+		Data:     map[string]interface{}{"a": 1},
+		Messages: []interface{}{map[string]interface{}{"a": 1}},
+	}.requestData()
+	assert.Error(t, err)
+
+	data, err := Executor{Messages: []interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}}}.requestData()
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}\n{\"a\":2}\n", string(data))
+}
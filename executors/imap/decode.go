@@ -10,8 +10,11 @@ import (
 	"mime/multipart"
 	"mime/quotedprintable"
 	"net/mail"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/gosimple/slug"
 	"github.com/yesnault/go-imap/imap"
 
 	"github.com/ovh/venom"
@@ -39,83 +42,15 @@ func extract(ctx context.Context, rsp imap.Response) (Mail, error) {
 	}
 
 	if headerAttr, ok := rsp.MessageInfo().Attrs["RFC822.HEADER"]; ok {
-		header := imap.AsBytes(headerAttr)
-		msg, err = mail.ReadMessage(bytes.NewReader(header))
+		msg, err = m.readHeader(imap.AsBytes(headerAttr))
 		if err != nil {
 			return Mail{}, err
 		}
-		m.Subject, err = decodeHeader(msg, "Subject")
-		if err != nil {
-			return Mail{}, fmt.Errorf("cannot decode Subject header: %s", err)
-		}
-		m.From, err = decodeHeader(msg, "From")
-		if err != nil {
-			return Mail{}, fmt.Errorf("cannot decode From header: %s", err)
-		}
-		m.To, err = decodeHeader(msg, "To")
-		if err != nil {
-			return Mail{}, fmt.Errorf("cannot decode To header: %s", err)
-		}
 	}
 
 	if textAttr, ok := rsp.MessageInfo().Attrs["RFC822.TEXT"]; ok {
-		body := imap.AsBytes(textAttr)
-		encoding := msg.Header.Get("Content-Transfer-Encoding")
-		var r io.Reader = bytes.NewReader(body)
-		switch encoding {
-		case "7bit", "8bit", "binary":
-			// noop, reader already initialized.
-		case "quoted-printable":
-			r = quotedprintable.NewReader(r)
-		case "base64":
-			r = base64.NewDecoder(base64.StdEncoding, r)
-		}
-		venom.Debug(ctx, "Mail Content-Transfer-Encoding is %s ", encoding)
-
-		contentType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
-		// Using strings.Contains because "mime: no media type" error is not defined in mime package
-		// and thus, cannot be used with errors.Is
-		if err != nil && !strings.Contains(err.Error(), "mime: no media type") {
-			return Mail{}, fmt.Errorf("error while reading Content-Type:%s", err)
-		} else if err != nil && strings.Contains(err.Error(), "mime: no media type") {
-			// Error "mime: no media type" is not a blocking error (returns when no Content-Type header is present)
-			// But it means we cannot read body
-			venom.Warn(ctx, "Content-Type header empty, skipping body reading")
-		}
-		if contentType != "" {
-			if contentType == "multipart/mixed" || contentType == "multipart/alternative" {
-				if boundary, ok := params["boundary"]; ok {
-					mr := multipart.NewReader(r, boundary)
-					for {
-						p, errm := mr.NextPart()
-						if errm == io.EOF {
-							continue
-						}
-						if errm != nil {
-							venom.Debug(ctx, "Error while read Part:%s", err)
-							break
-						}
-						slurp, errm := io.ReadAll(p)
-						if errm != nil {
-							venom.Debug(ctx, "Error while ReadAll Part:%s", err)
-							continue
-						}
-						slurp = bytes.TrimRight(slurp, "\r\n")
-						m.Body = string(slurp)
-						break
-					}
-				}
-			} else {
-				body, err = io.ReadAll(r)
-				if err != nil {
-					return Mail{}, err
-				}
-				body = bytes.TrimRight(body, "\r\n")
-			}
-			if m.Body == "" {
-				body = bytes.TrimRight(body, "\r\n")
-				m.Body = string(body)
-			}
+		if err := m.readText(ctx, msg, imap.AsBytes(textAttr)); err != nil {
+			return Mail{}, err
 		}
 	}
 
@@ -127,3 +62,146 @@ func extract(ctx context.Context, rsp imap.Response) (Mail, error) {
 
 	return m, nil
 }
+
+// Decode reads the content of a raw RFC 822 mail, as received by a SMTP server
+func Decode(ctx context.Context, raw []byte) (Mail, error) {
+	m := Mail{}
+	header, text := raw, []byte{}
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(raw, []byte(sep)); i >= 0 {
+			header, text = raw[:i+len(sep)], raw[i+len(sep):]
+			break
+		}
+	}
+	msg, err := m.readHeader(header)
+	if err != nil {
+		return Mail{}, err
+	}
+	if err := m.readText(ctx, msg, text); err != nil {
+		return Mail{}, err
+	}
+	return m, nil
+}
+
+// readHeader reads the Subject, From and To headers of the mail
+func (m *Mail) readHeader(header []byte) (*mail.Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(header))
+	if err != nil {
+		return nil, err
+	}
+	m.Subject, err = decodeHeader(msg, "Subject")
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode Subject header: %s", err)
+	}
+	m.From, err = decodeHeader(msg, "From")
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode From header: %s", err)
+	}
+	m.To, err = decodeHeader(msg, "To")
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode To header: %s", err)
+	}
+	return msg, nil
+}
+
+// readText reads the body of the mail, according to the Content-Transfer-Encoding and Content-Type headers of msg.
+// The body of a multipart mail is its first text part, the parts with a file name are its attachments.
+func (m *Mail) readText(ctx context.Context, msg *mail.Message, body []byte) error {
+	encoding := msg.Header.Get("Content-Transfer-Encoding")
+	r := transferDecoder(bytes.NewReader(body), encoding)
+	venom.Debug(ctx, "Mail Content-Transfer-Encoding is %s ", encoding)
+
+	contentType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	// Using strings.Contains because "mime: no media type" error is not defined in mime package
+	// and thus, cannot be used with errors.Is
+	if err != nil && !strings.Contains(err.Error(), "mime: no media type") {
+		return fmt.Errorf("error while reading Content-Type:%s", err)
+	} else if err != nil && strings.Contains(err.Error(), "mime: no media type") {
+		// Error "mime: no media type" is not a blocking error (returns when no Content-Type header is present)
+		// But it means we cannot read body
+		venom.Warn(ctx, "Content-Type header empty, skipping body reading")
+	}
+	if contentType != "" {
+		if strings.HasPrefix(contentType, "multipart/") {
+			// the body stays empty if the mail has no text part, such as a mail with attachments only
+			if boundary, ok := params["boundary"]; ok {
+				m.readParts(ctx, r, boundary)
+			}
+		} else {
+			body, err = io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			m.Body = string(bytes.TrimRight(body, "\r\n"))
+		}
+	}
+	return nil
+}
+
+// readParts reads the parts of a multipart body, the nested multipart parts included
+func (m *Mail) readParts(ctx context.Context, r io.Reader, boundary string) {
+	mr := multipart.NewReader(r, boundary)
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			if err != io.EOF {
+				venom.Debug(ctx, "Error while read Part:%s", err)
+			}
+			return
+		}
+		// the quoted-printable parts are decoded by the multipart reader, which removes their Content-Transfer-Encoding header
+		content, err := io.ReadAll(transferDecoder(p, p.Header.Get("Content-Transfer-Encoding")))
+		if err != nil {
+			venom.Debug(ctx, "Error while ReadAll Part:%s", err)
+			continue
+		}
+		contentType, params, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		filename := p.FileName()
+		if filename == "" {
+			filename = params["name"]
+		}
+		if decoded, err := new(mime.WordDecoder).DecodeHeader(filename); err == nil {
+			filename = decoded
+		}
+
+		switch {
+		case strings.HasPrefix(contentType, "multipart/"):
+			m.readParts(ctx, bytes.NewReader(content), params["boundary"])
+		case filename != "":
+			m.Attachments = append(m.Attachments, Attachment{
+				Filename:    filename,
+				ContentType: contentType,
+				Size:        len(content),
+				content:     content,
+			})
+		case m.Body == "":
+			m.Body = string(bytes.TrimRight(content, "\r\n"))
+		}
+	}
+}
+
+// saveAttachments writes the attachments of the mail in the output directory, prefixed by the mailbox and the UID of the mail
+func (m *Mail) saveAttachments(ctx context.Context, mailbox string) error {
+	outputDir := venom.StringVarFromCtx(ctx, "venom.outputdir")
+	for i := range m.Attachments {
+		a := &m.Attachments[i]
+		a.Path = filepath.Join(outputDir, fmt.Sprintf("%s_%d_%s", slug.Make(mailbox), m.UID, filepath.Base(a.Filename)))
+		if err := os.WriteFile(a.Path, a.content, 0o644); err != nil {
+			return err
+		}
+		venom.Debug(ctx, "Attachment %q saved in %s", a.Filename, a.Path)
+	}
+	return nil
+}
+
+// transferDecoder returns a reader decoding the content according to its Content-Transfer-Encoding
+func transferDecoder(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	}
+	// 7bit, 8bit and binary contents are not encoded
+	return r
+}
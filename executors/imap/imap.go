@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +24,9 @@ const (
 	Name = "imap"
 	// imapClientTimeout represents the timeout for the IMAP client and, as a result, the timeout for the testcase
 	imapClientTimeout = 5 * time.Second
+	// Default values of the wait_for_mail polling
+	defaultWaitForMailTimeout  = 30   // seconds
+	defaultWaitForMailInterval = 1000 // milliseconds
 )
 
 var (
@@ -182,14 +187,23 @@ func (a *commandMoveArgs) initFrom(args map[string]any) {
 	}
 }
 
-// SearchCriteria represents the search criteria to fetch mails through FETCH command (https://www.rfc-editor.org/rfc/rfc3501#section-6.4.5)
+// SearchCriteria represents the search criteria to fetch mails through SEARCH and FETCH commands (https://www.rfc-editor.org/rfc/rfc3501#section-6.4.4)
+// From, To, Subject and Body are regular expressions checked on the fetched mails, the other criteria are checked by the server.
 type SearchCriteria struct {
-	Mailbox string `json:"mailbox,omitempty" yaml:"mailbox,omitempty"`
-	UID     uint32 `json:"uid,omitempty" yaml:"uid,omitempty"`
-	From    string `json:"from,omitempty" yaml:"from,omitempty"`
-	To      string `json:"to,omitempty" yaml:"to,omitempty"`
-	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
-	Body    string `json:"body,omitempty" yaml:"body,omitempty"`
+	Mailbox   string            `json:"mailbox,omitempty" yaml:"mailbox,omitempty"`
+	UID       uint32            `json:"uid,omitempty" yaml:"uid,omitempty"`
+	From      string            `json:"from,omitempty" yaml:"from,omitempty"`
+	To        string            `json:"to,omitempty" yaml:"to,omitempty"`
+	Subject   string            `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Body      string            `json:"body,omitempty" yaml:"body,omitempty"`
+	MessageID string            `json:"messageid,omitempty" yaml:"messageid,omitempty"`
+	Header    map[string]string `json:"header,omitempty" yaml:"header,omitempty"`
+	Text      string            `json:"text,omitempty" yaml:"text,omitempty"`
+	// Since and Before are dates formatted as 2006-01-02
+	Since  string   `json:"since,omitempty" yaml:"since,omitempty"`
+	Before string   `json:"before,omitempty" yaml:"before,omitempty"`
+	Unseen bool     `json:"unseen,omitempty" yaml:"unseen,omitempty"`
+	Flags  []string `json:"flags,omitempty" yaml:"flags,omitempty"`
 }
 
 func (s *SearchCriteria) isAnyMandatoryFieldEmpty() bool {
@@ -204,15 +218,38 @@ type Command struct {
 	Name CommandName `json:"name" yaml:"name"`
 	// Args defines the arguments to the command. Arguments associated to the command are listed in the README file
 	Args map[string]any `json:"args" yaml:"args"`
+	// WaitForMail polls the mailbox until mails match the Search field, before executing the command
+	WaitForMail *WaitForMail `json:"wait_for_mail,omitempty" yaml:"wait_for_mail,omitempty" mapstructure:"wait_for_mail"`
+}
+
+// WaitForMail represents the polling of a mailbox until enough mails match the search criteria
+type WaitForMail struct {
+	// Count of matching mails to wait for. Default is 1
+	Count int `json:"count,omitempty" yaml:"count,omitempty"`
+	// Timeout in seconds. Default is 30
+	Timeout int64 `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Interval between two searches in milliseconds. Default is 1000
+	Interval int64 `json:"interval,omitempty" yaml:"interval,omitempty"`
 }
 
 type Mail struct {
-	UID     uint32   `json:"uid,omitempty" yaml:"uid,omitempty"`
-	From    string   `json:"from,omitempty" yaml:"from,omitempty"`
-	To      string   `json:"to,omitempty" yaml:"to,omitempty"`
-	Subject string   `json:"subject,omitempty" yaml:"subject,omitempty"`
-	Body    string   `json:"body,omitempty" yaml:"body,omitempty"`
-	Flags   []string `json:"flags,omitempty" yaml:"flags,omitempty"`
+	UID         uint32       `json:"uid,omitempty" yaml:"uid,omitempty"`
+	From        string       `json:"from,omitempty" yaml:"from,omitempty"`
+	To          string       `json:"to,omitempty" yaml:"to,omitempty"`
+	Subject     string       `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Body        string       `json:"body,omitempty" yaml:"body,omitempty"`
+	Flags       []string     `json:"flags,omitempty" yaml:"flags,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty" yaml:"attachments,omitempty"`
+}
+
+// Attachment represents a file attached to a mail
+type Attachment struct {
+	Filename    string `json:"filename" yaml:"filename"`
+	ContentType string `json:"contenttype" yaml:"contenttype"`
+	Size        int    `json:"size" yaml:"size"`
+	// Path of the file, saved in the output directory by the fetch command
+	Path    string `json:"path,omitempty" yaml:"path,omitempty"`
+	content []byte
 }
 
 func (m *Mail) containsFlag(flag string) bool {
@@ -259,7 +296,9 @@ type CommandResult struct {
 	// Search represents the result of the Command's search field
 	Search Mail `json:"search,omitempty" yaml:"search,omitempty"`
 	// Mail represents the state of the searched mail after the command was executed
-	Mail        Mail    `json:"mail,omitempty" yaml:"mail,omitempty"`
+	Mail Mail `json:"mail,omitempty" yaml:"mail,omitempty"`
+	// Mails represents all the mails found by the fetch command, or by the wait_for_mail polling
+	Mails       []Mail  `json:"mails,omitempty" yaml:"mails,omitempty"`
 	Err         string  `json:"err,omitempty" yaml:"err,omitempty"`
 	TimeSeconds float64 `json:"timeseconds,omitempty" yaml:"timeseconds,omitempty"`
 }
@@ -321,6 +360,12 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 func (e Executor) handleCommands(ctx context.Context, c *Client) []CommandResult {
 	results := make([]CommandResult, len(e.Commands))
 	for i, command := range e.Commands {
+		if command.WaitForMail != nil {
+			if mails, err := c.waitForMail(ctx, command.Search, *command.WaitForMail); err != nil {
+				results[i] = CommandResult{Err: fmt.Sprintf("wait_for_mail: %v", err), Mails: mails}
+				continue
+			}
+		}
 		switch command.Name {
 		case CommandAppend:
 			args := commandAppendArgs{}
@@ -635,18 +680,27 @@ func (c *Client) fetchMail(ctx context.Context, mailToFind SearchCriteria) Comma
 	start := time.Now()
 	result := CommandResult{}
 
-	venom.Debug(ctx, "Searching mail with criteria: %+v", mailToFind)
-	mail, err := c.getFirstFoundMail(ctx, mailToFind)
+	venom.Debug(ctx, "Searching mails with criteria: %+v", mailToFind)
+	mails, err := c.searchMails(ctx, mailToFind)
+	if err == nil && len(mails) == 0 {
+		err = errMailNotFound
+	}
 	if err != nil {
 		return CommandResult{Err: fmt.Sprintf("error while retrieving mail: %v", err)}
 	}
-	venom.Debug(ctx, "Found mail: %+v", mail)
+	venom.Debug(ctx, "Found %d mail(s)", len(mails))
+	for i := range mails {
+		if err := mails[i].saveAttachments(ctx, mailToFind.Mailbox); err != nil {
+			return CommandResult{Err: fmt.Sprintf("error while saving attachments: %v", err)}
+		}
+	}
 	// To avoid confusing the user about whether field to test, both are valid as the command does not modify the mail
-	result.Search = mail
-	result.Mail = mail
+	result.Search = mails[0]
+	result.Mail = mails[0]
+	result.Mails = mails
 
 	result.TimeSeconds = time.Since(start).Seconds()
-	venom.Debug(ctx, "Move command executed in %.2f seconds", result.TimeSeconds)
+	venom.Debug(ctx, "Fetch command executed in %.2f seconds", result.TimeSeconds)
 	return result
 }
 
@@ -781,25 +835,37 @@ func (m *Mail) isSearched(mailToFind SearchCriteria) (bool, error) {
 }
 
 // getFirstFoundMail returns the first mail found through search criteria
-// If peek is true, mail body text will not be read thus leaving flags unchanged (for more information, refer to RFC 3501 section 6.4.5)
 func (c *Client) getFirstFoundMail(ctx context.Context, mailToFind SearchCriteria) (Mail, error) {
+	mails, err := c.searchMails(ctx, mailToFind)
+	if err != nil {
+		return Mail{}, err
+	}
+	if len(mails) == 0 {
+		return Mail{}, errMailNotFound
+	}
+	return mails[0], nil
+}
+
+// searchMails returns the mails found through search criteria: the server searches the mailbox, then the regular expressions are checked on the fetched mails
+func (c *Client) searchMails(ctx context.Context, mailToFind SearchCriteria) ([]Mail, error) {
 	if mailToFind.isAnyMandatoryFieldEmpty() {
-		return Mail{}, errors.New("empty search criteria: 'mailbox' is a mandatory field")
+		return nil, errors.New("empty search criteria: 'mailbox' is a mandatory field")
 	}
 
 	count, err := c.countNumberOfMessagesInMailbox(mailToFind.Mailbox)
 	if err != nil {
-		return Mail{}, fmt.Errorf("error while counting number of messages in mailbox %q: %w", mailToFind.Mailbox, err)
+		return nil, fmt.Errorf("error while counting number of messages in mailbox %q: %w", mailToFind.Mailbox, err)
 	}
 	if count == 0 {
-		return Mail{}, errEmptyMailbox
+		return nil, errEmptyMailbox
 	}
 
-	messages, err := c.fetchMails(ctx, mailToFind.Mailbox)
+	messages, err := c.fetchMails(ctx, mailToFind)
 	if err != nil {
-		return Mail{}, fmt.Errorf("error while fetching messages in mailbox %q: %v", mailToFind.Mailbox, err)
+		return nil, fmt.Errorf("error while fetching messages in mailbox %q: %v", mailToFind.Mailbox, err)
 	}
 
+	mails := []Mail{}
 	for _, msg := range messages {
 		mail, err := extract(ctx, msg)
 		if err != nil {
@@ -807,31 +873,83 @@ func (c *Client) getFirstFoundMail(ctx context.Context, mailToFind SearchCriteri
 			continue
 		}
 
-		// Handle the first found mail only
 		found, err := mail.isSearched(mailToFind)
 		if err != nil {
-			return Mail{}, err
+			return nil, err
 		}
-
 		if found {
-			return mail, nil
+			mails = append(mails, mail)
 		}
 	}
+	return mails, nil
+}
 
-	return Mail{}, errMailNotFound
+// waitForMail searches the mails until enough are found, or until the timeout
+func (c *Client) waitForMail(ctx context.Context, mailToFind SearchCriteria, wait WaitForMail) ([]Mail, error) {
+	count := 1
+	if wait.Count > 0 {
+		count = wait.Count
+	}
+	timeout := time.Duration(defaultWaitForMailTimeout) * time.Second
+	if wait.Timeout > 0 {
+		timeout = time.Duration(wait.Timeout) * time.Second
+	}
+	interval := time.Duration(defaultWaitForMailInterval) * time.Millisecond
+	if wait.Interval > 0 {
+		interval = time.Duration(wait.Interval) * time.Millisecond
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		mails, err := c.searchMails(ctx, mailToFind)
+		if err != nil && err != errEmptyMailbox {
+			return nil, err
+		}
+		if len(mails) >= count {
+			return mails, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return mails, fmt.Errorf("%d mail(s) found after %s while waiting for %d", len(mails), timeout, count)
+		}
+		venom.Debug(ctx, "%d mail(s) found while waiting for %d, next search in %s", len(mails), count, interval)
+		select {
+		case <-ctx.Done():
+			return mails, fmt.Errorf("%d mail(s) found while waiting for %d: %w", len(mails), count, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
 }
 
-func (c *Client) fetchMails(ctx context.Context, mailbox string) ([]imap.Response, error) {
-	venom.Debug(ctx, "Selecting mailbox %q", mailbox)
-	if _, err := c.Select(mailbox, false); err != nil {
+// fetchMails fetches the mails of the mailbox found by the server through the search criteria
+func (c *Client) fetchMails(ctx context.Context, mailToFind SearchCriteria) ([]imap.Response, error) {
+	venom.Debug(ctx, "Selecting mailbox %q", mailToFind.Mailbox)
+	if _, err := c.Select(mailToFind.Mailbox, false); err != nil {
 		venom.Error(ctx, "Error with select %s", err)
 		return []imap.Response{}, err
 	}
 
-	seqset, _ := imap.NewSeqSet("1:*")
+	keys, err := mailToFind.searchKeys(c)
+	if err != nil {
+		return []imap.Response{}, err
+	}
+	cmd, err := imap.Wait(c.UIDSearch(keys...))
+	if err != nil {
+		venom.Error(ctx, "Error with SEARCH command: %v", err)
+		return []imap.Response{}, err
+	}
+	uids := []uint32{}
+	for _, rsp := range cmd.Data {
+		uids = append(uids, rsp.SearchResults()...)
+	}
+	venom.Debug(ctx, "Server found %d message(s) in mailbox %q", len(uids), mailToFind.Mailbox)
+	if len(uids) == 0 {
+		return []imap.Response{}, nil
+	}
+	seqset, _ := imap.NewSeqSet("")
+	seqset.AddNum(uids...)
 
 	messages := []imap.Response{}
-	cmd, err := imap.Wait(c.Fetch(seqset, "UID", "ENVELOPE", "FLAGS", "RFC822.HEADER", "RFC822.TEXT", "BODY.PEEK[TEXT]"))
+	cmd, err = imap.Wait(c.UIDFetch(seqset, "UID", "ENVELOPE", "FLAGS", "RFC822.HEADER", "RFC822.TEXT", "BODY.PEEK[TEXT]"))
 	if err != nil {
 		venom.Error(ctx, "Error with FETCH command: %v", err)
 		return []imap.Response{}, err
@@ -842,10 +960,79 @@ func (c *Client) fetchMails(ctx context.Context, mailbox string) ([]imap.Respons
 		messages = append(messages, *rsp)
 	}
 
-	venom.Debug(ctx, "Fetched %d message(s) from mailbox %q", len(messages), mailbox)
+	venom.Debug(ctx, "Fetched %d message(s) from mailbox %q", len(messages), mailToFind.Mailbox)
 	return messages, nil
 }
 
+// searchKeys returns the keys of the SEARCH command matching the criteria, or ALL if there is none.
+// The client sends them with the UTF-8 charset.
+func (s *SearchCriteria) searchKeys(c *Client) ([]imap.Field, error) {
+	keys := []imap.Field{}
+	add := func(key string, values ...string) {
+		keys = append(keys, key)
+		for _, v := range values {
+			keys = append(keys, c.Quote(v))
+		}
+	}
+
+	if s.UID != 0 {
+		keys = append(keys, "UID", strconv.FormatUint(uint64(s.UID), 10))
+	}
+	// The regular expressions are checked on the fetched mails, their literal prefix narrows the search of the server
+	for _, r := range []struct{ key, expr string }{{"FROM", s.From}, {"TO", s.To}, {"SUBJECT", s.Subject}} {
+		if r.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(r.expr)
+		if err != nil {
+			return nil, err
+		}
+		if prefix, _ := re.LiteralPrefix(); strings.TrimSpace(prefix) != "" {
+			add(r.key, prefix)
+		}
+	}
+	if s.MessageID != "" {
+		add("HEADER", "Message-ID", s.MessageID)
+	}
+	headers := make([]string, 0, len(s.Header))
+	for k := range s.Header {
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
+	for _, k := range headers {
+		add("HEADER", k, s.Header[k])
+	}
+	if s.Text != "" {
+		add("TEXT", s.Text)
+	}
+	for _, d := range []struct{ key, date string }{{"SINCE", s.Since}, {"BEFORE", s.Before}} {
+		if d.date == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", d.date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s date %q, expected format is 2006-01-02", strings.ToLower(d.key), d.date)
+		}
+		keys = append(keys, d.key, date.Format("2-Jan-2006"))
+	}
+	if s.Unseen {
+		keys = append(keys, "UNSEEN")
+	}
+	for _, flag := range s.Flags {
+		switch strings.ToLower(flag) {
+		case `\seen`, `\answered`, `\flagged`, `\deleted`, `\draft`:
+			keys = append(keys, strings.ToUpper(flag[1:]))
+		default:
+			keys = append(keys, "KEYWORD", flag)
+		}
+	}
+
+	if len(keys) == 0 {
+		return []imap.Field{"ALL"}, nil
+	}
+	return keys, nil
+}
+
 func (c *Client) countNumberOfMessagesInMailbox(mailbox string) (uint32, error) {
 	cmd, err := imap.Wait(c.Status(mailbox))
 	if err != nil {
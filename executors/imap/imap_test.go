@@ -1,7 +1,10 @@
 package imap
 
 import (
+	"context"
 	"testing"
+
+	"github.com/ovh/venom"
 )
 
 func TestMail_containsFlag(t *testing.T) {
@@ -299,3 +302,76 @@ func TestMail_hasSameFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestDecode(t *testing.T) {
+	venom.InitTestLogger(t)
+	raw := "From: shop@venom.local\r\n" +
+		"To: customer@example.org\r\n" +
+		"Subject: Invoice\r\n" +
+		"Content-Type: multipart/mixed; boundary=mixed\r\n" +
+		"\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: multipart/alternative; boundary=alt\r\n" +
+		"\r\n" +
+		"--alt\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Your invoice is attached =E2=82=AC\r\n" +
+		"--alt\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		"<p>Your invoice is attached</p>\r\n" +
+		"--alt--\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: application/pdf; name=\"invoice.pdf\"\r\n" +
+		"Content-Disposition: attachment; filename=\"invoice.pdf\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"JVBERi0xLjQ=\r\n" +
+		"--mixed--\r\n"
+
+	m, err := Decode(context.Background(), []byte(raw))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if m.Subject != "Invoice" || m.From != "shop@venom.local" || m.To != "customer@example.org" {
+		t.Errorf("Decode() headers = %q, %q, %q", m.Subject, m.From, m.To)
+	}
+	if want := "Your invoice is attached €"; m.Body != want {
+		t.Errorf("Decode() body = %q, want %q", m.Body, want)
+	}
+	if len(m.Attachments) != 1 {
+		t.Fatalf("Decode() attachments = %+v, want 1 attachment", m.Attachments)
+	}
+	a := m.Attachments[0]
+	if a.Filename != "invoice.pdf" || a.ContentType != "application/pdf" || a.Size != 8 || string(a.content) != "%PDF-1.4" {
+		t.Errorf("Decode() attachment = %+v", a)
+	}
+}
+
+func TestDecode_AttachmentOnly(t *testing.T) {
+	venom.InitTestLogger(t)
+	raw := "From: shop@venom.local\r\n" +
+		"Subject: Invoice\r\n" +
+		"Content-Type: multipart/mixed; boundary=mixed\r\n" +
+		"\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Disposition: attachment; filename=\"invoice.pdf\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"JVBERi0xLjQ=\r\n" +
+		"--mixed--\r\n"
+
+	m, err := Decode(context.Background(), []byte(raw))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if m.Body != "" {
+		t.Errorf("Decode() body = %q, want empty body", m.Body)
+	}
+	if len(m.Attachments) != 1 {
+		t.Errorf("Decode() attachments = %+v, want 1 attachment", m.Attachments)
+	}
+}
@@ -0,0 +1,220 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/IBM/sarama"
+
+	"github.com/ovh/venom"
+)
+
+// PartitionLag represents the committed offset of a consumer group on a partition
+type PartitionLag struct {
+	Topic     string `json:"topic" yaml:"topic"`
+	Partition int32  `json:"partition" yaml:"partition"`
+	Offset    int64  `json:"offset" yaml:"offset"`
+	EndOffset int64  `json:"end_offset" yaml:"endOffset"`
+	Lag       int64  `json:"lag" yaml:"lag"`
+}
+
+// runAdminAction runs the admin action of the step and fills the result
+func (e Executor) runAdminAction(ctx context.Context, result *Result) error {
+	config, err := e.getKafkaConfig()
+	if err != nil {
+		return err
+	}
+	client, err := sarama.NewClient(e.Addrs, config)
+	if err != nil {
+		return fmt.Errorf("error instantiate client err: %w", err)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return fmt.Errorf("error instantiate cluster admin err: %w", err)
+	}
+	// closing the admin also closes the client
+	defer func() { _ = admin.Close() }()
+
+	switch e.Action {
+	case "create_topic":
+		return e.createTopics(ctx, admin)
+	case "delete_topic":
+		for _, topic := range e.Topics {
+			venom.Debug(ctx, "deleting topic %s", topic)
+			if err := admin.DeleteTopic(topic); err != nil {
+				return fmt.Errorf("can't delete topic %s: %w", topic, err)
+			}
+		}
+		return nil
+	case "describe_consumer_group":
+		return e.describeConsumerGroup(client, admin, result)
+	case "reset_offsets":
+		if err := e.resetOffsets(ctx, client); err != nil {
+			return err
+		}
+		return e.describeConsumerGroup(client, admin, result)
+	default:
+		return fmt.Errorf("action must be create_topic, delete_topic, describe_consumer_group or reset_offsets")
+	}
+}
+
+func (e Executor) createTopics(ctx context.Context, admin sarama.ClusterAdmin) error {
+	detail := &sarama.TopicDetail{
+		NumPartitions:     e.NumPartitions,
+		ReplicationFactor: e.ReplicationFactor,
+	}
+	if detail.NumPartitions == 0 {
+		detail.NumPartitions = 1
+	}
+	if detail.ReplicationFactor == 0 {
+		detail.ReplicationFactor = 1
+	}
+	if len(e.TopicConfig) > 0 {
+		detail.ConfigEntries = make(map[string]*string, len(e.TopicConfig))
+		for k := range e.TopicConfig {
+			v := e.TopicConfig[k]
+			detail.ConfigEntries[k] = &v
+		}
+	}
+	for _, topic := range e.Topics {
+		venom.Debug(ctx, "creating topic %s with %d partition(s)", topic, detail.NumPartitions)
+		if err := admin.CreateTopic(topic, detail, false); err != nil {
+			return fmt.Errorf("can't create topic %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+// describeConsumerGroup fills the result with the state of the group and its lag on each partition
+func (e Executor) describeConsumerGroup(client sarama.Client, admin sarama.ClusterAdmin, result *Result) error {
+	if e.GroupID == "" {
+		return fmt.Errorf("groupID is mandatory")
+	}
+	groups, err := admin.DescribeConsumerGroups([]string{e.GroupID})
+	if err != nil {
+		return fmt.Errorf("can't describe consumer group %s: %w", e.GroupID, err)
+	}
+	if len(groups) == 1 {
+		result.GroupState = groups[0].State
+		result.Members = len(groups[0].Members)
+	}
+
+	// a nil map lists the offsets of all the topics consumed by the group
+	var topicPartitions map[string][]int32
+	if len(e.Topics) > 0 {
+		topicPartitions = make(map[string][]int32, len(e.Topics))
+		for _, topic := range e.Topics {
+			partitions, err := client.Partitions(topic)
+			if err != nil {
+				return fmt.Errorf("can't get partitions of topic %s: %w", topic, err)
+			}
+			topicPartitions[topic] = partitions
+		}
+	}
+	offsets, err := admin.ListConsumerGroupOffsets(e.GroupID, topicPartitions)
+	if err != nil {
+		return fmt.Errorf("can't list offsets of consumer group %s: %w", e.GroupID, err)
+	}
+
+	result.Offsets = []PartitionLag{}
+	result.TotalLag = 0
+	for topic, blocks := range offsets.Blocks {
+		for partition, block := range blocks {
+			if block.Err != sarama.ErrNoError {
+				return fmt.Errorf("can't get offset of topic %s partition %d: %w", topic, partition, block.Err)
+			}
+			endOffset, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return fmt.Errorf("can't get end offset of topic %s partition %d: %w", topic, partition, err)
+			}
+			// without committed offset, the lag is computed from the oldest available message
+			from := block.Offset
+			if from < 0 {
+				from, err = client.GetOffset(topic, partition, sarama.OffsetOldest)
+				if err != nil {
+					return fmt.Errorf("can't get oldest offset of topic %s partition %d: %w", topic, partition, err)
+				}
+			}
+			lag := PartitionLag{
+				Topic:     topic,
+				Partition: partition,
+				Offset:    block.Offset,
+				EndOffset: endOffset,
+				Lag:       endOffset - from,
+			}
+			result.Offsets = append(result.Offsets, lag)
+			result.TotalLag += lag.Lag
+		}
+	}
+	sort.Slice(result.Offsets, func(i, j int) bool {
+		if result.Offsets[i].Topic != result.Offsets[j].Topic {
+			return result.Offsets[i].Topic < result.Offsets[j].Topic
+		}
+		return result.Offsets[i].Partition < result.Offsets[j].Partition
+	})
+	return nil
+}
+
+// resetOffsets commits the offsets of the group to FromOffset or FromTimestamp.
+// The group must not have active members.
+func (e Executor) resetOffsets(ctx context.Context, client sarama.Client) error {
+	if e.GroupID == "" {
+		return fmt.Errorf("groupID is mandatory")
+	}
+	if e.FromOffset == nil && e.FromTimestamp == "" {
+		return fmt.Errorf("fromOffset or fromTimestamp is mandatory")
+	}
+	offsetManager, err := sarama.NewOffsetManagerFromClient(e.GroupID, client)
+	if err != nil {
+		return fmt.Errorf("error instantiate offset manager err: %w", err)
+	}
+	defer func() { _ = offsetManager.Close() }()
+
+	var poms []sarama.PartitionOffsetManager
+	for _, topic := range e.Topics {
+		partitions := e.Partitions
+		if len(partitions) == 0 {
+			partitions, err = client.Partitions(topic)
+			if err != nil {
+				return fmt.Errorf("can't get partitions of topic %s: %w", topic, err)
+			}
+		}
+		for _, partition := range partitions {
+			offset, err := e.startOffset(client, topic, partition)
+			if err != nil {
+				return err
+			}
+			// OffsetNewest and OffsetOldest are resolved, they can't be committed as is
+			if offset < 0 {
+				offset, err = client.GetOffset(topic, partition, offset)
+				if err != nil {
+					return fmt.Errorf("can't get offset of topic %s partition %d: %w", topic, partition, err)
+				}
+			}
+			pom, err := offsetManager.ManagePartition(topic, partition)
+			if err != nil {
+				return fmt.Errorf("can't manage offset of topic %s partition %d: %w", topic, partition, err)
+			}
+			venom.Debug(ctx, "resetting offset of group %s on topic %s partition %d to %d", e.GroupID, topic, partition, offset)
+			// MarkOffset only moves forward and ResetOffset only moves backward
+			pom.MarkOffset(offset, "")
+			pom.ResetOffset(offset, "")
+			pom.AsyncClose()
+			poms = append(poms, pom)
+		}
+	}
+
+	offsetManager.Commit()
+	for _, pom := range poms {
+		select {
+		case err := <-pom.Errors():
+			if err != nil {
+				return fmt.Errorf("can't reset offsets of group %s: %w", e.GroupID, err)
+			}
+		default:
+		}
+	}
+	return nil
+}
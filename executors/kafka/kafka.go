@@ -3,17 +3,20 @@ package kafka
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
 
 	"github.com/ovh/venom"
 )
@@ -40,13 +43,40 @@ type (
 		Value          string            `json:"value,omitempty" yaml:"value,omitempty"`
 		ValueFile      string            `json:"valueFile,omitempty" yaml:"valueFile,omitempty"`
 		AvroSchemaFile string            `json:"avroSchemaFile,omitempty" yaml:"avroSchemaFile,omitempty"`
+		// SchemaFile is the Protobuf or JSON schema file used with WithProtobuf or WithJSONSchema
+		SchemaFile string `json:"schemaFile,omitempty" yaml:"schemaFile,omitempty"`
+		// ProtoMessage is the name of the Protobuf message of the value, the first message of the schema by default
+		ProtoMessage string `json:"protoMessage,omitempty" yaml:"protoMessage,omitempty"`
+	}
+
+	// ConsumedMessage represents a message received from kafka, with its position in the topic
+	ConsumedMessage struct {
+		Topic     string            `json:"topic" yaml:"topic"`
+		Key       string            `json:"key" yaml:"key"`
+		Headers   map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+		Value     string            `json:"value,omitempty" yaml:"value,omitempty"`
+		Partition int32             `json:"partition" yaml:"partition"`
+		Offset    int64             `json:"offset" yaml:"offset"`
+		Timestamp string            `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
 	}
 
 	// MessageJSON represents the object sended or received from kafka
 	MessageJSON struct {
-		Topic string
-		Key   interface{}
-		Value interface{}
+		Topic     string
+		Key       interface{}
+		Value     interface{}
+		Headers   map[string]string
+		Partition int32
+		Offset    int64
+		Timestamp string
+	}
+
+	// Match represents the conditions a consumed message must fulfill to be kept
+	Match struct {
+		// Headers the message must have, with their values
+		Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+		// Value is a map of paths in the JSON value (such as user.roles.0) to their expected values
+		Value map[string]string `json:"value,omitempty" yaml:"value,omitempty"`
 	}
 
 	// Executor represents a Test Exec
@@ -55,6 +85,8 @@ type (
 		// Registry schema address
 		SchemaRegistryAddr string `json:"schema_registry_addr,omitempty" yaml:"schemaRegistryAddr,omitempty"`
 		WithAVRO           bool   `json:"with_avro,omitempty" yaml:"withAVRO,omitempty"`
+		WithProtobuf       bool   `json:"with_protobuf,omitempty" yaml:"withProtobuf,omitempty"`
+		WithJSONSchema     bool   `json:"with_json_schema,omitempty" yaml:"withJSONSchema,omitempty"`
 		WithTLS            bool   `json:"with_tls,omitempty" yaml:"withTLS,omitempty"`
 		WithSASL           bool   `json:"with_sasl,omitempty" yaml:"withSASL,omitempty"`
 		WithSASLHandshaked bool   `json:"with_sasl_handshaked,omitempty" yaml:"withSASLHandshaked,omitempty"`
@@ -64,9 +96,16 @@ type (
 		// TLS Config
 		InsecureTLS bool `json:"insecure_tls,omitempty" yaml:"insecure_tls,omitempty"`
 
-		// ClientType must be "consumer" or "producer"
+		// ClientType must be "consumer", "producer" or "admin"
 		ClientType string `json:"client_type,omitempty" yaml:"clientType,omitempty"`
 
+		// Used when ClientType is admin
+		// Action must be "create_topic", "delete_topic", "describe_consumer_group" or "reset_offsets"
+		Action            string            `json:"action,omitempty" yaml:"action,omitempty"`
+		NumPartitions     int32             `json:"num_partitions,omitempty" yaml:"numPartitions,omitempty"`
+		ReplicationFactor int16             `json:"replication_factor,omitempty" yaml:"replicationFactor,omitempty"`
+		TopicConfig       map[string]string `json:"topic_config,omitempty" yaml:"topicConfig,omitempty"`
+
 		// Used when ClientType is consumer
 		GroupID string   `json:"group_id,omitempty" yaml:"groupID,omitempty"`
 		Topics  []string `json:"topics,omitempty" yaml:"topics,omitempty"`
@@ -83,6 +122,16 @@ type (
 
 		// KeyFilter determines the key to filter from
 		KeyFilter string `json:"key_filter,omitempty" yaml:"keyFilter,omitempty"`
+		// Match filters the messages on their headers or on their JSON value
+		Match *Match `json:"match,omitempty" yaml:"match,omitempty"`
+
+		// FromOffset and FromTimestamp read the partitions from the given position, without consumer group.
+		// FromOffset can also be -1 (newest) or -2 (oldest). FromTimestamp is a RFC3339 date or a unix timestamp in milliseconds.
+		FromOffset    *int64 `json:"from_offset,omitempty" yaml:"fromOffset,omitempty"`
+		FromTimestamp string `json:"from_timestamp,omitempty" yaml:"fromTimestamp,omitempty"`
+		// Partitions restricts the partitions read with FromOffset or FromTimestamp, all partitions by default.
+		// It is also used by the reset_offsets admin action.
+		Partitions []int32 `json:"partitions,omitempty" yaml:"partitions,omitempty"`
 
 		// Only one of JSON or Avro are currently supported
 		ConsumerEncoding string `json:"consumer_encoding,omitempty" yaml:"consumerEncoding,omitempty"`
@@ -102,14 +151,30 @@ type (
 
 	// Result represents a step result.
 	Result struct {
-		TimeSeconds  float64       `json:"timeseconds,omitempty" yaml:"timeSeconds,omitempty"`
-		Messages     []Message     `json:"messages,omitempty" yaml:"messages,omitempty"`
-		MessagesJSON []interface{} `json:"messagesjson,omitempty" yaml:"messagesJSON,omitempty"`
-		Err          string        `json:"err" yaml:"error"`
-	}
-	consumeFunc = func(message *sarama.ConsumerMessage) (Message, interface{}, error)
+		TimeSeconds  float64           `json:"timeseconds,omitempty" yaml:"timeSeconds,omitempty"`
+		Messages     []ConsumedMessage `json:"messages,omitempty" yaml:"messages,omitempty"`
+		MessagesJSON []interface{}     `json:"messagesjson,omitempty" yaml:"messagesJSON,omitempty"`
+		// Set by the describe_consumer_group and reset_offsets admin actions
+		GroupState string         `json:"group_state,omitempty" yaml:"groupState,omitempty"`
+		Members    int            `json:"members,omitempty" yaml:"members,omitempty"`
+		Offsets    []PartitionLag `json:"offsets,omitempty" yaml:"offsets,omitempty"`
+		TotalLag   int64          `json:"total_lag,omitempty" yaml:"totalLag,omitempty"`
+		Err        string         `json:"err" yaml:"error"`
+	}
+	consumeFunc = func(message *sarama.ConsumerMessage) (ConsumedMessage, MessageJSON, error)
 )
 
+// schemaFormats returns the number of schema formats enabled
+func (e Executor) schemaFormats() int {
+	formats := 0
+	for _, enabled := range []bool{e.WithAVRO, e.WithProtobuf, e.WithJSONSchema} {
+		if enabled {
+			formats++
+		}
+	}
+	return formats
+}
+
 // ZeroValueResult return an empty implementation of this executor result
 func (Executor) ZeroValueResult() interface{} {
 	return Result{}
@@ -128,8 +193,12 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 	}
 	start := time.Now()
 
+	if e.schemaFormats() > 1 {
+		return nil, fmt.Errorf("with_avro, with_protobuf and with_json_schema can't be used together")
+	}
+
 	result := Result{}
-	if e.WithAVRO && len(e.SchemaRegistryAddr) != 0 {
+	if (e.WithAVRO || e.WithProtobuf || e.WithJSONSchema) && len(e.SchemaRegistryAddr) != 0 {
 		var err error
 		e.schemaReg, err = NewSchemaRegistry(e.SchemaRegistryAddr)
 		if err != nil {
@@ -157,8 +226,12 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 		if err != nil {
 			result.Err = err.Error()
 		}
+	case "admin":
+		if err := e.runAdminAction(ctx, &result); err != nil {
+			result.Err = err.Error()
+		}
 	default:
-		return nil, fmt.Errorf("type must be a consumer or a producer")
+		return nil, fmt.Errorf("type must be a consumer, a producer or an admin")
 	}
 
 	elapsed := time.Since(start)
@@ -229,7 +302,12 @@ func (e Executor) getMessageValue(m *Message, workdir string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("can't get value: %w", err)
 	}
-	if !e.WithAVRO {
+	switch {
+	case e.WithProtobuf:
+		return e.getProtobufMessageValue(m, value, workdir)
+	case e.WithJSONSchema:
+		return e.getJSONSchemaMessageValue(m, value, workdir)
+	case !e.WithAVRO:
 		// This is test without AVRO - value is all we need to have
 		return value, nil
 	}
@@ -275,6 +353,81 @@ func (e Executor) getMessageValue(m *Message, workdir string) ([]byte, error) {
 	return encodedAvroMsg, nil
 }
 
+func (e Executor) getProtobufMessageValue(m *Message, value []byte, workdir string) ([]byte, error) {
+	schemaID, schema, err := e.getSchema(m, "PROTOBUF", workdir)
+	if err != nil {
+		return nil, err
+	}
+	value, err = yamlToJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	protoMsg, err := Convert2Protobuf(value, schema, m.ProtoMessage)
+	if err != nil {
+		return nil, fmt.Errorf("can't convert value 2 protobuf with schema: %w", err)
+	}
+	encodedMsg, err := CreateMessage(protoMsg, schemaID)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode protobuf message with schemaID: %s", err)
+	}
+	return encodedMsg, nil
+}
+
+func (e Executor) getJSONSchemaMessageValue(m *Message, value []byte, workdir string) ([]byte, error) {
+	schemaID, _, err := e.getSchema(m, "JSON", workdir)
+	if err != nil {
+		return nil, err
+	}
+	value, err = yamlToJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	encodedMsg, err := CreateMessage(value, schemaID)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode json message with schemaID: %s", err)
+	}
+	return encodedMsg, nil
+}
+
+// getSchema registers the schema file of the message if provided, or gets the latest schema of the topic from the Schema Registry
+func (e Executor) getSchema(m *Message, schemaType, workdir string) (int, string, error) {
+	if e.schemaReg == nil {
+		return 0, "", fmt.Errorf("schemaRegistryAddr is mandatory")
+	}
+	subject := fmt.Sprintf("%s-value", m.Topic) // Using topic name strategy
+	schemaFile := strings.TrimSpace(m.SchemaFile)
+	if len(schemaFile) == 0 {
+		schemaID, schema, err := e.schemaReg.GetLatestSchema(subject)
+		if err != nil {
+			return 0, "", fmt.Errorf("can't get latest schema for subject %s: %w", subject, err)
+		}
+		return schemaID, schema, nil
+	}
+	schemaPath := path.Join(workdir, schemaFile)
+	schemaBlob, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return 0, "", fmt.Errorf("can't read from %s: %w", schemaPath, err)
+	}
+	schemaID, err := e.schemaReg.RegisterNewSchemaWithType(subject, schemaType, string(schemaBlob))
+	if err != nil {
+		return 0, "", fmt.Errorf("can't register new schema in SchemaRegistry: %s", err)
+	}
+	return schemaID, string(schemaBlob), nil
+}
+
+// yamlToJSON converts a YAML or JSON value to JSON
+func yamlToJSON(value []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(value, &v); err != nil {
+		return nil, fmt.Errorf("value is neither YAML nor JSON: %w", err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("can't convert value to JSON: %w", err)
+	}
+	return b, nil
+}
+
 func (e Executor) getRAWMessageValue(m *Message, workdir string) ([]byte, error) {
 	// We have 2 fields Value and ValueFile from where we can get value, we prefer Value
 	if len(m.Value) != 0 {
@@ -290,7 +443,7 @@ func (e Executor) getRAWMessageValue(m *Message, workdir string) ([]byte, error)
 	return value, nil
 }
 
-func (e Executor) consumeMessages(ctx context.Context) ([]Message, []interface{}, error) {
+func (e Executor) consumeMessages(ctx context.Context) ([]ConsumedMessage, []interface{}, error) {
 	if len(e.Topics) == 0 {
 		return nil, nil, fmt.Errorf("You must provide topics")
 	}
@@ -303,12 +456,6 @@ func (e Executor) consumeMessages(ctx context.Context) ([]Message, []interface{}
 		config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	}
 
-	consumerGroup, err := sarama.NewConsumerGroup(e.Addrs, e.GroupID, config)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error instantiate consumer err: %w", err)
-	}
-	defer func() { _ = consumerGroup.Close() }()
-
 	timeout := time.Duration(e.Timeout) * time.Second
 	if e.WaitFor > 0 {
 		timeout = time.Duration(e.WaitFor) * time.Second
@@ -316,6 +463,33 @@ func (e Executor) consumeMessages(ctx context.Context) ([]Message, []interface{}
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	h := &handler{
+		withAVRO:       e.WithAVRO,
+		withProtobuf:   e.WithProtobuf,
+		withJSONSchema: e.WithJSONSchema,
+		messages:       []ConsumedMessage{},
+		messagesJSON:   []interface{}{},
+		markOffset:     e.MarkOffset,
+		messageLimit:   e.MessageLimit,
+		schemaReg:      e.schemaReg,
+		keyFilter:      e.KeyFilter,
+		match:          e.Match,
+		done:           make(chan struct{}),
+	}
+
+	if e.FromOffset != nil || e.FromTimestamp != "" {
+		if err := e.consumePartitions(ctx, config, h); err != nil {
+			return nil, nil, err
+		}
+		return h.messages, h.messagesJSON, nil
+	}
+
+	consumerGroup, err := sarama.NewConsumerGroup(e.Addrs, e.GroupID, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error instantiate consumer err: %w", err)
+	}
+	defer func() { _ = consumerGroup.Close() }()
+
 	// Track errors
 	go func() {
 		for err := range consumerGroup.Errors() {
@@ -327,17 +501,6 @@ func (e Executor) consumeMessages(ctx context.Context) ([]Message, []interface{}
 		}
 	}()
 
-	h := &handler{
-		withAVRO:     e.WithAVRO,
-		messages:     []Message{},
-		messagesJSON: []interface{}{},
-		markOffset:   e.MarkOffset,
-		messageLimit: e.MessageLimit,
-		schemaReg:    e.schemaReg,
-		keyFilter:    e.KeyFilter,
-		done:         make(chan struct{}),
-	}
-
 	cherr := make(chan error)
 	go func() {
 		cherr <- consumerGroup.Consume(ctx, e.Topics, h)
@@ -365,6 +528,114 @@ func (e Executor) consumeMessages(ctx context.Context) ([]Message, []interface{}
 	return h.messages, h.messagesJSON, nil
 }
 
+// consumePartitions reads the partitions of the topics from FromOffset or FromTimestamp, without consumer group
+func (e Executor) consumePartitions(ctx context.Context, config *sarama.Config, h *handler) error {
+	client, err := sarama.NewClient(e.Addrs, config)
+	if err != nil {
+		return fmt.Errorf("error instantiate client err: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return fmt.Errorf("error instantiate consumer err: %w", err)
+	}
+	defer func() { _ = consumer.Close() }()
+
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+		errs     []error
+	)
+	for _, topic := range e.Topics {
+		partitions := e.Partitions
+		if len(partitions) == 0 {
+			partitions, err = client.Partitions(topic)
+			if err != nil {
+				return fmt.Errorf("can't get partitions of topic %s: %w", topic, err)
+			}
+		}
+		for _, partition := range partitions {
+			offset, err := e.startOffset(client, topic, partition)
+			if err != nil {
+				return err
+			}
+			venom.Debug(ctx, "consuming topic %s partition %d from offset %d", topic, partition, offset)
+			pc, err := consumer.ConsumePartition(topic, partition, offset)
+			if err != nil {
+				return fmt.Errorf("can't consume topic %s partition %d from offset %d: %w", topic, partition, offset, err)
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { _ = pc.Close() }()
+				for {
+					select {
+					case message := <-pc.Messages():
+						limitReached, err := h.process(ctx, message)
+						if err != nil {
+							errMutex.Lock()
+							errs = append(errs, err)
+							errMutex.Unlock()
+							return
+						}
+						if limitReached {
+							return
+						}
+					case <-h.done:
+						return
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("error on consume: %w", errs[0])
+	}
+	select {
+	case <-h.done:
+		return nil
+	default:
+	}
+	if e.WaitFor > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		venom.Info(ctx, "wait ended")
+		return nil
+	}
+	return fmt.Errorf("kafka consumed failed: %w", ctx.Err())
+}
+
+// startOffset returns the offset of the partition to start reading from
+func (e Executor) startOffset(client sarama.Client, topic string, partition int32) (int64, error) {
+	if e.FromTimestamp == "" {
+		return *e.FromOffset, nil
+	}
+	ts, err := parseTimestamp(e.FromTimestamp)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := client.GetOffset(topic, partition, ts.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("can't get offset of topic %s partition %d at %s: %w", topic, partition, e.FromTimestamp, err)
+	}
+	return offset, nil
+}
+
+// parseTimestamp parses a RFC3339 date or a unix timestamp in milliseconds
+func parseTimestamp(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return ts, fmt.Errorf("invalid timestamp %q, must be a RFC3339 date or a unix timestamp in milliseconds", s)
+	}
+	return ts, nil
+}
+
 func (e Executor) getKafkaConfig() (*sarama.Config, error) {
 	config := sarama.NewConfig()
 	config.Net.TLS.Enable = e.WithTLS
@@ -391,16 +662,19 @@ func (e Executor) getKafkaConfig() (*sarama.Config, error) {
 
 // handler represents a Sarama consumer group consumer
 type handler struct {
-	withAVRO     bool
-	messages     []Message
-	messagesJSON []interface{}
-	markOffset   bool
-	messageLimit int
-	schemaReg    SchemaRegistry
-	keyFilter    string
-	mutex        sync.Mutex
-	done         chan struct{}
-	once         sync.Once
+	withAVRO       bool
+	withProtobuf   bool
+	withJSONSchema bool
+	messages       []ConsumedMessage
+	messagesJSON   []interface{}
+	markOffset     bool
+	messageLimit   int
+	schemaReg      SchemaRegistry
+	keyFilter      string
+	match          *Match
+	mutex          sync.Mutex
+	done           chan struct{}
+	once           sync.Once
 }
 
 // Setup is run at the beginning of a new session, before ConsumeClaim
@@ -424,33 +698,11 @@ func (h *handler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama
 			return nil
 		default:
 		}
-		consumeFunction := h.consumeJSON
-		if h.withAVRO {
-			consumeFunction = h.consumeAVRO
-		}
-		msg, msgJSON, err := consumeFunction(message)
+
+		limitReached, err := h.process(ctx, message)
 		if err != nil {
 			return err
 		}
-		// Pass filter
-		if h.keyFilter != "" && msg.Key != h.keyFilter {
-			venom.Info(ctx, "ignore message with key: %s", msg.Key)
-			continue
-		}
-
-		h.mutex.Lock()
-		// Check if message limit is hit *before* adding new message
-		messagesLen := len(h.messages)
-		if h.messageLimit > 0 && messagesLen >= h.messageLimit {
-			h.mutex.Unlock()
-			h.messageLimitReached(ctx)
-			return nil
-		}
-
-		h.messages = append(h.messages, msg)
-		h.messagesJSON = append(h.messagesJSON, msgJSON)
-		h.mutex.Unlock()
-		messagesLen++
 
 		if h.markOffset {
 			session.MarkMessage(message, "")
@@ -458,9 +710,7 @@ func (h *handler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama
 
 		session.MarkMessage(message, "delivered")
 
-		// Check if the message limit is hit
-		if h.messageLimit > 0 && messagesLen >= h.messageLimit {
-			h.messageLimitReached(ctx)
+		if limitReached {
 			return nil
 		}
 	}
@@ -468,6 +718,103 @@ func (h *handler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama
 	return nil
 }
 
+// process decodes and filters a message, then keeps it if the message limit is not hit.
+// It returns true when the message limit is reached.
+func (h *handler) process(ctx context.Context, message *sarama.ConsumerMessage) (bool, error) {
+	consumeFunction := h.consumeJSON
+	switch {
+	case h.withProtobuf:
+		consumeFunction = h.consumeProtobuf
+	case h.withJSONSchema:
+		consumeFunction = h.consumeJSONSchema
+	case h.withAVRO:
+		consumeFunction = h.consumeAVRO
+	}
+	msg, msgJSON, err := consumeFunction(message)
+	if err != nil {
+		return false, err
+	}
+	// Pass filter
+	if h.keyFilter != "" && msg.Key != h.keyFilter {
+		venom.Info(ctx, "ignore message with key: %s", msg.Key)
+		return false, nil
+	}
+	if h.match != nil {
+		if ok, reason := h.match.matches(msg, msgJSON); !ok {
+			venom.Info(ctx, "ignore message at offset %d of partition %d: %s", msg.Offset, msg.Partition, reason)
+			return false, nil
+		}
+	}
+
+	h.mutex.Lock()
+	// Check if message limit is hit *before* adding new message
+	messagesLen := len(h.messages)
+	if h.messageLimit > 0 && messagesLen >= h.messageLimit {
+		h.mutex.Unlock()
+		h.messageLimitReached(ctx)
+		return true, nil
+	}
+
+	h.messages = append(h.messages, msg)
+	h.messagesJSON = append(h.messagesJSON, msgJSON)
+	h.mutex.Unlock()
+	messagesLen++
+
+	// Check if the message limit is hit
+	if h.messageLimit > 0 && messagesLen >= h.messageLimit {
+		h.messageLimitReached(ctx)
+		return true, nil
+	}
+	return false, nil
+}
+
+// matches checks the headers and the JSON value of a message, it returns the reason of a mismatch
+func (m Match) matches(msg ConsumedMessage, msgJSON MessageJSON) (bool, string) {
+	for k, v := range m.Headers {
+		actual, ok := msg.Headers[k]
+		if !ok {
+			return false, fmt.Sprintf("header %q not found", k)
+		}
+		if actual != v {
+			return false, fmt.Sprintf("header %q is %q, expected %q", k, actual, v)
+		}
+	}
+	for path, v := range m.Value {
+		actual, ok := lookupJSONPath(msgJSON.Value, path)
+		if !ok {
+			return false, fmt.Sprintf("value %q not found", path)
+		}
+		if s := fmt.Sprint(actual); s != v {
+			return false, fmt.Sprintf("value %q is %q, expected %q", path, s, v)
+		}
+	}
+	return true, ""
+}
+
+// lookupJSONPath returns the element of a decoded JSON value at a dot-separated path, such as user.roles.0
+func lookupJSONPath(value interface{}, path string) (interface{}, bool) {
+	current := value
+	for _, key := range strings.Split(path, ".") {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			v, ok := c[key]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			current = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
 func (h *handler) messageLimitReached(ctx context.Context) {
 	venom.Info(ctx, "message limit reached")
 	// Signal to other handler goroutines that they should stop consuming messages.
@@ -476,45 +823,93 @@ func (h *handler) messageLimitReached(ctx context.Context) {
 	h.once.Do(func() { close(h.done) })
 }
 
-func (h *handler) consumeJSON(message *sarama.ConsumerMessage) (Message, interface{}, error) {
-	msg := Message{
-		Topic: message.Topic,
-		Key:   string(message.Key),
-		Value: string(message.Value),
-	}
-	msgJSON := MessageJSON{
-		Topic: message.Topic,
-	}
+func (h *handler) consumeJSON(message *sarama.ConsumerMessage) (ConsumedMessage, MessageJSON, error) {
+	msg, msgJSON := newConsumedMessage(message)
+	msg.Value = string(message.Value)
 	convertFromMessage2JSON(&msg, &msgJSON)
 
 	return msg, msgJSON, nil
 }
 
-func (h *handler) consumeAVRO(message *sarama.ConsumerMessage) (Message, interface{}, error) {
-	msg := Message{
-		Topic: message.Topic,
-		Key:   string(message.Key),
-	}
-	msgJSON := MessageJSON{
-		Topic: message.Topic,
-	}
+func (h *handler) consumeAVRO(message *sarama.ConsumerMessage) (ConsumedMessage, MessageJSON, error) {
+	msg, msgJSON := newConsumedMessage(message)
 	// 1. Get Schema ID
 	avroMsg, schemaID := GetMessageAvroID(message.Value)
 	schema, err := h.schemaReg.GetSchemaByID(schemaID)
 	if err != nil {
-		return msg, nil, fmt.Errorf("can't get Schema with ID %d: %w", schemaID, err)
+		return msg, msgJSON, fmt.Errorf("can't get Schema with ID %d: %w", schemaID, err)
 	}
 	// 2. Decode Avro Msg
 	value, err := ConvertFromAvro(avroMsg, schema)
 	if err != nil {
-		return msg, nil, fmt.Errorf("can't get value from Avro message: %w", err)
+		return msg, msgJSON, fmt.Errorf("can't get value from Avro message: %w", err)
+	}
+	msg.Value = value
+	convertFromMessage2JSON(&msg, &msgJSON)
+	return msg, msgJSON, nil
+}
+
+func (h *handler) consumeProtobuf(message *sarama.ConsumerMessage) (ConsumedMessage, MessageJSON, error) {
+	msg, msgJSON := newConsumedMessage(message)
+	if len(message.Value) <= int(schemaIDSize) {
+		return msg, msgJSON, fmt.Errorf("message at offset %d is not encoded with a schema", message.Offset)
+	}
+	// 1. Get Schema ID
+	protoMsg, schemaID := GetMessageAvroID(message.Value)
+	schema, err := h.schemaReg.GetSchemaByID(schemaID)
+	if err != nil {
+		return msg, msgJSON, fmt.Errorf("can't get Schema with ID %d: %w", schemaID, err)
+	}
+	// 2. Decode Protobuf Msg
+	value, err := ConvertFromProtobuf(protoMsg, schema)
+	if err != nil {
+		return msg, msgJSON, fmt.Errorf("can't get value from Protobuf message: %w", err)
 	}
 	msg.Value = value
 	convertFromMessage2JSON(&msg, &msgJSON)
 	return msg, msgJSON, nil
 }
 
-func convertFromMessage2JSON(message *Message, msgJSON *MessageJSON) {
+func (h *handler) consumeJSONSchema(message *sarama.ConsumerMessage) (ConsumedMessage, MessageJSON, error) {
+	msg, msgJSON := newConsumedMessage(message)
+	if len(message.Value) <= int(schemaIDSize) {
+		return msg, msgJSON, fmt.Errorf("message at offset %d is not encoded with a schema", message.Offset)
+	}
+	// The value follows the schema ID, the schema is only needed by the producer
+	value, _ := GetMessageAvroID(message.Value)
+	msg.Value = string(value)
+	convertFromMessage2JSON(&msg, &msgJSON)
+	return msg, msgJSON, nil
+}
+
+// newConsumedMessage initializes the messages with the metadata of the consumed message, without its value
+func newConsumedMessage(message *sarama.ConsumerMessage) (ConsumedMessage, MessageJSON) {
+	msg := ConsumedMessage{
+		Topic:     message.Topic,
+		Key:       string(message.Key),
+		Partition: message.Partition,
+		Offset:    message.Offset,
+	}
+	if len(message.Headers) > 0 {
+		msg.Headers = make(map[string]string, len(message.Headers))
+		for _, h := range message.Headers {
+			msg.Headers[string(h.Key)] = string(h.Value)
+		}
+	}
+	if !message.Timestamp.IsZero() {
+		msg.Timestamp = message.Timestamp.Format(time.RFC3339Nano)
+	}
+	msgJSON := MessageJSON{
+		Topic:     message.Topic,
+		Headers:   msg.Headers,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+	}
+	return msg, msgJSON
+}
+
+func convertFromMessage2JSON(message *ConsumedMessage, msgJSON *MessageJSON) {
 	// unmarshall the message.Value
 	listMessageJSON := []MessageJSON{}
 	// try to unmarshall into an array
@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+func TestMatch_matches(t *testing.T) {
+	msg := ConsumedMessage{Headers: map[string]string{"event": "created"}, Value: `{"user":{"name":"foo","roles":["admin"],"age":42}}`}
+	msgJSON := MessageJSON{}
+	convertFromMessage2JSON(&msg, &msgJSON)
+
+	ok, _ := Match{
+		Headers: map[string]string{"event": "created"},
+		Value:   map[string]string{"user.name": "foo", "user.roles.0": "admin", "user.age": "42"},
+	}.matches(msg, msgJSON)
+	assert.True(t, ok)
+
+	ok, reason := Match{Headers: map[string]string{"event": "deleted"}}.matches(msg, msgJSON)
+	assert.False(t, ok)
+	assert.Contains(t, reason, "event")
+
+	ok, reason = Match{Value: map[string]string{"user.roles.1": "admin"}}.matches(msg, msgJSON)
+	assert.False(t, ok)
+	assert.Contains(t, reason, "not found")
+
+	ok, _ = Match{Value: map[string]string{"user.name": "bar"}}.matches(msg, msgJSON)
+	assert.False(t, ok)
+}
+
+func TestParseTimestamp(t *testing.T) {
+	ts, err := parseTimestamp("1672531200000")
+	require.NoError(t, err)
+	assert.True(t, ts.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	ts, err = parseTimestamp("2023-01-01T00:00:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1672531200000), ts.UnixMilli())
+
+	_, err = parseTimestamp("yesterday")
+	assert.Error(t, err)
+}
+
+const testProtobufSchema = `syntax = "proto3";
+package venom.test;
+
+message User {
+  string name = 1;
+  int32 age = 2;
+  message Address {
+    string city = 1;
+  }
+}
+`
+
+func TestConvert2Protobuf(t *testing.T) {
+	b, err := Convert2Protobuf([]byte(`{"name":"foo","age":42}`), testProtobufSchema, "")
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), b[0])
+	value, err := ConvertFromProtobuf(b, testProtobufSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"foo","age":42}`, value)
+
+	b, err = Convert2Protobuf([]byte(`{"city":"Paris"}`), testProtobufSchema, "User.Address")
+	require.NoError(t, err)
+	indexes, _, err := decodeMessageIndexes(b)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 0}, indexes)
+	value, err = ConvertFromProtobuf(b, testProtobufSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"city":"Paris"}`, value)
+
+	_, err = Convert2Protobuf([]byte(`{"unknown":1}`), testProtobufSchema, "venom.test.User")
+	assert.Error(t, err)
+	_, err = Convert2Protobuf([]byte(`{}`), testProtobufSchema, "Unknown")
+	assert.Error(t, err)
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	b, err := yamlToJSON([]byte("name: foo\nage: 42\n"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"foo","age":42}`, string(b))
+}
+
+func TestSchemaRegistry_RegisterNewSchemaWithType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/subjects/topic-value/versions", r.URL.Path)
+		body := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "PROTOBUF", body["schemaType"])
+		_, _ = w.Write([]byte(`{"id":12}`))
+	}))
+	defer srv.Close()
+
+	reg, err := NewSchemaRegistry(srv.URL)
+	require.NoError(t, err)
+	id, err := reg.RegisterNewSchemaWithType("topic-value", "PROTOBUF", testProtobufSchema)
+	require.NoError(t, err)
+	assert.Equal(t, 12, id)
+}
+
+func TestSchemaRegistry_GetSchemaByID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+	}))
+	defer srv.Close()
+
+	reg, err := NewSchemaRegistry(srv.URL)
+	require.NoError(t, err)
+	_, err = reg.GetSchemaByID(42)
+	assert.ErrorContains(t, err, "could not get schema id 42 from schema registry")
+}
+
+func TestExecutor_Run_SchemaFormats(t *testing.T) {
+	venom.InitTestLogger(t)
+	_, err := Executor{}.Run(context.Background(), venom.TestStep{"clientType": "producer", "withAVRO": true, "withProtobuf": true})
+	assert.EqualError(t, err, "with_avro, with_protobuf and with_json_schema can't be used together")
+}
@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const protobufSchemaFilename = "schema.proto"
+
+// parseProtobufSchema parses a Protobuf schema, as stored in the schema registry.
+// Only the well-known types can be imported.
+func parseProtobufSchema(schema string) (protoreflect.FileDescriptor, error) {
+	parser := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(map[string]string{protobufSchemaFilename: schema}),
+	}
+	fds, err := parser.ParseFiles(protobufSchemaFilename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Protobuf schema: %w", err)
+	}
+	return fds[0].UnwrapFile(), nil
+}
+
+// findProtobufMessage returns the message named messageName in the schema with its indexes, as described in
+// https://docs.confluent.io/platform/current/schema-registry/fundamentals/serdes-develop/index.html#wire-format
+// The first message of the schema is used when messageName is empty.
+func findProtobufMessage(fd protoreflect.FileDescriptor, messageName string) (protoreflect.MessageDescriptor, []int, error) {
+	if fd.Messages().Len() == 0 {
+		return nil, nil, fmt.Errorf("no message in Protobuf schema")
+	}
+	if messageName == "" {
+		return fd.Messages().Get(0), []int{0}, nil
+	}
+
+	md := lookupProtobufMessage(fd.Messages(), messageName)
+	if md == nil {
+		return nil, nil, fmt.Errorf("message %s not found in Protobuf schema", messageName)
+	}
+
+	indexes := []int{}
+	for d := protoreflect.Descriptor(md); d != fd; d = d.Parent() {
+		indexes = append([]int{d.Index()}, indexes...)
+	}
+	return md, indexes, nil
+}
+
+// lookupProtobufMessage looks for a message by its full name, or by its name relative to the package, in messages and their nested messages
+func lookupProtobufMessage(messages protoreflect.MessageDescriptors, name string) protoreflect.MessageDescriptor {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if string(md.FullName()) == name || string(md.FullName()) == string(md.ParentFile().Package())+"."+name {
+			return md
+		}
+		if nested := lookupProtobufMessage(md.Messages(), name); nested != nil {
+			return nested
+		}
+	}
+	return nil
+}
+
+// encodeMessageIndexes encodes the indexes of a message as zigzag varints, prefixed by their count
+func encodeMessageIndexes(indexes []int) []byte {
+	// the first message is encoded as a single 0
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return []byte{0}
+	}
+	b := binary.AppendVarint(nil, int64(len(indexes)))
+	for _, i := range indexes {
+		b = binary.AppendVarint(b, int64(i))
+	}
+	return b
+}
+
+// decodeMessageIndexes decodes the indexes of a message, it returns them with the remaining bytes
+func decodeMessageIndexes(b []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(b)
+	if n <= 0 || count < 0 {
+		return nil, nil, fmt.Errorf("invalid message indexes")
+	}
+	b = b[n:]
+	if count == 0 {
+		return []int{0}, b, nil
+	}
+	indexes := make([]int, 0, count)
+	for i := int64(0); i < count; i++ {
+		index, n := binary.Varint(b)
+		if n <= 0 || index < 0 {
+			return nil, nil, fmt.Errorf("invalid message indexes")
+		}
+		indexes = append(indexes, int(index))
+		b = b[n:]
+	}
+	return indexes, b, nil
+}
+
+// Convert2Protobuf will convert a JSON value to a Protobuf message prefixed by its indexes
+func Convert2Protobuf(value []byte, schema, messageName string) ([]byte, error) {
+	fd, err := parseProtobufSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	md, indexes, err := findProtobufMessage(fd, messageName)
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(md)
+	if err := protojson.Unmarshal(value, msg); err != nil {
+		return nil, fmt.Errorf("failed to convert value %s to Protobuf message %s: %w", value, md.FullName(), err)
+	}
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Protobuf message %s: %w", md.FullName(), err)
+	}
+	return append(encodeMessageIndexes(indexes), b...), nil
+}
+
+// ConvertFromProtobuf will convert a Protobuf message prefixed by its indexes to JSON with help of schema
+func ConvertFromProtobuf(b []byte, schema string) (string, error) {
+	fd, err := parseProtobufSchema(schema)
+	if err != nil {
+		return "", err
+	}
+	indexes, b, err := decodeMessageIndexes(b)
+	if err != nil {
+		return "", err
+	}
+	messages := fd.Messages()
+	var md protoreflect.MessageDescriptor
+	for _, i := range indexes {
+		if i >= messages.Len() {
+			return "", fmt.Errorf("message indexes %v not found in Protobuf schema", indexes)
+		}
+		md = messages.Get(i)
+		messages = md.Messages()
+	}
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return "", fmt.Errorf("failed to decode Protobuf message %s: %w", md.FullName(), err)
+	}
+	textual, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert Protobuf message %s to JSON: %w", md.FullName(), err)
+	}
+	return string(textual), nil
+}
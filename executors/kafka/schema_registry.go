@@ -1,8 +1,13 @@
 package kafka
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	schemaregistry "github.com/landoop/schema-registry"
@@ -13,11 +18,15 @@ type (
 	SchemaRegistry interface {
 		GetSchemaByID(id int) (string, error)
 		RegisterNewSchema(subject, schema string) (int, error)
+		// RegisterNewSchemaWithType registers a schema of another type than Avro, such as PROTOBUF or JSON
+		RegisterNewSchemaWithType(subject, schemaType, schema string) (int, error)
 		GetLatestSchema(subject string) (int, string, error)
 	}
 
 	client struct {
-		client *schemaregistry.Client
+		client     *schemaregistry.Client
+		host       string
+		httpClient *http.Client
 	}
 )
 
@@ -34,7 +43,9 @@ func NewWithClient(schemaRegistryHost string, httpClient *http.Client) (SchemaRe
 		return nil, fmt.Errorf("failed to connect to schema registry: %w", err)
 	}
 	return &client{
-		client: schemaRegistryClient,
+		client:     schemaRegistryClient,
+		host:       strings.TrimSuffix(schemaRegistryHost, "/"),
+		httpClient: httpClient,
 	}, nil
 }
 
@@ -42,7 +53,7 @@ func NewWithClient(schemaRegistryHost string, httpClient *http.Client) (SchemaRe
 func (c client) GetSchemaByID(id int) (string, error) {
 	schema, err := c.client.GetSchemaByID(id)
 	if err != nil {
-		return "", fmt.Errorf("could not get schema id %q from schema registry: %w", id, err)
+		return "", fmt.Errorf("could not get schema id %d from schema registry: %w", id, err)
 	}
 
 	return schema, nil
@@ -57,6 +68,40 @@ func (c client) RegisterNewSchema(subject, schema string) (int, error) {
 	return schemaID, nil
 }
 
+// RegisterNewSchemaWithType either register a new schema of the given type and return the ID or get the ID of an already created schema.
+// The schema registry client only handles Avro schemas, so the request is sent directly.
+func (c client) RegisterNewSchemaWithType(subject, schemaType, schema string) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema, "schemaType": schemaType})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/subjects/%s/versions", c.host, url.PathEscape(subject)), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register new %s schema: %w", schemaType, err)
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return 0, fmt.Errorf("failed to register new %s schema: %s: %s", schemaType, resp.Status, content)
+	}
+	var res struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(content, &res); err != nil {
+		return 0, fmt.Errorf("failed to read registered schema ID: %w", err)
+	}
+	return res.ID, nil
+}
+
 // GetLatestSchema gets latest schema identifier from the given subject.
 func (c client) GetLatestSchema(subject string) (int, string, error) {
 	schema, err := c.client.GetLatestSchema(subject)
@@ -0,0 +1,1080 @@
+package mailserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ovh/venom"
+	"github.com/ovh/venom/executors/imap"
+)
+
+const (
+	imapCapabilities = "IMAP4rev1 UIDPLUS MOVE"
+	imapFlags        = `\Answered \Flagged \Deleted \Seen \Draft`
+	imapDateLayout   = "02-Jan-2006 15:04:05 -0700"
+	imapSearchLayout = "2-Jan-2006"
+)
+
+// imapSyntaxError is returned for a command which can't be parsed
+type imapSyntaxError string
+
+func (e imapSyntaxError) Error() string {
+	return string(e)
+}
+
+// imapSession is the state of an IMAP connection
+type imapSession struct {
+	ctx           context.Context
+	s             *server
+	r             *bufio.Reader
+	w             *bufio.Writer
+	authenticated bool
+	selected      *mailbox
+	readOnly      bool
+	logout        bool
+}
+
+// serveIMAP serves the subset of IMAP4rev1 used by mail clients to read, flag, move and delete the mails
+func (s *server) serveIMAP(ctx context.Context, c net.Conn) {
+	session := &imapSession{ctx: ctx, s: s, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	session.untagged("OK [CAPABILITY %s] venom mail server ready", imapCapabilities)
+	for !session.logout {
+		if err := session.w.Flush(); err != nil {
+			return
+		}
+		tag, args, err := session.readCommand()
+		if _, ok := err.(imapSyntaxError); ok {
+			session.tagged(tag, "BAD", err.Error())
+			continue
+		} else if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			session.tagged(tag, "BAD", "Missing command")
+			continue
+		}
+
+		name, uid := strings.ToUpper(toString(args[0])), false
+		args = args[1:]
+		if name == "UID" && len(args) > 0 {
+			name, uid = strings.ToUpper(toString(args[0])), true
+			args = args[1:]
+		}
+		venom.Debug(ctx, "mail server %q received IMAP command %s", s.name, name)
+		status, text := session.handle(name, uid, args)
+		session.tagged(tag, status, text)
+	}
+	session.w.Flush() // nolint
+}
+
+func (session *imapSession) untagged(format string, args ...interface{}) {
+	fmt.Fprintf(session.w, "* "+format+"\r\n", args...)
+}
+
+func (session *imapSession) tagged(tag, status, text string) {
+	if tag == "" {
+		tag = "*"
+	}
+	fmt.Fprintf(session.w, "%s %s %s\r\n", tag, status, text)
+}
+
+// handle executes the command and returns the status and the text of the tagged response
+func (session *imapSession) handle(name string, uid bool, args []interface{}) (string, string) {
+	switch name {
+	case "CAPABILITY":
+		session.untagged("CAPABILITY %s", imapCapabilities)
+		return "OK", "CAPABILITY completed"
+	case "NOOP", "CHECK":
+		return "OK", name + " completed"
+	case "LOGOUT":
+		session.untagged("BYE venom mail server logging out")
+		session.logout = true
+		return "OK", "LOGOUT completed"
+	case "LOGIN":
+		if session.authenticated {
+			return "BAD", "Already authenticated"
+		}
+		if len(args) != 2 {
+			return "BAD", "Syntax: LOGIN user password"
+		}
+		if !session.s.authenticate(toString(args[0]), toString(args[1])) {
+			return "NO", "[AUTHENTICATIONFAILED] Invalid credentials"
+		}
+		session.authenticated = true
+		return "OK", fmt.Sprintf("[CAPABILITY %s] LOGIN completed", imapCapabilities)
+	}
+	if !session.authenticated {
+		return "BAD", "Command not allowed before LOGIN"
+	}
+
+	st := session.s.store
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	switch name {
+	case "SELECT", "EXAMINE":
+		return session.selectMailbox(name, args)
+	case "CREATE", "DELETE":
+		if len(args) != 1 {
+			return "BAD", "Syntax: " + name + " mailbox"
+		}
+		mboxName := toString(args[0])
+		if name == "CREATE" {
+			if !st.create(mboxName) {
+				return "NO", "Mailbox already exists"
+			}
+			return "OK", "CREATE completed"
+		}
+		mbox := st.get(mboxName)
+		if mbox == nil || mbox.name == inbox {
+			return "NO", "Mailbox can't be deleted"
+		}
+		delete(st.mailboxes, mbox.name)
+		return "OK", "DELETE completed"
+	case "LIST", "LSUB":
+		return session.list(name, args)
+	case "STATUS":
+		return session.status(args)
+	case "APPEND":
+		return session.append(args)
+	}
+
+	if session.selected == nil || st.mailboxes[session.selected.name] != session.selected {
+		session.selected = nil
+		return "BAD", "No mailbox selected"
+	}
+	switch name {
+	case "CLOSE", "UNSELECT":
+		if name == "CLOSE" && !session.readOnly {
+			session.expunge(func(msg *message) bool { return msg.hasFlag(`\Deleted`) }, true)
+		}
+		session.selected = nil
+		return "OK", name + " completed"
+	case "EXPUNGE":
+		if session.readOnly {
+			return "NO", "Mailbox is read-only"
+		}
+		inSet := func(*message) bool { return true }
+		if uid {
+			if len(args) != 1 {
+				return "BAD", "Syntax: UID EXPUNGE sequence-set"
+			}
+			set, err := parseSeqSet(toString(args[0]))
+			if err != nil {
+				return "BAD", err.Error()
+			}
+			max := session.selected.maxUID()
+			inSet = func(msg *message) bool { return set.contains(msg.uid, max) }
+		}
+		session.expunge(func(msg *message) bool { return msg.hasFlag(`\Deleted`) && inSet(msg) }, false)
+		return "OK", "EXPUNGE completed"
+	case "SEARCH":
+		return session.search(uid, args)
+	case "FETCH":
+		return session.fetch(uid, args)
+	case "STORE":
+		return session.storeFlags(uid, args)
+	case "COPY", "MOVE":
+		return session.copy(name, uid, args)
+	}
+	return "BAD", fmt.Sprintf("Command %s not implemented", name)
+}
+
+func (session *imapSession) selectMailbox(name string, args []interface{}) (string, string) {
+	if len(args) != 1 {
+		return "BAD", "Syntax: " + name + " mailbox"
+	}
+	session.selected = nil
+	mbox := session.s.store.get(toString(args[0]))
+	if mbox == nil {
+		return "NO", "Mailbox doesn't exist"
+	}
+	session.selected, session.readOnly = mbox, name == "EXAMINE"
+
+	session.untagged("FLAGS (%s)", imapFlags)
+	session.untagged(`OK [PERMANENTFLAGS (%s \*)] Flags permitted`, imapFlags)
+	session.untagged("%d EXISTS", len(mbox.messages))
+	session.untagged("0 RECENT")
+	for i, msg := range mbox.messages {
+		if !msg.hasFlag(`\Seen`) {
+			session.untagged("OK [UNSEEN %d] First unseen", i+1)
+			break
+		}
+	}
+	session.untagged("OK [UIDVALIDITY %d] UIDs valid", mbox.uidValidity)
+	session.untagged("OK [UIDNEXT %d] Predicted next UID", mbox.uidNext)
+	if session.readOnly {
+		return "OK", "[READ-ONLY] EXAMINE completed"
+	}
+	return "OK", "[READ-WRITE] SELECT completed"
+}
+
+func (session *imapSession) list(name string, args []interface{}) (string, string) {
+	if len(args) != 2 {
+		return "BAD", "Syntax: " + name + " reference mailbox"
+	}
+	pattern := toString(args[0]) + toString(args[1])
+	if pattern == "" {
+		session.untagged(`%s (\Noselect) "/" ""`, name)
+		return "OK", name + " completed"
+	}
+	// * matches any name, % matches a name without hierarchy delimiter
+	expr := strings.NewReplacer(`\*`, `.*`, `%`, `[^/]*`).Replace(regexp.QuoteMeta(pattern))
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return "BAD", err.Error()
+	}
+	for _, mboxName := range session.s.store.names() {
+		if re.MatchString(mboxName) {
+			session.untagged(`%s (\HasNoChildren) "/" %s`, name, imapString(mboxName))
+		}
+	}
+	return "OK", name + " completed"
+}
+
+func (session *imapSession) status(args []interface{}) (string, string) {
+	if len(args) != 2 {
+		return "BAD", "Syntax: STATUS mailbox (items)"
+	}
+	mbox := session.s.store.get(toString(args[0]))
+	if mbox == nil {
+		return "NO", "Mailbox doesn't exist"
+	}
+	items := []string{}
+	for _, item := range toList(args[1]) {
+		var value uint32
+		switch strings.ToUpper(toString(item)) {
+		case "MESSAGES":
+			value = uint32(len(mbox.messages))
+		case "RECENT":
+			value = 0
+		case "UIDNEXT":
+			value = mbox.uidNext
+		case "UIDVALIDITY":
+			value = mbox.uidValidity
+		case "UNSEEN":
+			for _, msg := range mbox.messages {
+				if !msg.hasFlag(`\Seen`) {
+					value++
+				}
+			}
+		default:
+			return "BAD", fmt.Sprintf("Unknown status item %s", toString(item))
+		}
+		items = append(items, fmt.Sprintf("%s %d", strings.ToUpper(toString(item)), value))
+	}
+	session.untagged("STATUS %s (%s)", imapString(mbox.name), strings.Join(items, " "))
+	return "OK", "STATUS completed"
+}
+
+// append stores a mail, with its optional flags and internal date
+func (session *imapSession) append(args []interface{}) (string, string) {
+	if len(args) < 2 {
+		return "BAD", "Syntax: APPEND mailbox [(flags)] [date] message"
+	}
+	msg := &message{date: time.Now(), raw: []byte(toString(args[len(args)-1]))}
+	for _, arg := range args[1 : len(args)-1] {
+		if flags, ok := arg.([]interface{}); ok {
+			for _, f := range flags {
+				msg.flags = append(msg.flags, toString(f))
+			}
+			continue
+		}
+		date, err := time.Parse(imapDateLayout, strings.TrimSpace(toString(arg)))
+		if err != nil {
+			return "BAD", fmt.Sprintf("Invalid date %q", toString(arg))
+		}
+		msg.date = date
+	}
+	mbox := session.s.store.get(toString(args[0]))
+	if mbox == nil {
+		return "NO", "[TRYCREATE] Mailbox doesn't exist"
+	}
+	uid := mbox.add(msg)
+	return "OK", fmt.Sprintf("[APPENDUID %d %d] APPEND completed", mbox.uidValidity, uid)
+}
+
+// expunge removes the messages of the selected mailbox matched by remove
+func (session *imapSession) expunge(remove func(*message) bool, silent bool) {
+	mbox := session.selected
+	kept := make([]*message, 0, len(mbox.messages))
+	for _, msg := range mbox.messages {
+		if remove(msg) {
+			// the sequence number of the message is shifted by the messages already removed
+			if !silent {
+				session.untagged("%d EXPUNGE", len(kept)+1)
+			}
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	mbox.messages = kept
+}
+
+// messages returns the indexes of the messages of the selected mailbox matched by a sequence set, of UIDs if uid is true
+func (session *imapSession) messages(uid bool, arg interface{}) ([]int, error) {
+	set, err := parseSeqSet(toString(arg))
+	if err != nil {
+		return nil, err
+	}
+	mbox := session.selected
+	max := uint32(len(mbox.messages))
+	if uid {
+		max = mbox.maxUID()
+	}
+	indexes := []int{}
+	for i, msg := range mbox.messages {
+		n := uint32(i + 1)
+		if uid {
+			n = msg.uid
+		}
+		if set.contains(n, max) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+func (session *imapSession) fetch(uid bool, args []interface{}) (string, string) {
+	if len(args) != 2 {
+		return "BAD", "Syntax: FETCH sequence-set items"
+	}
+	items, err := parseFetchItems(args[1])
+	if err != nil {
+		return "BAD", err.Error()
+	}
+	if uid && !containsFetchItem(items, "UID") {
+		items = append([]fetchItem{{name: "UID"}}, items...)
+	}
+	indexes, err := session.messages(uid, args[0])
+	if err != nil {
+		return "BAD", err.Error()
+	}
+
+	for _, i := range indexes {
+		msg := session.selected.messages[i]
+		// reading the text of the message without PEEK marks it as seen
+		for _, item := range items {
+			if item.marksSeen() && !session.readOnly && !msg.hasFlag(`\Seen`) {
+				msg.flags = append(msg.flags, `\Seen`)
+			}
+		}
+		values := make([]string, len(items))
+		for j, item := range items {
+			values[j] = msg.fetch(item)
+		}
+		session.untagged("%d FETCH (%s)", i+1, strings.Join(values, " "))
+	}
+	return "OK", "FETCH completed"
+}
+
+// storeFlags replaces, adds or removes flags of the messages
+func (session *imapSession) storeFlags(uid bool, args []interface{}) (string, string) {
+	if len(args) < 3 {
+		return "BAD", "Syntax: STORE sequence-set [+|-]FLAGS[.SILENT] (flags)"
+	}
+	if session.readOnly {
+		return "NO", "Mailbox is read-only"
+	}
+	item := strings.ToUpper(toString(args[1]))
+	silent := strings.HasSuffix(item, ".SILENT")
+	item = strings.TrimSuffix(item, ".SILENT")
+	if item != "FLAGS" && item != "+FLAGS" && item != "-FLAGS" {
+		return "BAD", fmt.Sprintf("Unknown store item %s", toString(args[1]))
+	}
+	flagArgs := args[2:]
+	if list, ok := args[2].([]interface{}); ok {
+		flagArgs = list
+	}
+	flags := make([]string, len(flagArgs))
+	for i, f := range flagArgs {
+		flags[i] = toString(f)
+	}
+	indexes, err := session.messages(uid, args[0])
+	if err != nil {
+		return "BAD", err.Error()
+	}
+
+	for _, i := range indexes {
+		msg := session.selected.messages[i]
+		switch item {
+		case "FLAGS":
+			msg.flags = append([]string{}, flags...)
+		case "+FLAGS":
+			for _, f := range flags {
+				if !msg.hasFlag(f) {
+					msg.flags = append(msg.flags, f)
+				}
+			}
+		case "-FLAGS":
+			kept := []string{}
+			for _, existing := range msg.flags {
+				removed := false
+				for _, f := range flags {
+					removed = removed || strings.EqualFold(existing, f)
+				}
+				if !removed {
+					kept = append(kept, existing)
+				}
+			}
+			msg.flags = kept
+		}
+		if !silent {
+			values := []string{msg.fetch(fetchItem{name: "FLAGS"})}
+			if uid {
+				values = append([]string{msg.fetch(fetchItem{name: "UID"})}, values...)
+			}
+			session.untagged("%d FETCH (%s)", i+1, strings.Join(values, " "))
+		}
+	}
+	return "OK", "STORE completed"
+}
+
+// copy copies the messages to another mailbox, they are then removed from the selected mailbox by MOVE
+func (session *imapSession) copy(name string, uid bool, args []interface{}) (string, string) {
+	if len(args) != 2 {
+		return "BAD", "Syntax: " + name + " sequence-set mailbox"
+	}
+	if name == "MOVE" && session.readOnly {
+		return "NO", "Mailbox is read-only"
+	}
+	indexes, err := session.messages(uid, args[0])
+	if err != nil {
+		return "BAD", err.Error()
+	}
+	dest := session.s.store.get(toString(args[1]))
+	if dest == nil {
+		return "NO", "[TRYCREATE] Mailbox doesn't exist"
+	}
+
+	moved := map[*message]bool{}
+	var sourceUIDs, destUIDs []string
+	for _, i := range indexes {
+		msg := session.selected.messages[i]
+		moved[msg] = true
+		sourceUIDs = append(sourceUIDs, strconv.FormatUint(uint64(msg.uid), 10))
+		destUIDs = append(destUIDs, strconv.FormatUint(uint64(dest.add(msg)), 10))
+	}
+	copyUID := fmt.Sprintf("[COPYUID %d %s %s]", dest.uidValidity, strings.Join(sourceUIDs, ","), strings.Join(destUIDs, ","))
+	if len(indexes) == 0 {
+		copyUID = ""
+	}
+	if name == "COPY" {
+		return "OK", strings.TrimSpace(copyUID + " COPY completed")
+	}
+	if copyUID != "" {
+		session.untagged("OK %s Moved", copyUID)
+	}
+	session.expunge(func(msg *message) bool { return moved[msg] }, false)
+	return "OK", "MOVE completed"
+}
+
+func (session *imapSession) search(uid bool, args []interface{}) (string, string) {
+	if len(args) >= 2 && strings.EqualFold(toString(args[0]), "CHARSET") {
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		return "BAD", "Syntax: SEARCH criteria"
+	}
+	mbox := session.selected
+	criteria, err := session.searchCriteria(args, mbox)
+	if err != nil {
+		return "BAD", err.Error()
+	}
+
+	found := []string{}
+	for i, msg := range mbox.messages {
+		if criteria(i, msg) {
+			n := uint32(i + 1)
+			if uid {
+				n = msg.uid
+			}
+			found = append(found, strconv.FormatUint(uint64(n), 10))
+		}
+	}
+	session.untagged("%s", strings.TrimSpace("SEARCH "+strings.Join(found, " ")))
+	return "OK", "SEARCH completed"
+}
+
+// searchCriteria returns a criteria matching all the search keys
+func (session *imapSession) searchCriteria(args []interface{}, mbox *mailbox) (func(int, *message) bool, error) {
+	criteria := []func(int, *message) bool{}
+	for len(args) > 0 {
+		var (
+			criterion func(int, *message) bool
+			err       error
+		)
+		criterion, args, err = session.searchKey(args, mbox)
+		if err != nil {
+			return nil, err
+		}
+		criteria = append(criteria, criterion)
+	}
+	return func(i int, msg *message) bool {
+		for _, criterion := range criteria {
+			if !criterion(i, msg) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// searchKey returns the criteria of the first search key of args, and the remaining args
+func (session *imapSession) searchKey(args []interface{}, mbox *mailbox) (func(int, *message) bool, []interface{}, error) {
+	if list, ok := args[0].([]interface{}); ok {
+		criteria, err := session.searchCriteria(list, mbox)
+		return criteria, args[1:], err
+	}
+	key := strings.ToUpper(toString(args[0]))
+	args = args[1:]
+	// operand returns the n-th operand of the key
+	operand := func(n int) (string, error) {
+		if len(args) < n {
+			return "", fmt.Errorf("missing operand for search key %s", key)
+		}
+		return toString(args[n-1]), nil
+	}
+	hasFlag := func(flag string) func(int, *message) bool {
+		return func(_ int, msg *message) bool { return msg.hasFlag(flag) }
+	}
+	not := func(criterion func(int, *message) bool) func(int, *message) bool {
+		return func(i int, msg *message) bool { return !criterion(i, msg) }
+	}
+
+	switch key {
+	case "ALL":
+		return func(int, *message) bool { return true }, args, nil
+	case "ANSWERED", "DELETED", "DRAFT", "FLAGGED", "SEEN":
+		return hasFlag(`\` + key[:1] + strings.ToLower(key[1:])), args, nil
+	case "UNANSWERED", "UNDELETED", "UNDRAFT", "UNFLAGGED", "UNSEEN", "NEW":
+		flag := strings.TrimPrefix(key, "UN")
+		if key == "NEW" {
+			flag = "SEEN"
+		}
+		return not(hasFlag(`\` + flag[:1] + strings.ToLower(flag[1:]))), args, nil
+	case "KEYWORD", "UNKEYWORD":
+		flag, err := operand(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key == "UNKEYWORD" {
+			return not(hasFlag(flag)), args[1:], nil
+		}
+		return hasFlag(flag), args[1:], nil
+	case "FROM", "TO", "CC", "BCC", "SUBJECT", "BODY", "TEXT", "HEADER":
+		field, value := key, ""
+		var err error
+		if key == "HEADER" {
+			if field, err = operand(1); err == nil {
+				value, err = operand(2)
+			}
+			args = args[1:]
+		} else {
+			value, err = operand(1)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		value = strings.ToLower(value)
+		return func(_ int, msg *message) bool {
+			for _, content := range session.searchContents(msg, field) {
+				if strings.Contains(strings.ToLower(content), value) {
+					return true
+				}
+			}
+			return false
+		}, args[1:], nil
+	case "LARGER", "SMALLER":
+		value, err := operand(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid size %q for search key %s", value, key)
+		}
+		return func(_ int, msg *message) bool {
+			return key == "LARGER" && len(msg.raw) > size || key == "SMALLER" && len(msg.raw) < size
+		}, args[1:], nil
+	case "BEFORE", "ON", "SINCE", "SENTBEFORE", "SENTON", "SENTSINCE":
+		value, err := operand(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		day, err := time.Parse(imapSearchLayout, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid date %q for search key %s", value, key)
+		}
+		return func(_ int, msg *message) bool {
+			date := msg.date
+			if strings.HasPrefix(key, "SENT") {
+				parsed, err := mail.ReadMessage(bytes.NewReader(msg.raw))
+				if err != nil {
+					return false
+				}
+				if date, err = parsed.Header.Date(); err != nil {
+					return false
+				}
+			}
+			// only the day of the date is compared
+			d := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+			switch strings.TrimPrefix(key, "SENT") {
+			case "BEFORE":
+				return d.Before(day)
+			case "ON":
+				return d.Equal(day)
+			default:
+				return !d.Before(day)
+			}
+		}, args[1:], nil
+	case "UID":
+		value, err := operand(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		set, err := parseSeqSet(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		max := mbox.maxUID()
+		return func(_ int, msg *message) bool { return set.contains(msg.uid, max) }, args[1:], nil
+	case "NOT":
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("missing operand for search key NOT")
+		}
+		criterion, rest, err := session.searchKey(args, mbox)
+		if err != nil {
+			return nil, nil, err
+		}
+		return not(criterion), rest, nil
+	case "OR":
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("missing operand for search key OR")
+		}
+		first, rest, err := session.searchKey(args, mbox)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf("missing operand for search key OR")
+		}
+		second, rest, err := session.searchKey(rest, mbox)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(i int, msg *message) bool { return first(i, msg) || second(i, msg) }, rest, nil
+	}
+
+	// a search key can be a set of sequence numbers
+	set, err := parseSeqSet(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown search key %s", key)
+	}
+	max := uint32(len(mbox.messages))
+	return func(i int, _ *message) bool { return set.contains(uint32(i+1), max) }, args, nil
+}
+
+// searchContents returns the contents of the message in which a search key looks for its value.
+// The headers are decoded, and the body is both the raw and the decoded one.
+func (session *imapSession) searchContents(msg *message, field string) []string {
+	header, text := msg.split()
+	switch field {
+	case "BODY", "TEXT":
+		contents := []string{string(text)}
+		if decoded, err := imap.Decode(session.ctx, msg.raw); err == nil {
+			contents = append(contents, decoded.Body)
+		}
+		if field == "TEXT" {
+			contents = append(contents, string(header))
+		}
+		return contents
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(header))
+	if err != nil {
+		return nil
+	}
+	contents := []string{}
+	dec := new(mime.WordDecoder)
+	for _, value := range parsed.Header[textproto.CanonicalMIMEHeaderKey(field)] {
+		if decoded, err := dec.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		contents = append(contents, value)
+	}
+	return contents
+}
+
+// fetchItem is a data item of a FETCH command, with the section of the BODY items
+type fetchItem struct {
+	name    string
+	section string
+	peek    bool
+}
+
+func (item fetchItem) marksSeen() bool {
+	return item.name == "RFC822" || item.name == "RFC822.TEXT" || item.name == "BODY" && !item.peek
+}
+
+func containsFetchItem(items []fetchItem, name string) bool {
+	for _, item := range items {
+		if item.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFetchItems reads a data item, a macro or a list of data items
+func parseFetchItems(arg interface{}) ([]fetchItem, error) {
+	names := []string{}
+	if list, ok := arg.([]interface{}); ok {
+		for _, v := range list {
+			names = append(names, toString(v))
+		}
+	} else {
+		switch name := strings.ToUpper(toString(arg)); name {
+		case "ALL", "FULL":
+			names = []string{"FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE"}
+		case "FAST":
+			names = []string{"FLAGS", "INTERNALDATE", "RFC822.SIZE"}
+		default:
+			names = []string{name}
+		}
+	}
+
+	items := make([]fetchItem, 0, len(names))
+	for _, name := range names {
+		name = strings.ToUpper(name)
+		switch name {
+		case "UID", "FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE", "RFC822", "RFC822.HEADER", "RFC822.TEXT":
+			items = append(items, fetchItem{name: name})
+			continue
+		}
+		item := fetchItem{name: "BODY"}
+		switch {
+		case strings.HasPrefix(name, "BODY.PEEK["):
+			item.peek, item.section = true, strings.TrimPrefix(name, "BODY.PEEK[")
+		case strings.HasPrefix(name, "BODY["):
+			item.section = strings.TrimPrefix(name, "BODY[")
+		default:
+			return nil, fmt.Errorf("fetch item %s not implemented", name)
+		}
+		item.section = strings.TrimSuffix(item.section, "]")
+		if item.section != "" && item.section != "HEADER" && item.section != "TEXT" {
+			return nil, fmt.Errorf("fetch section %s not implemented", item.section)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// fetch returns the data item of the message as written in a FETCH response
+func (msg *message) fetch(item fetchItem) string {
+	header, text := msg.split()
+	switch item.name {
+	case "UID":
+		return fmt.Sprintf("UID %d", msg.uid)
+	case "FLAGS":
+		return fmt.Sprintf("FLAGS (%s)", strings.Join(msg.flags, " "))
+	case "INTERNALDATE":
+		return fmt.Sprintf("INTERNALDATE %q", msg.date.Format(imapDateLayout))
+	case "RFC822.SIZE":
+		return fmt.Sprintf("RFC822.SIZE %d", len(msg.raw))
+	case "ENVELOPE":
+		return "ENVELOPE " + msg.envelope()
+	case "RFC822":
+		return "RFC822 " + imapLiteral(string(msg.raw))
+	case "RFC822.HEADER":
+		return "RFC822.HEADER " + imapLiteral(string(header))
+	case "RFC822.TEXT":
+		return "RFC822.TEXT " + imapLiteral(string(text))
+	}
+	content := msg.raw
+	switch item.section {
+	case "HEADER":
+		content = header
+	case "TEXT":
+		content = text
+	}
+	return fmt.Sprintf("BODY[%s] %s", item.section, imapLiteral(string(content)))
+}
+
+// envelope returns the envelope structure of the message, as defined by RFC 3501 section 7.4.2
+func (msg *message) envelope() string {
+	header := mail.Header{}
+	if parsed, err := mail.ReadMessage(bytes.NewReader(msg.raw)); err == nil {
+		header = parsed.Header
+	}
+	from := imapAddressList(header, "From")
+	sender, replyTo := imapAddressList(header, "Sender"), imapAddressList(header, "Reply-To")
+	if sender == "NIL" {
+		sender = from
+	}
+	if replyTo == "NIL" {
+		replyTo = from
+	}
+	return fmt.Sprintf("(%s %s %s %s %s %s %s %s %s %s)",
+		imapNString(header.Get("Date")), imapNString(header.Get("Subject")), from, sender, replyTo,
+		imapAddressList(header, "To"), imapAddressList(header, "Cc"), imapAddressList(header, "Bcc"),
+		imapNString(header.Get("In-Reply-To")), imapNString(header.Get("Message-Id")))
+}
+
+func imapAddressList(header mail.Header, key string) string {
+	addresses, err := header.AddressList(key)
+	if err != nil || len(addresses) == 0 {
+		return "NIL"
+	}
+	values := make([]string, len(addresses))
+	for i, a := range addresses {
+		mailbox, host := a.Address, ""
+		if at := strings.LastIndex(a.Address, "@"); at >= 0 {
+			mailbox, host = a.Address[:at], a.Address[at+1:]
+		}
+		values[i] = fmt.Sprintf("(%s NIL %s %s)", imapNString(a.Name), imapNString(mailbox), imapNString(host))
+	}
+	return "(" + strings.Join(values, "") + ")"
+}
+
+// imapString returns a quoted string, or a literal if the value can't be quoted
+func imapString(s string) string {
+	for _, c := range []byte(s) {
+		if c == '\r' || c == '\n' || c >= 0x80 {
+			return imapLiteral(s)
+		}
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func imapNString(s string) string {
+	if s == "" {
+		return "NIL"
+	}
+	return imapString(s)
+}
+
+func imapLiteral(s string) string {
+	return fmt.Sprintf("{%d}\r\n%s", len(s), s)
+}
+
+// seqSet is a set of sequence numbers or UIDs, 0 stands for *, the largest number in use
+type seqSet []struct{ start, stop uint32 }
+
+func parseSeqSet(s string) (seqSet, error) {
+	set := seqSet{}
+	parse := func(n string) (uint32, error) {
+		if n == "*" {
+			return 0, nil
+		}
+		v, err := strconv.ParseUint(n, 10, 32)
+		if err != nil || v == 0 {
+			return 0, fmt.Errorf("invalid sequence set %q", s)
+		}
+		return uint32(v), nil
+	}
+	for _, r := range strings.Split(s, ",") {
+		startValue, stopValue, isRange := strings.Cut(r, ":")
+		start, err := parse(startValue)
+		if err != nil {
+			return nil, err
+		}
+		stop := start
+		if isRange {
+			if stop, err = parse(stopValue); err != nil {
+				return nil, err
+			}
+		}
+		set = append(set, struct{ start, stop uint32 }{start, stop})
+	}
+	return set, nil
+}
+
+func (set seqSet) contains(n, max uint32) bool {
+	for _, r := range set {
+		start, stop := r.start, r.stop
+		if start == 0 {
+			start = max
+		}
+		if stop == 0 {
+			stop = max
+		}
+		if start > stop {
+			start, stop = stop, start
+		}
+		if n >= start && n <= stop {
+			return true
+		}
+	}
+	return false
+}
+
+// maxUID returns the UID of the last message, 0 if the mailbox is empty
+func (mbox *mailbox) maxUID() uint32 {
+	if len(mbox.messages) == 0 {
+		return 0
+	}
+	return mbox.messages[len(mbox.messages)-1].uid
+}
+
+// readCommand reads a command with its literals. The tag is returned with syntax errors, to reply to the command.
+func (session *imapSession) readCommand() (string, []interface{}, error) {
+	line, err := session.readLine()
+	if err != nil {
+		return "", nil, err
+	}
+	tag, _, _ := strings.Cut(string(line), " ")
+	p := &imapParser{session: session, line: line[len(tag):]}
+	args, err := p.values(0)
+	if err != nil {
+		return tag, nil, err
+	}
+	return tag, args, nil
+}
+
+func (session *imapSession) readLine() ([]byte, error) {
+	line, err := session.r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// imapParser reads the arguments of a command: atoms, quoted strings, literals and parenthesized lists
+type imapParser struct {
+	session *imapSession
+	line    []byte
+	pos     int
+}
+
+// values reads the values until the closing character, or the end of the command if closing is 0
+func (p *imapParser) values(closing byte) ([]interface{}, error) {
+	values := []interface{}{}
+	for {
+		for p.pos < len(p.line) && p.line[p.pos] == ' ' {
+			p.pos++
+		}
+		if p.pos >= len(p.line) {
+			if closing != 0 {
+				return nil, imapSyntaxError(fmt.Sprintf("Missing %q", closing))
+			}
+			return values, nil
+		}
+
+		switch p.line[p.pos] {
+		case '(':
+			p.pos++
+			list, err := p.values(')')
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, list)
+		case ')':
+			if closing != ')' {
+				return nil, imapSyntaxError("Unexpected ')'")
+			}
+			p.pos++
+			return values, nil
+		case '"':
+			s, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		case '{':
+			s, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		default:
+			values = append(values, p.atom())
+		}
+	}
+}
+
+func (p *imapParser) quoted() (string, error) {
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.line); p.pos++ {
+		switch c := p.line[p.pos]; c {
+		case '\\':
+			p.pos++
+			if p.pos < len(p.line) {
+				b.WriteByte(p.line[p.pos])
+			}
+		case '"':
+			p.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", imapSyntaxError("Unterminated quoted string")
+}
+
+// literal reads the literal announced at the end of the line, then continues with the next line
+func (p *imapParser) literal() (string, error) {
+	end := bytes.IndexByte(p.line[p.pos:], '}')
+	if end < 0 || p.pos+end != len(p.line)-1 {
+		return "", imapSyntaxError("Literal must end the line")
+	}
+	spec := string(p.line[p.pos+1 : p.pos+end])
+	size, err := strconv.Atoi(strings.TrimSuffix(spec, "+"))
+	if err != nil || size < 0 {
+		return "", imapSyntaxError(fmt.Sprintf("Invalid literal size %q", spec))
+	}
+	// the client waits for a continuation request, unless the literal is non-synchronizing
+	if !strings.HasSuffix(spec, "+") {
+		fmt.Fprintf(p.session.w, "+ Ready for literal data\r\n")
+		if err := p.session.w.Flush(); err != nil {
+			return "", err
+		}
+	}
+	content := make([]byte, size)
+	if _, err := io.ReadFull(p.session.r, content); err != nil {
+		return "", err
+	}
+	if p.line, err = p.session.readLine(); err != nil {
+		return "", err
+	}
+	p.pos = 0
+	return string(content), nil
+}
+
+// atom reads an atom, the brackets of a section such as BODY[HEADER.FIELDS (SUBJECT)] are part of the atom
+func (p *imapParser) atom() string {
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.line); p.pos++ {
+		c := p.line[p.pos]
+		if c == '[' {
+			depth++
+		} else if c == ']' && depth > 0 {
+			depth--
+		} else if depth == 0 && (c == ' ' || c == '(' || c == ')') {
+			break
+		}
+	}
+	return string(p.line[start:p.pos])
+}
+
+func toString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func toList(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{v}
+}
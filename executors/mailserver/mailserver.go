@@ -0,0 +1,474 @@
+package mailserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+
+	"github.com/ovh/venom"
+	"github.com/ovh/venom/executors/imap"
+)
+
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
+// Name of executor
+const Name = "mailserver"
+
+// ContextKey is the key used to store the running servers in the context
+const ContextKey = venom.ContextKey("mailserverContext")
+
+const (
+	defaultServerName = "default"
+	defaultHost       = "127.0.0.1"
+	inbox             = "INBOX"
+)
+
+// New returns a new Executor
+func New() venom.Executor {
+	return &Executor{}
+}
+
+// Executor struct. Json and yaml descriptor are used for json output
+type Executor struct {
+	// Action must be "start", "messages", "reset" or "stop"
+	Action string `json:"action" yaml:"action"`
+	// Name of the server, allows to run several servers in the same testsuite. Default is "default"
+	Server string `json:"server,omitempty" yaml:"server,omitempty"`
+	// Host to listen on when starting the server. Default is 127.0.0.1
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+	// Ports to listen on when starting the server. Default is a random port
+	SMTPPort int `json:"smtpport,omitempty" yaml:"smtpport,omitempty"`
+	IMAPPort int `json:"imapport,omitempty" yaml:"imapport,omitempty"`
+	// Credentials expected by the SMTP and IMAP servers. Any credentials are accepted if empty
+	User     string `json:"user,omitempty" yaml:"user,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// Mailboxes created when starting the server, in addition to INBOX
+	Mailboxes []string `json:"mailboxes,omitempty" yaml:"mailboxes,omitempty"`
+}
+
+// Message represents a mail stored by the server, decoded as by the imap executor
+type Message struct {
+	imap.Mail
+	Mailbox string `json:"mailbox" yaml:"mailbox"`
+	// Sender and Recipients of the SMTP envelope, empty for a mail appended through IMAP
+	Sender     string            `json:"sender,omitempty" yaml:"sender,omitempty"`
+	Recipients []string          `json:"recipients,omitempty" yaml:"recipients,omitempty"`
+	MessageID  string            `json:"messageid,omitempty" yaml:"messageid,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Raw        string            `json:"raw" yaml:"raw"`
+}
+
+// Result represents a step result. Json and yaml descriptor are used for json output
+type Result struct {
+	Host        string        `json:"host,omitempty" yaml:"host,omitempty"`
+	SMTPPort    int           `json:"smtpport,omitempty" yaml:"smtpport,omitempty"`
+	IMAPPort    int           `json:"imapport,omitempty" yaml:"imapport,omitempty"`
+	Messages    []interface{} `json:"messages,omitempty" yaml:"messages,omitempty"`
+	TimeSeconds float64       `json:"timeseconds,omitempty" yaml:"timeseconds,omitempty"`
+}
+
+// servers holds the running mail servers by name
+type servers struct {
+	mutex   sync.Mutex
+	servers map[string]*server
+}
+
+type mailServerContext struct {
+	servers *servers
+	// owned is true when the servers are not shared with the other testcases of the testsuite
+	owned bool
+}
+
+type server struct {
+	name      string
+	host      string
+	smtpPort  int
+	imapPort  int
+	user      string
+	password  string
+	store     *store
+	listeners []net.Listener
+	wg        sync.WaitGroup
+	mutex     sync.Mutex
+	conns     map[net.Conn]struct{}
+	stopped   bool
+}
+
+// ZeroValueResult return an empty implementation of this executor result
+func (Executor) ZeroValueResult() interface{} {
+	return Result{}
+}
+
+// Setup gets the servers shared by the testcases of the testsuite
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	if s := venom.TestSuiteResource(ctx, string(ContextKey), func() io.Closer { return newServers() }); s != nil {
+		return context.WithValue(ctx, ContextKey, &mailServerContext{servers: s.(*servers)}), nil
+	}
+	return context.WithValue(ctx, ContextKey, &mailServerContext{servers: newServers(), owned: true}), nil
+}
+
+// TearDown stops the servers if they are not shared with the testsuite
+func (Executor) TearDown(ctx context.Context) error {
+	mailCtx := getMailServerCtx(ctx)
+	if mailCtx == nil || !mailCtx.owned {
+		return nil
+	}
+	return mailCtx.servers.Close()
+}
+
+func getMailServerCtx(ctx context.Context) *mailServerContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*mailServerContext)
+}
+
+func newServers() *servers {
+	return &servers{servers: map[string]*server{}}
+}
+
+// Close stops all the servers
+func (s *servers) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for name, srv := range s.servers {
+		srv.stop()
+		delete(s.servers, name)
+	}
+	return nil
+}
+
+// Run execute TestStep
+func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, error) {
+	// transform step to Executor Instance
+	var e Executor
+	if err := mapstructure.Decode(step, &e); err != nil {
+		return nil, err
+	}
+	if e.Server == "" {
+		e.Server = defaultServerName
+	}
+
+	mailCtx := getMailServerCtx(ctx)
+	if mailCtx == nil {
+		return nil, errors.New("mailserver executor must be setup before use")
+	}
+
+	start := time.Now()
+	result := Result{}
+
+	running := mailCtx.servers
+	running.mutex.Lock()
+	defer running.mutex.Unlock()
+	s, started := running.servers[e.Server]
+
+	switch e.Action {
+	case "start":
+		if started {
+			return nil, fmt.Errorf("mail server %q is already started", e.Server)
+		}
+		var err error
+		s, err = e.start(ctx)
+		if err != nil {
+			return nil, err
+		}
+		running.servers[e.Server] = s
+		venom.Info(ctx, "mail server %q listening on %s with SMTP port %d and IMAP port %d", e.Server, s.host, s.smtpPort, s.imapPort)
+		venom.SetTestSuiteVar(ctx, Name+"."+e.Server+".host", s.host)
+		venom.SetTestSuiteVar(ctx, Name+"."+e.Server+".smtpport", s.smtpPort)
+		venom.SetTestSuiteVar(ctx, Name+"."+e.Server+".imapport", s.imapPort)
+	case "messages", "reset", "stop":
+		if !started {
+			return nil, fmt.Errorf("mail server %q is not started", e.Server)
+		}
+		var err error
+		result.Messages, err = s.store.decodedMessages(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read received messages")
+		}
+		switch e.Action {
+		case "reset":
+			s.store.reset()
+		case "stop":
+			delete(running.servers, e.Server)
+			s.stop()
+		}
+	default:
+		return nil, fmt.Errorf("action %q must be start, messages, reset or stop", e.Action)
+	}
+
+	result.Host = s.host
+	result.SMTPPort = s.smtpPort
+	result.IMAPPort = s.imapPort
+	result.TimeSeconds = time.Since(start).Seconds()
+	return result, nil
+}
+
+func (e Executor) start(ctx context.Context) (*server, error) {
+	host := e.Host
+	if host == "" {
+		host = defaultHost
+	}
+	s := &server{
+		name:     e.Server,
+		host:     host,
+		user:     e.User,
+		password: e.Password,
+		store:    newStore(e.Mailboxes),
+		conns:    map[net.Conn]struct{}{},
+	}
+
+	for _, l := range []struct {
+		protocol string
+		port     int
+		serve    func(context.Context, net.Conn)
+		bound    *int
+	}{
+		{"SMTP", e.SMTPPort, s.serveSMTP, &s.smtpPort},
+		{"IMAP", e.IMAPPort, s.serveIMAP, &s.imapPort},
+	} {
+		listen := net.JoinHostPort(host, strconv.Itoa(l.port))
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			s.stop()
+			return nil, errors.Wrapf(err, "unable to listen on %s for %s", listen, l.protocol)
+		}
+		*l.bound = listener.Addr().(*net.TCPAddr).Port
+		s.listeners = append(s.listeners, listener)
+		s.wg.Add(1)
+		go s.accept(ctx, listener, l.serve)
+	}
+	return s, nil
+}
+
+// accept serves the connections of the listener until it is closed
+func (s *server) accept(ctx context.Context, l net.Listener, serve func(context.Context, net.Conn)) {
+	defer s.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				venom.Error(ctx, "mail server %q stopped accepting connections: %v", s.name, err)
+			}
+			return
+		}
+		s.mutex.Lock()
+		if s.stopped {
+			s.mutex.Unlock()
+			conn.Close() // nolint
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mutex.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mutex.Lock()
+				delete(s.conns, conn)
+				s.mutex.Unlock()
+				conn.Close() // nolint
+			}()
+			serve(ctx, conn)
+		}()
+	}
+}
+
+// authenticate checks the credentials, any credentials are valid if the server has none
+func (s *server) authenticate(user, password string) bool {
+	return s.user == "" && s.password == "" || user == s.user && password == s.password
+}
+
+// stop closes the listeners and the open connections, then waits for their goroutines
+func (s *server) stop() {
+	for _, l := range s.listeners {
+		l.Close() // nolint
+	}
+	s.mutex.Lock()
+	s.stopped = true
+	for conn := range s.conns {
+		conn.Close() // nolint
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+}
+
+// store holds the mailboxes of the server, shared by the SMTP and IMAP connections
+type store struct {
+	mutex       sync.Mutex
+	mailboxes   map[string]*mailbox
+	uidValidity uint32
+}
+
+type mailbox struct {
+	name        string
+	uidValidity uint32
+	uidNext     uint32
+	messages    []*message
+}
+
+type message struct {
+	uid        uint32
+	flags      []string
+	date       time.Time
+	raw        []byte
+	sender     string
+	recipients []string
+}
+
+func newStore(mailboxes []string) *store {
+	st := &store{mailboxes: map[string]*mailbox{}, uidValidity: uint32(time.Now().Unix())}
+	for _, name := range append([]string{inbox}, mailboxes...) {
+		st.create(name)
+	}
+	return st
+}
+
+// mailboxName returns the name of a mailbox, INBOX is case-insensitive
+func mailboxName(name string) string {
+	if strings.EqualFold(name, inbox) {
+		return inbox
+	}
+	return name
+}
+
+// get returns the mailbox, or nil if it doesn't exist. The store must be locked.
+func (st *store) get(name string) *mailbox {
+	return st.mailboxes[mailboxName(name)]
+}
+
+// create returns false if the mailbox already exists. The store must be locked.
+func (st *store) create(name string) bool {
+	name = mailboxName(name)
+	if _, ok := st.mailboxes[name]; ok {
+		return false
+	}
+	// a mailbox created again after its deletion gets a new UIDVALIDITY, as its UIDs start again from 1
+	st.uidValidity++
+	st.mailboxes[name] = &mailbox{name: name, uidValidity: st.uidValidity, uidNext: 1}
+	return true
+}
+
+// names returns the sorted names of the mailboxes. The store must be locked.
+func (st *store) names() []string {
+	names := make([]string, 0, len(st.mailboxes))
+	for name := range st.mailboxes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deliver stores a mail received by SMTP in INBOX
+func (st *store) deliver(sender string, recipients []string, raw []byte) uint32 {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.get(inbox).add(&message{
+		date:       time.Now(),
+		raw:        raw,
+		sender:     sender,
+		recipients: recipients,
+	})
+}
+
+// reset removes all the messages, the mailboxes are kept
+func (st *store) reset() {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	for _, mbox := range st.mailboxes {
+		mbox.messages = nil
+	}
+}
+
+// decodedMessages returns the messages of all the mailboxes as generic values, so that JSON assertions can be used on them
+func (st *store) decodedMessages(ctx context.Context) ([]interface{}, error) {
+	st.mutex.Lock()
+	messages := []Message{}
+	for _, name := range st.names() {
+		for _, msg := range st.mailboxes[name].messages {
+			decoded, err := msg.decode(ctx)
+			if err != nil {
+				venom.Warn(ctx, "Cannot decode the mail %d of mailbox %q: %v", msg.uid, name, err)
+			}
+			decoded.Mailbox = name
+			messages = append(messages, decoded)
+		}
+	}
+	st.mutex.Unlock()
+
+	b, err := json.Marshal(messages)
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	if err := venom.JSONUnmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// add appends a copy of the message with the next UID of the mailbox, and returns this UID
+func (mbox *mailbox) add(msg *message) uint32 {
+	m := *msg
+	m.uid = mbox.uidNext
+	m.flags = append([]string{}, msg.flags...)
+	mbox.uidNext++
+	mbox.messages = append(mbox.messages, &m)
+	return m.uid
+}
+
+func (msg *message) hasFlag(flag string) bool {
+	for _, f := range msg.flags {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// split returns the header of the raw message, including the empty line which ends it, and the text which follows
+func (msg *message) split() ([]byte, []byte) {
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(msg.raw, []byte(sep)); i >= 0 {
+			return msg.raw[:i+len(sep)], msg.raw[i+len(sep):]
+		}
+	}
+	return msg.raw, []byte{}
+}
+
+// decode reads the message with the decoding logic of the imap executor
+func (msg *message) decode(ctx context.Context) (Message, error) {
+	m := Message{
+		Sender:     msg.sender,
+		Recipients: msg.recipients,
+		Headers:    map[string]string{},
+		Raw:        string(msg.raw),
+	}
+	decoded, err := imap.Decode(ctx, msg.raw)
+	decoded.UID = msg.uid
+	decoded.Flags = msg.flags
+	m.Mail = decoded
+	if parsed, errp := mail.ReadMessage(bytes.NewReader(msg.raw)); errp == nil {
+		for k, v := range parsed.Header {
+			m.Headers[k] = strings.Join(v, ",")
+		}
+		m.MessageID = parsed.Header.Get("Message-Id")
+	}
+	return m, err
+}
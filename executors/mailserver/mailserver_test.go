@@ -0,0 +1,216 @@
+package mailserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+	"github.com/ovh/venom/executors/imap"
+	smtpexecutor "github.com/ovh/venom/executors/smtp"
+)
+
+func TestExecutor_Run(t *testing.T) {
+	venom.InitTestLogger(t)
+	outputDir := t.TempDir()
+	invoice := filepath.Join(t.TempDir(), "invoice.txt")
+	require.NoError(t, os.WriteFile(invoice, []byte("Invoice #1"), 0o644))
+
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.WithValue(context.Background(), venom.ContextKey("var.venom.outputdir"), outputDir), venom.H{})
+	require.NoError(t, err)
+	defer e.TearDown(ctx)
+
+	res, err := e.Run(ctx, venom.TestStep{
+		"action":    "start",
+		"user":      "venom",
+		"password":  "secret",
+		"mailboxes": []interface{}{"Archive"},
+	})
+	require.NoError(t, err)
+	started := res.(Result)
+	assert.Equal(t, "127.0.0.1", started.Host)
+	require.NotZero(t, started.SMTPPort)
+	require.NotZero(t, started.IMAPPort)
+
+	_, err = smtpexecutor.New().Run(ctx, venom.TestStep{
+		"host":        started.Host,
+		"port":        strconv.Itoa(started.SMTPPort),
+		"user":        "venom",
+		"password":    "secret",
+		"from":        "sender@venom.local",
+		"to":          "Someone <someone@example.org>",
+		"bcc":         "hidden@example.org",
+		"subject":     "Order été confirmed",
+		"body":        "Your order is confirmed.\n.line starting with a dot",
+		"htmlbody":    "<p>Your order is confirmed.</p>",
+		"attachments": []interface{}{invoice},
+	})
+	require.NoError(t, err)
+
+	addr := fmt.Sprintf("%s:%d", started.Host, started.SMTPPort)
+	err = smtp.SendMail(addr, nil, "other@venom.local", []string{"someone@example.org"}, []byte("Subject: Hello\r\n\r\nHello\r\n"))
+	assert.ErrorContains(t, err, "Authentication required")
+
+	imapStep := func(commands ...interface{}) imap.CommandResult {
+		res, err := imap.New().Run(ctx, venom.TestStep{
+			"auth": map[string]interface{}{
+				"host":     started.Host,
+				"port":     strconv.Itoa(started.IMAPPort),
+				"user":     "venom",
+				"password": "secret",
+			},
+			"commands": commands,
+		})
+		require.NoError(t, err)
+		results := res.(imap.Result).Commands
+		require.Len(t, results, 1)
+		return results[0]
+	}
+
+	fetched := imapStep(map[string]interface{}{
+		"name":          "fetch",
+		"search":        map[string]interface{}{"mailbox": "INBOX", "subject": "Order été"},
+		"wait_for_mail": map[string]interface{}{"timeout": 1, "interval": 100},
+	})
+	require.Empty(t, fetched.Err)
+	require.Len(t, fetched.Mails, 1)
+	assert.Equal(t, uint32(1), fetched.Search.UID)
+	assert.Equal(t, "sender@venom.local", fetched.Search.From)
+	assert.Equal(t, "Someone <someone@example.org>", fetched.Search.To)
+	assert.Equal(t, "Your order is confirmed.\r\n.line starting with a dot", fetched.Search.Body)
+	assert.Equal(t, []string{`\Seen`}, fetched.Search.Flags)
+	require.Len(t, fetched.Search.Attachments, 1)
+	attachment := fetched.Search.Attachments[0]
+	assert.Equal(t, "invoice.txt", attachment.Filename)
+	assert.Equal(t, 10, attachment.Size)
+	assert.Equal(t, filepath.Join(outputDir, "inbox_1_invoice.txt"), attachment.Path)
+	content, err := os.ReadFile(attachment.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "Invoice #1", string(content))
+
+	waited := imapStep(map[string]interface{}{
+		"name":          "fetch",
+		"search":        map[string]interface{}{"mailbox": "INBOX", "from": "nobody@venom.local"},
+		"wait_for_mail": map[string]interface{}{"timeout": 1, "interval": 200},
+	})
+	assert.Equal(t, "wait_for_mail: 0 mail(s) found after 1s while waiting for 1", waited.Err)
+
+	flagged := imapStep(map[string]interface{}{
+		"name":   "flag",
+		"search": map[string]interface{}{"mailbox": "INBOX", "uid": 1},
+		"args":   map[string]interface{}{"add": []interface{}{"Important"}},
+	})
+	require.Empty(t, flagged.Err)
+	assert.ElementsMatch(t, []string{`\Seen`, "Important"}, flagged.Mail.Flags)
+
+	appended := imapStep(map[string]interface{}{
+		"name": "append",
+		"args": map[string]interface{}{"mailbox": "Archive", "from": "old@venom.local", "to": "someone@example.org", "subject": "Old", "body": "Archived"},
+	})
+	require.Empty(t, appended.Err)
+
+	moved := imapStep(map[string]interface{}{
+		"name":   "move",
+		"search": map[string]interface{}{"mailbox": "INBOX", "subject": "Order"},
+		"args":   map[string]interface{}{"mailbox": "Archive"},
+	})
+	require.Empty(t, moved.Err)
+	assert.Equal(t, uint32(2), moved.Mail.UID)
+
+	deleted := imapStep(map[string]interface{}{
+		"name":   "delete",
+		"search": map[string]interface{}{"mailbox": "Archive", "subject": "Old"},
+	})
+	require.Empty(t, deleted.Err)
+
+	res, err = e.Run(ctx, venom.TestStep{"action": "messages"})
+	require.NoError(t, err)
+	messages := res.(Result).Messages
+	require.Len(t, messages, 1)
+	msg := messages[0].(map[string]interface{})
+	assert.Equal(t, "Archive", msg["mailbox"])
+	assert.Equal(t, json.Number("2"), msg["uid"])
+	assert.Equal(t, "Order été confirmed", msg["subject"])
+	assert.Equal(t, "sender@venom.local", msg["sender"])
+	assert.Equal(t, []interface{}{"someone@example.org", "hidden@example.org"}, msg["recipients"])
+	assert.NotEmpty(t, msg["messageid"])
+	assert.Equal(t, "sender@venom.local", msg["headers"].(map[string]interface{})["From"])
+	assert.Contains(t, msg["raw"], "<p>Your order is confirmed.</p>")
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "reset"})
+	require.NoError(t, err)
+	res, err = e.Run(ctx, venom.TestStep{"action": "messages"})
+	require.NoError(t, err)
+	assert.Empty(t, res.(Result).Messages)
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "start"})
+	assert.Error(t, err, "server is already started")
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "stop"})
+	require.NoError(t, err)
+	_, err = smtp.Dial(addr)
+	assert.Error(t, err)
+}
+
+func TestImapSession_search(t *testing.T) {
+	venom.InitTestLogger(t)
+	st := newStore(nil)
+	for _, raw := range []string{
+		"From: a@venom.local\r\nSubject: =?utf-8?q?Caf=C3=A9?=\r\n\r\nfirst body\r\n",
+		"From: b@venom.local\r\nSubject: Second\r\nX-Priority: 1\r\n\r\nsecond body\r\n",
+		"From: c@venom.local\r\nSubject: Third\r\n\r\nthird body\r\n",
+	} {
+		st.deliver("sender@venom.local", []string{"someone@example.org"}, []byte(raw))
+	}
+	mbox := st.get("inbox")
+	mbox.messages[1].flags = []string{`\Seen`}
+	session := &imapSession{ctx: context.Background(), selected: mbox}
+
+	tests := []struct {
+		criteria []interface{}
+		want     []uint32
+	}{
+		{criteria: []interface{}{"ALL"}, want: []uint32{1, 2, 3}},
+		{criteria: []interface{}{"SUBJECT", "café"}, want: []uint32{1}},
+		{criteria: []interface{}{"UNSEEN", "FROM", "venom.local"}, want: []uint32{1, 3}},
+		{criteria: []interface{}{"HEADER", "X-Priority", "1"}, want: []uint32{2}},
+		{criteria: []interface{}{"OR", "BODY", "third", "SEEN"}, want: []uint32{2, 3}},
+		{criteria: []interface{}{"NOT", []interface{}{"UID", "2:*"}}, want: []uint32{1}},
+		{criteria: []interface{}{"2,3", "TEXT", "Third"}, want: []uint32{3}},
+	}
+	for _, tt := range tests {
+		criteria, err := session.searchCriteria(tt.criteria, mbox)
+		require.NoError(t, err)
+		found := []uint32{}
+		for i, msg := range mbox.messages {
+			if criteria(i, msg) {
+				found = append(found, msg.uid)
+			}
+		}
+		assert.Equal(t, tt.want, found, "%v", tt.criteria)
+	}
+
+	_, err := session.searchCriteria([]interface{}{"UNKNOWN"}, mbox)
+	assert.EqualError(t, err, "unknown search key UNKNOWN")
+}
+
+func TestExecutor_Run_InvalidAction(t *testing.T) {
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "restart"})
+	assert.EqualError(t, err, `action "restart" must be start, messages, reset or stop`)
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "messages"})
+	assert.EqualError(t, err, `mail server "default" is not started`)
+}
@@ -0,0 +1,223 @@
+package mailserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"github.com/ovh/venom"
+)
+
+// smtpSession is the state of a SMTP connection
+type smtpSession struct {
+	s             *server
+	conn          *textproto.Conn
+	authenticated bool
+	sender        string
+	recipients    []string
+	hasSender     bool
+}
+
+// serveSMTP stores the mails received on the connection in INBOX, whatever their recipients
+func (s *server) serveSMTP(ctx context.Context, c net.Conn) {
+	session := &smtpSession{s: s, conn: textproto.NewConn(c)}
+	session.reply(220, "%s venom mail server ready", s.host)
+	for {
+		line, err := session.conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		venom.Debug(ctx, "mail server %q received SMTP command %s", s.name, verb)
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			session.reply(250, "%s", s.host)
+		case "EHLO":
+			session.reply(250, "%s\n8BITMIME\nAUTH PLAIN LOGIN", s.host)
+		case "AUTH":
+			session.auth(arg)
+		case "MAIL":
+			session.mail(arg)
+		case "RCPT":
+			session.rcpt(arg)
+		case "DATA":
+			if err := session.data(ctx); err != nil {
+				return
+			}
+		case "RSET":
+			session.resetTransaction()
+			session.reply(250, "OK")
+		case "NOOP":
+			session.reply(250, "OK")
+		case "VRFY":
+			session.reply(252, "Cannot verify the user")
+		case "QUIT":
+			session.reply(221, "Bye")
+			return
+		default:
+			session.reply(502, "Command not implemented")
+		}
+	}
+}
+
+// reply writes a response, each line of the message is written on its own response line
+func (session *smtpSession) reply(code int, format string, args ...interface{}) {
+	lines := strings.Split(fmt.Sprintf(format, args...), "\n")
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		if err := session.conn.PrintfLine("%d%s%s", code, sep, line); err != nil {
+			return
+		}
+	}
+}
+
+func (session *smtpSession) resetTransaction() {
+	session.sender, session.recipients, session.hasSender = "", nil, false
+}
+
+// auth handles the PLAIN and LOGIN mechanisms
+func (session *smtpSession) auth(arg string) {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	readResponse := func(challenge string) (string, bool) {
+		session.reply(334, "%s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+		line, err := session.conn.ReadLine()
+		if err != nil || line == "*" {
+			return "", false
+		}
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return "", false
+		}
+		return string(decoded), true
+	}
+
+	var user, password string
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		var response string
+		if initial != "" {
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			if err != nil {
+				session.reply(501, "Invalid base64 data")
+				return
+			}
+			response = string(decoded)
+		} else {
+			var ok bool
+			if response, ok = readResponse(""); !ok {
+				session.reply(501, "Authentication cancelled")
+				return
+			}
+		}
+		// the response is authorization identity, user and password, separated by NUL characters
+		parts := strings.Split(response, "\x00")
+		if len(parts) != 3 {
+			session.reply(501, "Invalid PLAIN response")
+			return
+		}
+		user, password = parts[1], parts[2]
+	case "LOGIN":
+		var ok bool
+		if user, ok = readResponse("Username:"); !ok {
+			session.reply(501, "Authentication cancelled")
+			return
+		}
+		if password, ok = readResponse("Password:"); !ok {
+			session.reply(501, "Authentication cancelled")
+			return
+		}
+	default:
+		session.reply(504, "Unrecognized authentication type")
+		return
+	}
+
+	if !session.s.authenticate(user, password) {
+		session.reply(535, "Authentication credentials invalid")
+		return
+	}
+	session.authenticated = true
+	session.reply(235, "Authentication successful")
+}
+
+func (session *smtpSession) mail(arg string) {
+	if session.s.user != "" && !session.authenticated {
+		session.reply(530, "Authentication required")
+		return
+	}
+	address, ok := pathArgument(arg, "FROM:")
+	if !ok {
+		session.reply(501, "Syntax: MAIL FROM:<address>")
+		return
+	}
+	session.resetTransaction()
+	session.sender, session.hasSender = address, true
+	session.reply(250, "OK")
+}
+
+func (session *smtpSession) rcpt(arg string) {
+	if !session.hasSender {
+		session.reply(503, "Need MAIL command")
+		return
+	}
+	address, ok := pathArgument(arg, "TO:")
+	if !ok || address == "" {
+		session.reply(501, "Syntax: RCPT TO:<address>")
+		return
+	}
+	session.recipients = append(session.recipients, address)
+	session.reply(250, "OK")
+}
+
+// data reads the mail until the line with a single dot, an error is returned if the connection is broken
+func (session *smtpSession) data(ctx context.Context) error {
+	if len(session.recipients) == 0 {
+		session.reply(503, "Need RCPT command")
+		return nil
+	}
+	session.reply(354, "End data with <CR><LF>.<CR><LF>")
+	var raw bytes.Buffer
+	for {
+		line, err := session.conn.ReadLine()
+		if err != nil {
+			return err
+		}
+		if line == "." {
+			break
+		}
+		// remove the dot added by the client to the lines starting with a dot
+		raw.WriteString(strings.TrimPrefix(line, "."))
+		raw.WriteString("\r\n")
+	}
+	uid := session.s.store.deliver(session.sender, session.recipients, raw.Bytes())
+	venom.Debug(ctx, "mail server %q received a mail from %q to %v", session.s.name, session.sender, session.recipients)
+	session.resetTransaction()
+	session.reply(250, "OK: queued as %d", uid)
+	return nil
+}
+
+// pathArgument returns the address of a MAIL FROM:<address> or RCPT TO:<address> argument, the parameters which follow are ignored
+func pathArgument(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(path, "<") {
+		return "", false
+	}
+	end := strings.IndexByte(path, '>')
+	if end < 0 {
+		return "", false
+	}
+	return path[1:end], true
+}
@@ -0,0 +1,391 @@
+package mockserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+
+	"github.com/ovh/venom"
+)
+
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
+// Name of executor
+const Name = "mockserver"
+
+// ContextKey is the key used to store the running servers in the context
+const ContextKey = venom.ContextKey("mockserverContext")
+
+const (
+	defaultServerName = "default"
+	shutdownTimeout   = 5 * time.Second
+)
+
+// Scopes of a server
+const (
+	// ScopeTestCase servers are stopped at the end of the testcase
+	ScopeTestCase = "testcase"
+	// ScopeTestSuite servers are stopped at the end of the testsuite
+	ScopeTestSuite = "testsuite"
+)
+
+// New returns a new Executor
+func New() venom.Executor {
+	return &Executor{}
+}
+
+// Executor struct. Json and yaml descriptor are used for json output
+type Executor struct {
+	// Action must be "start", "requests", "reset" or "stop"
+	Action string `json:"action" yaml:"action"`
+	// Name of the server, allows to run several servers in the same testcase. Default is "default"
+	Server string `json:"server,omitempty" yaml:"server,omitempty"`
+	// Scope of the server when starting it: testcase (default) or testsuite
+	Scope string `json:"scope,omitempty" yaml:"scope,omitempty"`
+	// Address to listen on when starting the server. Default is 127.0.0.1 with a random port
+	Listen string `json:"listen,omitempty" yaml:"listen,omitempty"`
+	// Routes served by the server, matched in order
+	Routes []Route `json:"routes,omitempty" yaml:"routes,omitempty"`
+}
+
+// Route represents a canned response
+type Route struct {
+	// Method to match, any method if empty
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	// Path to match, can be a pattern as described in https://pkg.go.dev/path#Match
+	Path    string            `json:"path" yaml:"path"`
+	Status  int               `json:"status,omitempty" yaml:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Body of the response, a value which is not a string is encoded as JSON
+	Body interface{} `json:"body,omitempty" yaml:"body,omitempty"`
+	// Latency before sending the response. In Milliseconds
+	Latency int64 `json:"latency,omitempty" yaml:"latency,omitempty"`
+}
+
+// Request represents a request received by the server
+type Request struct {
+	Method   string            `json:"method" yaml:"method"`
+	Path     string            `json:"path" yaml:"path"`
+	Query    map[string]string `json:"query,omitempty" yaml:"query,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body     string            `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyJSON interface{}       `json:"bodyjson,omitempty" yaml:"bodyjson,omitempty"`
+	Matched  bool              `json:"matched" yaml:"matched"`
+}
+
+// Result represents a step result. Json and yaml descriptor are used for json output
+type Result struct {
+	URL         string        `json:"url,omitempty" yaml:"url,omitempty"`
+	Port        int           `json:"port,omitempty" yaml:"port,omitempty"`
+	Requests    []interface{} `json:"requests,omitempty" yaml:"requests,omitempty"`
+	TimeSeconds float64       `json:"timeseconds,omitempty" yaml:"timeseconds,omitempty"`
+}
+
+// servers holds the running mock servers by name
+type servers struct {
+	mutex   sync.Mutex
+	servers map[string]*server
+}
+
+type mockServerContext struct {
+	testcase *servers
+	// testsuite holds the servers shared by the testcases of the testsuite, it is testcase outside of a testsuite
+	testsuite *servers
+}
+
+type server struct {
+	url      string
+	port     int
+	httpSrv  *http.Server
+	routes   []Route
+	mutex    sync.Mutex
+	requests []Request
+}
+
+// ZeroValueResult return an empty implementation of this executor result
+func (Executor) ZeroValueResult() interface{} {
+	return Result{}
+}
+
+// Setup prepares the holder of the servers started during the testcase, and gets the servers of the testsuite
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	mockCtx := &mockServerContext{testcase: newServers()}
+	mockCtx.testsuite = mockCtx.testcase
+	if s := venom.TestSuiteResource(ctx, string(ContextKey), func() io.Closer { return newServers() }); s != nil {
+		mockCtx.testsuite = s.(*servers)
+	}
+	return context.WithValue(ctx, ContextKey, mockCtx), nil
+}
+
+// TearDown stops all the servers started during the testcase
+func (Executor) TearDown(ctx context.Context) error {
+	mockCtx := getMockServerCtx(ctx)
+	if mockCtx == nil {
+		return nil
+	}
+	return mockCtx.testcase.Close()
+}
+
+func getMockServerCtx(ctx context.Context) *mockServerContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*mockServerContext)
+}
+
+func newServers() *servers {
+	return &servers{servers: map[string]*server{}}
+}
+
+// Close stops all the servers
+func (s *servers) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var errs []string
+	for name, srv := range s.servers {
+		if err := srv.stop(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		delete(s.servers, name)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to stop mock servers: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Run execute TestStep
+func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, error) {
+	// transform step to Executor Instance
+	var e Executor
+	if err := mapstructure.Decode(step, &e); err != nil {
+		return nil, err
+	}
+	if e.Server == "" {
+		e.Server = defaultServerName
+	}
+	if e.Scope == "" {
+		e.Scope = ScopeTestCase
+	}
+	if e.Scope != ScopeTestCase && e.Scope != ScopeTestSuite {
+		return nil, fmt.Errorf("scope %q must be %s or %s", e.Scope, ScopeTestCase, ScopeTestSuite)
+	}
+
+	mockCtx := getMockServerCtx(ctx)
+	if mockCtx == nil {
+		return nil, errors.New("mockserver executor must be setup before use")
+	}
+
+	start := time.Now()
+	result := Result{}
+
+	// the servers of the testcase are locked first, then the servers of the testsuite
+	mockCtx.testcase.mutex.Lock()
+	defer mockCtx.testcase.mutex.Unlock()
+	if mockCtx.testsuite != mockCtx.testcase {
+		mockCtx.testsuite.mutex.Lock()
+		defer mockCtx.testsuite.mutex.Unlock()
+	}
+	owner := mockCtx.testcase
+	s, started := owner.servers[e.Server]
+	if !started {
+		owner = mockCtx.testsuite
+		s, started = owner.servers[e.Server]
+	}
+
+	switch e.Action {
+	case "start":
+		if started {
+			return nil, fmt.Errorf("mock server %q is already started", e.Server)
+		}
+		var err error
+		s, err = e.start(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if e.Scope == ScopeTestSuite {
+			mockCtx.testsuite.servers[e.Server] = s
+			venom.SetTestSuiteVar(ctx, Name+"."+e.Server+".url", s.url)
+			venom.SetTestSuiteVar(ctx, Name+"."+e.Server+".port", s.port)
+		} else {
+			mockCtx.testcase.servers[e.Server] = s
+		}
+		venom.Info(ctx, "mock server %q listening on %s until the end of the %s", e.Server, s.url, e.Scope)
+	case "requests", "reset", "stop":
+		if !started {
+			return nil, fmt.Errorf("mock server %q is not started", e.Server)
+		}
+		var err error
+		result.Requests, err = s.recordedRequests()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read recorded requests")
+		}
+		switch e.Action {
+		case "reset":
+			s.reset()
+		case "stop":
+			delete(owner.servers, e.Server)
+			if err := s.stop(); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("action %q must be start, requests, reset or stop", e.Action)
+	}
+
+	result.URL = s.url
+	result.Port = s.port
+	result.TimeSeconds = time.Since(start).Seconds()
+	return result, nil
+}
+
+func (e Executor) start(ctx context.Context) (*server, error) {
+	listen := e.Listen
+	if listen == "" {
+		listen = "127.0.0.1:0"
+	}
+	for i, r := range e.Routes {
+		if r.Path == "" {
+			return nil, fmt.Errorf("mandatory field path was empty in routes[%d]", i)
+		}
+		if _, err := path.Match(r.Path, "/"); err != nil {
+			return nil, errors.Wrapf(err, "invalid path pattern in routes[%d]", i)
+		}
+	}
+
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to listen on %s", listen)
+	}
+
+	s := &server{
+		port:   l.Addr().(*net.TCPAddr).Port,
+		routes: e.Routes,
+	}
+	s.url = "http://" + l.Addr().String()
+	s.httpSrv = &http.Server{Handler: s}
+	go func() {
+		if err := s.httpSrv.Serve(l); err != nil && err != http.ErrServerClosed {
+			venom.Error(ctx, "mock server %q stopped: %v", e.Server, err)
+		}
+	}()
+	return s, nil
+}
+
+// ServeHTTP records the request and writes the response of the first matching route
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := Request{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   map[string]string{},
+		Headers: map[string]string{},
+		Body:    string(body),
+	}
+	for k, v := range r.URL.Query() {
+		req.Query[k] = strings.Join(v, ",")
+	}
+	for k, v := range r.Header {
+		req.Headers[k] = strings.Join(v, ",")
+	}
+	var bodyJSON interface{}
+	if err := venom.JSONUnmarshal(body, &bodyJSON); err == nil {
+		req.BodyJSON = bodyJSON
+	}
+
+	route := s.match(r)
+	req.Matched = route != nil
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, req)
+	s.mutex.Unlock()
+
+	if route == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if route.Latency > 0 {
+		select {
+		case <-time.After(time.Duration(route.Latency) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	var content []byte
+	switch b := route.Body.(type) {
+	case nil:
+	case string:
+		content = []byte(b)
+	default:
+		content, _ = json.Marshal(b)
+		w.Header().Set("Content-Type", "application/json")
+	}
+	for k, v := range route.Headers {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(content)
+}
+
+func (s *server) match(r *http.Request) *Route {
+	for i := range s.routes {
+		route := &s.routes[i]
+		if route.Method != "" && !strings.EqualFold(route.Method, r.Method) {
+			continue
+		}
+		if ok, _ := path.Match(route.Path, r.URL.Path); ok {
+			return route
+		}
+	}
+	return nil
+}
+
+// recordedRequests returns the received requests as generic values, so that JSON assertions can be used on them
+func (s *server) recordedRequests() ([]interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, err := json.Marshal(s.requests)
+	if err != nil {
+		return nil, err
+	}
+	requests := []interface{}{}
+	if err := venom.JSONUnmarshal(b, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (s *server) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = nil
+}
+
+func (s *server) stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.httpSrv.Shutdown(ctx)
+}
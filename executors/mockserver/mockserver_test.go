@@ -0,0 +1,126 @@
+package mockserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+func TestExecutor_Run(t *testing.T) {
+	venom.InitTestLogger(t)
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+	defer e.TearDown(ctx)
+
+	res, err := e.Run(ctx, venom.TestStep{
+		"action": "start",
+		"routes": []interface{}{
+			map[string]interface{}{
+				"method":  "POST",
+				"path":    "/hook",
+				"status":  201,
+				"headers": map[string]interface{}{"X-Mock": "yes"},
+				"body":    map[string]interface{}{"ok": true},
+			},
+			map[string]interface{}{
+				"path":    "/slow/*",
+				"body":    "slow",
+				"latency": 100,
+			},
+		},
+	})
+	require.NoError(t, err)
+	url := res.(Result).URL
+	require.NotEmpty(t, url)
+	assert.NotZero(t, res.(Result).Port)
+
+	resp, err := http.Post(url+"/hook?id=1", "application/json", strings.NewReader(`{"event":"created"}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "yes", resp.Header.Get("X-Mock"))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"ok":true}`, string(body))
+
+	start := time.Now()
+	resp, err = http.Get(url + "/slow/one")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	resp, err = http.Get(url + "/hook")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 404, resp.StatusCode)
+
+	res, err = e.Run(ctx, venom.TestStep{"action": "requests"})
+	require.NoError(t, err)
+	requests := res.(Result).Requests
+	require.Len(t, requests, 3)
+	first := requests[0].(map[string]interface{})
+	assert.Equal(t, "POST", first["method"])
+	assert.Equal(t, "/hook", first["path"])
+	assert.Equal(t, map[string]interface{}{"id": "1"}, first["query"])
+	assert.Equal(t, map[string]interface{}{"event": "created"}, first["bodyjson"])
+	assert.Equal(t, true, first["matched"])
+	assert.Equal(t, false, requests[2].(map[string]interface{})["matched"])
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "reset"})
+	require.NoError(t, err)
+	res, err = e.Run(ctx, venom.TestStep{"action": "requests"})
+	require.NoError(t, err)
+	assert.Empty(t, res.(Result).Requests)
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "start"})
+	assert.Error(t, err, "server is already started")
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "stop"})
+	require.NoError(t, err)
+	_, err = http.Get(url + "/hook")
+	assert.Error(t, err)
+}
+
+func TestExecutor_TearDown(t *testing.T) {
+	venom.InitTestLogger(t)
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "start", "server": "a"})
+	require.NoError(t, err)
+	// outside of a testsuite, the servers of the testsuite scope are stopped with the testcase
+	_, err = e.Run(ctx, venom.TestStep{"action": "start", "server": "b", "scope": "testsuite"})
+	require.NoError(t, err)
+	_, err = e.Run(ctx, venom.TestStep{"action": "start", "server": "b"})
+	assert.EqualError(t, err, `mock server "b" is already started`)
+
+	require.Len(t, getMockServerCtx(ctx).testcase.servers, 2)
+	require.NoError(t, e.TearDown(ctx))
+	assert.Empty(t, getMockServerCtx(ctx).testcase.servers)
+}
+
+func TestExecutor_Run_InvalidAction(t *testing.T) {
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "restart"})
+	assert.EqualError(t, err, `action "restart" must be start, requests, reset or stop`)
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "requests"})
+	assert.EqualError(t, err, `mock server "default" is not started`)
+
+	_, err = e.Run(ctx, venom.TestStep{"action": "start", "scope": "forever"})
+	assert.EqualError(t, err, `scope "forever" must be testcase or testsuite`)
+}
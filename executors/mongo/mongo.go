@@ -3,14 +3,18 @@ package mongo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/yaml.v3"
@@ -20,6 +24,14 @@ import (
 
 const Name = "mongo"
 
+// defaultWatchDuration is the duration of the capture of the change stream events. In Milliseconds
+const defaultWatchDuration = 1000
+
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
 func New() venom.Executor {
 	return &Executor{}
 }
@@ -29,10 +41,17 @@ type Executor struct {
 	Database   string           `json:"database,omitempty" yaml:"database,omitempty"`
 	Collection string           `json:"collection,omitempty" yaml:"collection,omitempty"`
 	Actions    []map[string]any `json:"actions,omitempty" yaml:"actions,omitempty"`
+	// PoolSize is the maximum number of connections opened to the database, used when the first step connects to the URI
+	PoolSize int `json:"pool_size,omitempty" yaml:"pool_size,omitempty" mapstructure:"pool_size"`
+	// IdleTimeout closes the connections unused for this duration, used when the first step connects to the URI. In Seconds
+	IdleTimeout int `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty" mapstructure:"idle_timeout"`
+	// WaitUntil runs the actions again until the result matches its assertions
+	WaitUntil *venom.WaitUntil `json:"wait_until,omitempty" yaml:"wait_until,omitempty" mapstructure:"wait_until"`
 }
 
 type Result struct {
-	Actions []map[string]any `json:"actions,omitempty" yaml:"actions,omitempty"`
+	Actions   []map[string]any       `json:"actions,omitempty" yaml:"actions,omitempty"`
+	WaitUntil *venom.WaitUntilResult `json:"wait_until,omitempty" yaml:"wait_until,omitempty"`
 }
 
 func (e Executor) Run(ctx context.Context, step venom.TestStep) (any, error) {
@@ -40,308 +59,614 @@ func (e Executor) Run(ctx context.Context, step venom.TestStep) (any, error) {
 		return nil, err
 	}
 
-	venom.Debug(ctx, "connecting to database: %s\n", e.URI)
-	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(e.URI))
+	mongoClient, pooled, err := e.connect(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
+	}
+	if !pooled {
+		defer mongoClient.Disconnect(ctx)
 	}
-	defer mongoClient.Disconnect(ctx)
 
-	results := make([]map[string]any, len(e.Actions))
+	if e.WaitUntil == nil {
+		return e.runActions(ctx, mongoClient)
+	}
+	r, polling, err := e.WaitUntil.Poll(ctx, func() (any, error) {
+		return e.runActions(ctx, mongoClient)
+	})
+	if err != nil && !errors.Is(err, venom.ErrWaitUntilTimeout) {
+		return nil, err
+	}
+	result := r.(Result)
+	result.WaitUntil = &polling
+	if err != nil {
+		// the last result is reported with the timeout
+		return nil, &venom.ErrorWithResult{Result: result, Err: err}
+	}
+	return result, nil
+}
 
+// runActions runs the actions of the step and returns their results
+func (e Executor) runActions(ctx context.Context, mongoClient *mongo.Client) (Result, error) {
+	results := make([]map[string]any, len(e.Actions))
 	for i, action := range e.Actions {
-		actionType := fmt.Sprintf("%v", action["type"])
-		switch actionType {
-		case "loadFixtures":
-			var loadFixturesAction LoadFixturesAction
-			if err := mapstructure.Decode(action, &loadFixturesAction); err != nil {
-				return nil, err
-			}
+		result, err := e.runAction(ctx, mongoClient, action)
+		if err != nil {
+			return Result{}, err
+		}
+		results[i] = result
+	}
+	return Result{Actions: results}, nil
+}
 
-			if loadFixturesAction.Folder == "" {
-				return nil, fmt.Errorf("folder is required")
-			}
+// runAction runs an action and returns its result, the actions modifying the database may have none
+func (e Executor) runAction(ctx context.Context, mongoClient *mongo.Client, action map[string]any) (map[string]any, error) {
+	actionType := fmt.Sprintf("%v", action["type"])
+	switch actionType {
+	case "loadFixtures":
+		var loadFixturesAction LoadFixturesAction
+		if err := mapstructure.Decode(action, &loadFixturesAction); err != nil {
+			return nil, err
+		}
 
-			// First, drop the existing collections in the database to start clean
-			collections, err := mongoClient.Database(e.Database).ListCollectionNames(ctx, bson.M{})
-			if err != nil {
-				return nil, fmt.Errorf("failed to list collections: %w", err)
-			}
+		if loadFixturesAction.Folder == "" {
+			return nil, fmt.Errorf("folder is required")
+		}
 
-			for _, collection := range collections {
-				if strings.HasPrefix(collection, "system.") {
-					continue
-				}
+		// First, drop the existing collections in the database to start clean
+		collections, err := mongoClient.Database(e.Database).ListCollectionNames(ctx, bson.M{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections: %w", err)
+		}
 
-				if err := mongoClient.Database(e.Database).Collection(collection).Drop(ctx); err != nil {
-					return nil, fmt.Errorf("failed to drop collection %s: %w", collection, err)
-				}
+		for _, collection := range collections {
+			if strings.HasPrefix(collection, "system.") {
+				continue
 			}
 
-			dirEntries, err := os.ReadDir(path.Join(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), loadFixturesAction.Folder))
-			if err != nil {
-				return nil, err
+			if err := mongoClient.Database(e.Database).Collection(collection).Drop(ctx); err != nil {
+				return nil, fmt.Errorf("failed to drop collection %s: %w", collection, err)
 			}
+		}
 
-			fixtures := make([]string, 0, len(dirEntries))
-			for _, file := range dirEntries {
-				if file.IsDir() {
-					continue
-				}
+		dirEntries, err := os.ReadDir(path.Join(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), loadFixturesAction.Folder))
+		if err != nil {
+			return nil, err
+		}
 
-				extension := path.Ext(file.Name())
-				if extension != ".yaml" && extension != ".yml" {
-					continue
-				}
-				fixtures = append(fixtures, file.Name())
+		fixtures := make([]string, 0, len(dirEntries))
+		for _, file := range dirEntries {
+			if file.IsDir() {
+				continue
 			}
 
-			for _, fixture := range fixtures {
-				collectionName := strings.TrimSuffix(fixture, path.Ext(fixture))
-				filePath := path.Join(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), loadFixturesAction.Folder, fixture)
-
-				file, err := os.Open(filePath)
-				if err != nil {
-					return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
-				}
+			extension := path.Ext(file.Name())
+			if extension != ".yaml" && extension != ".yml" {
+				continue
+			}
+			fixtures = append(fixtures, file.Name())
+		}
 
-				var documents []any
-				if err := yaml.NewDecoder(file).Decode(&documents); err != nil {
-					return nil, fmt.Errorf("failed to decode fixture %s: %w", filePath, err)
-				}
+		for _, fixture := range fixtures {
+			collectionName := strings.TrimSuffix(fixture, path.Ext(fixture))
+			filePath := path.Join(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), loadFixturesAction.Folder, fixture)
 
-				if _, err := mongoClient.Database(e.Database).Collection(collectionName).InsertMany(ctx, documents); err != nil {
-					return nil, fmt.Errorf("failed to insert documents in collection %s: %w", collectionName, err)
-				}
+			file, err := os.Open(filePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
 			}
 
-		case "dropCollection":
-			if err := mongoClient.Database(e.Database).Collection(e.Collection).Drop(ctx); err != nil {
-				return nil, err
+			var documents []any
+			if err := yaml.NewDecoder(file).Decode(&documents); err != nil {
+				return nil, fmt.Errorf("failed to decode fixture %s: %w", filePath, err)
 			}
 
-		case "createCollection":
-			if err := mongoClient.Database(e.Database).CreateCollection(ctx, e.Collection); err != nil {
-				return nil, err
+			if _, err := mongoClient.Database(e.Database).Collection(collectionName).InsertMany(ctx, documents); err != nil {
+				return nil, fmt.Errorf("failed to insert documents in collection %s: %w", collectionName, err)
 			}
+		}
 
-		case "count":
-			var countAction CountAction
-			if err := mapstructure.Decode(action, &countAction); err != nil {
-				return nil, err
-			}
+	case "dropCollection":
+		if err := mongoClient.Database(e.Database).Collection(e.Collection).Drop(ctx); err != nil {
+			return nil, err
+		}
 
-			filter := bson.M{}
-			if countAction.Filter != "" {
-				if err := bson.UnmarshalExtJSON([]byte(countAction.Filter), false, &filter); err != nil {
-					return nil, err
-				}
-			}
+	case "createCollection":
+		if err := mongoClient.Database(e.Database).CreateCollection(ctx, e.Collection); err != nil {
+			return nil, err
+		}
 
-			var countOptions []*options.CountOptions
-			if countAction.Options.Limit != nil {
-				countOptions = append(countOptions, options.Count().SetLimit(*countAction.Options.Limit))
-			}
+	case "count":
+		var countAction CountAction
+		if err := mapstructure.Decode(action, &countAction); err != nil {
+			return nil, err
+		}
 
-			count, err := mongoClient.Database(e.Database).Collection(e.Collection).CountDocuments(ctx, filter, countOptions...)
-			if err != nil {
+		filter := bson.M{}
+		if countAction.Filter != "" {
+			if err := bson.UnmarshalExtJSON([]byte(countAction.Filter), false, &filter); err != nil {
 				return nil, err
 			}
+		}
 
-			results[i] = map[string]any{"count": count}
+		var countOptions []*options.CountOptions
+		if countAction.Options.Limit != nil {
+			countOptions = append(countOptions, options.Count().SetLimit(*countAction.Options.Limit))
+		}
 
-		case "insert":
-			var insertAction InsertAction
-			if err := mapstructure.Decode(action, &insertAction); err != nil {
-				return nil, err
-			}
+		count, err := mongoClient.Database(e.Database).Collection(e.Collection).CountDocuments(ctx, filter, countOptions...)
+		if err != nil {
+			return nil, err
+		}
 
-			var documents []any
+		return map[string]any{"count": count}, nil
 
-			if insertAction.File != "" {
-				filePath := path.Join(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), insertAction.File)
-				file, err := os.Open(filePath)
-				if err != nil {
-					return nil, err
-				}
+	case "insert":
+		var insertAction InsertAction
+		if err := mapstructure.Decode(action, &insertAction); err != nil {
+			return nil, err
+		}
 
-				decoder := json.NewDecoder(file)
-				for {
-					var documentJSON any
-					if err := decoder.Decode(&documentJSON); err != nil {
-						if err == io.EOF {
-							break
-						}
-						return nil, err
-					}
+		var documents []any
 
-					documentBytes, err := json.Marshal(documentJSON)
-					if err != nil {
-						return nil, err
-					}
+		if insertAction.File != "" {
+			filePath := path.Join(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), insertAction.File)
+			file, err := os.Open(filePath)
+			if err != nil {
+				return nil, err
+			}
 
-					var document bson.M
-					if err := bson.UnmarshalExtJSON(documentBytes, false, &document); err != nil {
-						return nil, err
+			decoder := json.NewDecoder(file)
+			for {
+				var documentJSON any
+				if err := decoder.Decode(&documentJSON); err != nil {
+					if err == io.EOF {
+						break
 					}
-					documents = append(documents, document)
+					return nil, err
+				}
+
+				documentBytes, err := json.Marshal(documentJSON)
+				if err != nil {
+					return nil, err
 				}
-			}
 
-			for _, documentJSON := range insertAction.Documents {
 				var document bson.M
-				if err := bson.UnmarshalExtJSON([]byte(documentJSON), false, &document); err != nil {
+				if err := bson.UnmarshalExtJSON(documentBytes, false, &document); err != nil {
 					return nil, err
 				}
 				documents = append(documents, document)
 			}
+		}
 
-			insertManyResult, err := mongoClient.Database(e.Database).Collection(e.Collection).InsertMany(ctx, documents)
-			if err != nil {
+		for _, documentJSON := range insertAction.Documents {
+			var document bson.M
+			if err := bson.UnmarshalExtJSON([]byte(documentJSON), false, &document); err != nil {
 				return nil, err
 			}
+			documents = append(documents, document)
+		}
+
+		insertManyResult, err := mongoClient.Database(e.Database).Collection(e.Collection).InsertMany(ctx, documents)
+		if err != nil {
+			return nil, err
+		}
+
+		var result map[string]any
+		if err := mapstructure.Decode(insertManyResult, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
 
-			var result map[string]any
-			if err := mapstructure.Decode(insertManyResult, &result); err != nil {
+	case "find":
+		var findAction FindAction
+		if err := mapstructure.Decode(action, &findAction); err != nil {
+			return nil, err
+		}
+
+		filter := bson.M{}
+		if findAction.Filter != "" {
+			if err := bson.UnmarshalExtJSON([]byte(findAction.Filter), false, &filter); err != nil {
 				return nil, err
 			}
-			results[i] = result
+		}
 
-		case "find":
-			var findAction FindAction
-			if err := mapstructure.Decode(action, &findAction); err != nil {
+		var findOptions []*options.FindOptions
+		if findAction.Options.Limit != nil {
+			findOptions = append(findOptions, options.Find().SetLimit(*findAction.Options.Limit))
+		}
+		if findAction.Options.Skip != nil {
+			findOptions = append(findOptions, options.Find().SetSkip(*findAction.Options.Skip))
+		}
+		if findAction.Options.Sort != "" {
+			var sort bson.M
+			if err := bson.UnmarshalExtJSON([]byte(findAction.Options.Sort), false, &sort); err != nil {
 				return nil, err
 			}
-
-			filter := bson.M{}
-			if findAction.Filter != "" {
-				if err := bson.UnmarshalExtJSON([]byte(findAction.Filter), false, &filter); err != nil {
-					return nil, err
-				}
+			findOptions = append(findOptions, options.Find().SetSort(sort))
+		}
+		if findAction.Options.Projection != "" {
+			var projection bson.M
+			if err := bson.UnmarshalExtJSON([]byte(findAction.Options.Projection), false, &projection); err != nil {
+				return nil, err
 			}
+			findOptions = append(findOptions, options.Find().SetProjection(projection))
+		}
 
-			var findOptions []*options.FindOptions
-			if findAction.Options.Limit != nil {
-				findOptions = append(findOptions, options.Find().SetLimit(*findAction.Options.Limit))
-			}
-			if findAction.Options.Skip != nil {
-				findOptions = append(findOptions, options.Find().SetSkip(*findAction.Options.Skip))
-			}
-			if findAction.Options.Sort != "" {
-				var sort bson.M
-				if err := bson.UnmarshalExtJSON([]byte(findAction.Options.Sort), false, &sort); err != nil {
-					return nil, err
-				}
-				findOptions = append(findOptions, options.Find().SetSort(sort))
-			}
-			if findAction.Options.Projection != "" {
-				var projection bson.M
-				if err := bson.UnmarshalExtJSON([]byte(findAction.Options.Projection), false, &projection); err != nil {
-					return nil, err
-				}
-				findOptions = append(findOptions, options.Find().SetProjection(projection))
-			}
+		cursor, err := mongoClient.Database(e.Database).Collection(e.Collection).Find(ctx, filter, findOptions...)
+		if err != nil {
+			return nil, err
+		}
 
-			cursor, err := mongoClient.Database(e.Database).Collection(e.Collection).Find(ctx, filter, findOptions...)
-			if err != nil {
+		var result []map[string]any
+		if err := cursor.All(ctx, &result); err != nil {
+			return nil, err
+		}
+		return map[string]any{"results": result}, nil
+
+	case "update":
+		var updateAction UpdateAction
+		if err := mapstructure.Decode(action, &updateAction); err != nil {
+			return nil, err
+		}
+
+		filter := bson.M{}
+		if updateAction.Filter != "" {
+			if err := bson.UnmarshalExtJSON([]byte(updateAction.Filter), false, &filter); err != nil {
 				return nil, err
 			}
+		}
 
-			var result []map[string]any
-			if err := cursor.All(ctx, &result); err != nil {
+		update := bson.M{}
+		if updateAction.Update != "" {
+			if err := bson.UnmarshalExtJSON([]byte(updateAction.Update), false, &update); err != nil {
 				return nil, err
 			}
-			results[i] = map[string]any{"results": result}
+		}
+
+		var updateOptions []*options.UpdateOptions
+		if updateAction.Options.Upsert != nil {
+			updateOptions = append(updateOptions, options.Update().SetUpsert(*updateAction.Options.Upsert))
+		}
+
+		updateResult, err := mongoClient.Database(e.Database).Collection(e.Collection).UpdateMany(ctx, filter, update, updateOptions...)
+		if err != nil {
+			return nil, err
+		}
+
+		var result map[string]any
+		if err := mapstructure.Decode(updateResult, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
 
-		case "update":
-			var updateAction UpdateAction
-			if err := mapstructure.Decode(action, &updateAction); err != nil {
+	case "delete":
+		var deleteAction DeleteAction
+		if err := mapstructure.Decode(action, &deleteAction); err != nil {
+			return nil, err
+		}
+
+		filter := bson.M{}
+		if deleteAction.Filter != "" {
+			if err := bson.UnmarshalExtJSON([]byte(deleteAction.Filter), false, &filter); err != nil {
 				return nil, err
 			}
+		}
 
-			filter := bson.M{}
-			if updateAction.Filter != "" {
-				if err := bson.UnmarshalExtJSON([]byte(updateAction.Filter), false, &filter); err != nil {
-					return nil, err
-				}
-			}
+		deleteResult, err := mongoClient.Database(e.Database).Collection(e.Collection).DeleteMany(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
 
-			update := bson.M{}
-			if updateAction.Update != "" {
-				if err := bson.UnmarshalExtJSON([]byte(updateAction.Update), false, &update); err != nil {
-					return nil, err
-				}
-			}
+		var result map[string]any
+		if err := mapstructure.Decode(deleteResult, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
 
-			var updateOptions []*options.UpdateOptions
-			if updateAction.Options.Upsert != nil {
-				updateOptions = append(updateOptions, options.Update().SetUpsert(*updateAction.Options.Upsert))
-			}
+	case "aggregate":
+		var aggregateAction AggregateAction
+		if err := mapstructure.Decode(action, &aggregateAction); err != nil {
+			return nil, err
+		}
 
-			updateResult, err := mongoClient.Database(e.Database).Collection(e.Collection).UpdateMany(ctx, filter, update, updateOptions...)
-			if err != nil {
+		pipeline := bson.A{}
+		for _, pipelineItemJSON := range aggregateAction.Pipeline {
+			var pipelineItem bson.M
+			if err := bson.UnmarshalExtJSON([]byte(pipelineItemJSON), false, &pipelineItem); err != nil {
 				return nil, err
 			}
+			pipeline = append(pipeline, pipelineItem)
+		}
+
+		cursor, err := mongoClient.Database(e.Database).Collection(e.Collection).Aggregate(ctx, pipeline)
+		if err != nil {
+			return nil, err
+		}
 
-			var result map[string]any
-			if err := mapstructure.Decode(updateResult, &result); err != nil {
+		var result []map[string]any
+		if err := cursor.All(ctx, &result); err != nil {
+			return nil, err
+		}
+		return map[string]any{"results": result}, nil
+
+	case "distinct":
+		var distinctAction DistinctAction
+		if err := mapstructure.Decode(action, &distinctAction); err != nil {
+			return nil, err
+		}
+
+		filter := bson.M{}
+		if err := unmarshalExtJSON(distinctAction.Filter, &filter); err != nil {
+			return nil, err
+		}
+
+		values, err := mongoClient.Database(e.Database).Collection(e.Collection).Distinct(ctx, distinctAction.Field, filter)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"values": values}, nil
+
+	case "findOneAndUpdate":
+		var findOneAndUpdateAction FindOneAndUpdateAction
+		if err := mapstructure.Decode(action, &findOneAndUpdateAction); err != nil {
+			return nil, err
+		}
+
+		filter := bson.M{}
+		if err := unmarshalExtJSON(findOneAndUpdateAction.Filter, &filter); err != nil {
+			return nil, err
+		}
+		update := bson.M{}
+		if err := unmarshalExtJSON(findOneAndUpdateAction.Update, &update); err != nil {
+			return nil, err
+		}
+
+		findOneAndUpdateOptions := options.FindOneAndUpdate()
+		if findOneAndUpdateAction.Options.Upsert != nil {
+			findOneAndUpdateOptions.SetUpsert(*findOneAndUpdateAction.Options.Upsert)
+		}
+		switch findOneAndUpdateAction.Options.ReturnDocument {
+		case "", "before":
+		case "after":
+			findOneAndUpdateOptions.SetReturnDocument(options.After)
+		default:
+			return nil, fmt.Errorf("invalid returnDocument %q: expected before or after", findOneAndUpdateAction.Options.ReturnDocument)
+		}
+		if findOneAndUpdateAction.Options.Sort != "" {
+			var sort bson.D
+			if err := unmarshalExtJSON(findOneAndUpdateAction.Options.Sort, &sort); err != nil {
 				return nil, err
 			}
-			results[i] = result
-
-		case "delete":
-			var deleteAction DeleteAction
-			if err := mapstructure.Decode(action, &deleteAction); err != nil {
+			findOneAndUpdateOptions.SetSort(sort)
+		}
+		if findOneAndUpdateAction.Options.Projection != "" {
+			var projection bson.M
+			if err := unmarshalExtJSON(findOneAndUpdateAction.Options.Projection, &projection); err != nil {
 				return nil, err
 			}
+			findOneAndUpdateOptions.SetProjection(projection)
+		}
 
-			filter := bson.M{}
-			if deleteAction.Filter != "" {
-				if err := bson.UnmarshalExtJSON([]byte(deleteAction.Filter), false, &filter); err != nil {
-					return nil, err
-				}
-			}
+		var document map[string]any
+		err := mongoClient.Database(e.Database).Collection(e.Collection).FindOneAndUpdate(ctx, filter, update, findOneAndUpdateOptions).Decode(&document)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		return map[string]any{"document": document}, nil
 
-			deleteResult, err := mongoClient.Database(e.Database).Collection(e.Collection).DeleteMany(ctx, filter)
+	case "bulkWrite":
+		var bulkWriteAction BulkWriteAction
+		if err := mapstructure.Decode(action, &bulkWriteAction); err != nil {
+			return nil, err
+		}
+
+		models := make([]mongo.WriteModel, len(bulkWriteAction.Operations))
+		for i, operation := range bulkWriteAction.Operations {
+			model, err := operation.writeModel()
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("bulkWrite operation #%d: %w", i, err)
 			}
+			models[i] = model
+		}
+
+		bulkWriteOptions := options.BulkWrite()
+		if bulkWriteAction.Options.Ordered != nil {
+			bulkWriteOptions.SetOrdered(*bulkWriteAction.Options.Ordered)
+		}
+
+		bulkWriteResult, err := mongoClient.Database(e.Database).Collection(e.Collection).BulkWrite(ctx, models, bulkWriteOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		var result map[string]any
+		if err := mapstructure.Decode(bulkWriteResult, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	case "createIndex":
+		var createIndexAction CreateIndexAction
+		if err := mapstructure.Decode(action, &createIndexAction); err != nil {
+			return nil, err
+		}
 
-			var result map[string]any
-			if err := mapstructure.Decode(deleteResult, &result); err != nil {
+		// the order of the keys matters in a compound index
+		var keys bson.D
+		if err := unmarshalExtJSON(createIndexAction.Keys, &keys); err != nil {
+			return nil, err
+		}
+
+		indexOptions := options.Index()
+		if createIndexAction.Options.Name != "" {
+			indexOptions.SetName(createIndexAction.Options.Name)
+		}
+		if createIndexAction.Options.Unique != nil {
+			indexOptions.SetUnique(*createIndexAction.Options.Unique)
+		}
+		if createIndexAction.Options.Sparse != nil {
+			indexOptions.SetSparse(*createIndexAction.Options.Sparse)
+		}
+		if createIndexAction.Options.ExpireAfterSeconds != nil {
+			indexOptions.SetExpireAfterSeconds(*createIndexAction.Options.ExpireAfterSeconds)
+		}
+		if createIndexAction.Options.PartialFilterExpression != "" {
+			var partialFilterExpression bson.M
+			if err := unmarshalExtJSON(createIndexAction.Options.PartialFilterExpression, &partialFilterExpression); err != nil {
 				return nil, err
 			}
-			results[i] = result
+			indexOptions.SetPartialFilterExpression(partialFilterExpression)
+		}
 
-		case "aggregate":
-			var aggregateAction AggregateAction
-			if err := mapstructure.Decode(action, &aggregateAction); err != nil {
-				return nil, err
+		name, err := mongoClient.Database(e.Database).Collection(e.Collection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: indexOptions})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"name": name}, nil
+
+	case "listIndexes":
+		cursor, err := mongoClient.Database(e.Database).Collection(e.Collection).Indexes().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var indexes []map[string]any
+		if err := cursor.All(ctx, &indexes); err != nil {
+			return nil, err
+		}
+		return map[string]any{"indexes": indexes}, nil
+
+	case "transaction":
+		var transactionAction TransactionAction
+		if err := mapstructure.Decode(action, &transactionAction); err != nil {
+			return nil, err
+		}
+		for i, a := range transactionAction.Actions {
+			if actionType := fmt.Sprintf("%v", a["type"]); actionType == "transaction" || actionType == "watch" {
+				return nil, fmt.Errorf("transaction action #%d: %s can't run in a transaction", i, actionType)
 			}
+		}
 
-			pipeline := bson.A{}
-			for _, pipelineItemJSON := range aggregateAction.Pipeline {
-				var pipelineItem bson.M
-				if err := bson.UnmarshalExtJSON([]byte(pipelineItemJSON), false, &pipelineItem); err != nil {
-					return nil, err
+		session, err := mongoClient.StartSession()
+		if err != nil {
+			return nil, err
+		}
+		defer session.EndSession(ctx)
+
+		// the actions are retried by the driver on transient transaction errors
+		results, err := session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (any, error) {
+			results := make([]map[string]any, len(transactionAction.Actions))
+			for i, a := range transactionAction.Actions {
+				// the actions of a transaction can use another collection than the step
+				actionExecutor := e
+				if collection, ok := a["collection"].(string); ok && collection != "" {
+					actionExecutor.Collection = collection
+				}
+				result, err := actionExecutor.runAction(sessionCtx, mongoClient, a)
+				if err != nil {
+					return nil, fmt.Errorf("transaction action #%d: %w", i, err)
 				}
-				pipeline = append(pipeline, pipelineItem)
+				results[i] = result
 			}
+			return results, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"results": results}, nil
 
-			cursor, err := mongoClient.Database(e.Database).Collection(e.Collection).Aggregate(ctx, pipeline)
-			if err != nil {
-				return nil, err
-			}
+	case "watch":
+		var watchAction WatchAction
+		if err := mapstructure.Decode(action, &watchAction); err != nil {
+			return nil, err
+		}
+		return e.watch(ctx, mongoClient, watchAction)
 
-			var result []map[string]any
-			if err := cursor.All(ctx, &result); err != nil {
-				return nil, err
-			}
-			results[i] = map[string]any{"results": result}
+	default:
+		return nil, fmt.Errorf("unknown action type %q", actionType)
+	}
+
+	return nil, nil
+}
+
+// watch captures the change stream events of the collection, or of the database without collection,
+// until the duration expires or the maximum number of events is reached
+func (e Executor) watch(ctx context.Context, mongoClient *mongo.Client, watchAction WatchAction) (map[string]any, error) {
+	pipeline := bson.A{}
+	for _, pipelineItemJSON := range watchAction.Pipeline {
+		var pipelineItem bson.M
+		if err := bson.UnmarshalExtJSON([]byte(pipelineItemJSON), false, &pipelineItem); err != nil {
+			return nil, err
 		}
+		pipeline = append(pipeline, pipelineItem)
 	}
 
-	return Result{Actions: results}, nil
+	changeStreamOptions := options.ChangeStream()
+	if watchAction.FullDocument != "" {
+		changeStreamOptions.SetFullDocument(options.FullDocument(watchAction.FullDocument))
+	}
+	if watchAction.StartAtOperationTime != "" {
+		startAt, err := parseOperationTime(watchAction.StartAtOperationTime)
+		if err != nil {
+			return nil, err
+		}
+		changeStreamOptions.SetStartAtOperationTime(startAt)
+	}
+
+	var (
+		stream *mongo.ChangeStream
+		err    error
+	)
+	if e.Collection == "" {
+		stream, err = mongoClient.Database(e.Database).Watch(ctx, pipeline, changeStreamOptions)
+	} else {
+		stream, err = mongoClient.Database(e.Database).Collection(e.Collection).Watch(ctx, pipeline, changeStreamOptions)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close(ctx)
+
+	duration := time.Duration(watchAction.Duration) * time.Millisecond
+	if duration <= 0 {
+		duration = defaultWatchDuration * time.Millisecond
+	}
+	watchCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	events := []map[string]any{}
+	for (watchAction.MaxEvents <= 0 || len(events) < watchAction.MaxEvents) && stream.Next(watchCtx) {
+		var event map[string]any
+		if err := stream.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	// the stream stops with an error when the duration expires
+	if err := stream.Err(); err != nil && watchCtx.Err() == nil {
+		return nil, err
+	}
+	return map[string]any{"events": events}, nil
+}
+
+// parseOperationTime parses a RFC3339 date, or a number of seconds since the epoch
+func parseOperationTime(s string) (*primitive.Timestamp, error) {
+	if seconds, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return &primitive.Timestamp{T: uint32(seconds)}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid startAtOperationTime %q: expected a RFC3339 date or a number of seconds", s)
+	}
+	return &primitive.Timestamp{T: uint32(t.Unix())}, nil
+}
+
+// unmarshalExtJSON unmarshals an Extended JSON document, an empty string is an empty document
+func unmarshalExtJSON(s string, v any) error {
+	if s == "" {
+		return nil
+	}
+	return bson.UnmarshalExtJSON([]byte(s), false, v)
 }
 
 type Action struct {
@@ -396,3 +721,115 @@ type AggregateAction struct {
 	Action
 	Pipeline []string
 }
+
+type DistinctAction struct {
+	Action
+	Field  string
+	Filter string
+}
+
+type FindOneAndUpdateAction struct {
+	Action
+	Filter  string
+	Update  string
+	Options struct {
+		Upsert *bool
+		// ReturnDocument is before (default) or after the update
+		ReturnDocument string
+		Sort           string
+		Projection     string
+	}
+}
+
+type BulkWriteAction struct {
+	Action
+	Operations []BulkWriteOperation
+	Options    struct {
+		Ordered *bool
+	}
+}
+
+// BulkWriteOperation is an operation of a bulkWrite action:
+// insertOne, updateOne, updateMany, replaceOne, deleteOne or deleteMany
+type BulkWriteOperation struct {
+	Type        string
+	Document    string
+	Filter      string
+	Update      string
+	Replacement string
+	Upsert      *bool
+}
+
+func (o BulkWriteOperation) writeModel() (mongo.WriteModel, error) {
+	document, filter, update, replacement := bson.M{}, bson.M{}, bson.M{}, bson.M{}
+	if err := unmarshalExtJSON(o.Document, &document); err != nil {
+		return nil, err
+	}
+	if err := unmarshalExtJSON(o.Filter, &filter); err != nil {
+		return nil, err
+	}
+	if err := unmarshalExtJSON(o.Update, &update); err != nil {
+		return nil, err
+	}
+	if err := unmarshalExtJSON(o.Replacement, &replacement); err != nil {
+		return nil, err
+	}
+
+	switch o.Type {
+	case "insertOne":
+		return mongo.NewInsertOneModel().SetDocument(document), nil
+	case "updateOne":
+		model := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update)
+		if o.Upsert != nil {
+			model.SetUpsert(*o.Upsert)
+		}
+		return model, nil
+	case "updateMany":
+		model := mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(update)
+		if o.Upsert != nil {
+			model.SetUpsert(*o.Upsert)
+		}
+		return model, nil
+	case "replaceOne":
+		model := mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(replacement)
+		if o.Upsert != nil {
+			model.SetUpsert(*o.Upsert)
+		}
+		return model, nil
+	case "deleteOne":
+		return mongo.NewDeleteOneModel().SetFilter(filter), nil
+	case "deleteMany":
+		return mongo.NewDeleteManyModel().SetFilter(filter), nil
+	}
+	return nil, fmt.Errorf("unknown operation type %q", o.Type)
+}
+
+type CreateIndexAction struct {
+	Action
+	Keys    string
+	Options struct {
+		Name                    string
+		Unique                  *bool
+		Sparse                  *bool
+		ExpireAfterSeconds      *int32
+		PartialFilterExpression string
+	}
+}
+
+type TransactionAction struct {
+	Action
+	Actions []map[string]any
+}
+
+type WatchAction struct {
+	Action
+	Pipeline []string
+	// Duration of the capture of the events. In Milliseconds. Default 1000
+	Duration int
+	// MaxEvents stops the capture when this number of events is reached
+	MaxEvents int
+	// FullDocument is updateLookup to get the updated documents
+	FullDocument string
+	// StartAtOperationTime captures the events since this date, such as the start of the testcase
+	StartAtOperationTime string
+}
@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestBulkWriteOperation_writeModel(t *testing.T) {
+	upsert := true
+	model, err := BulkWriteOperation{Type: "updateOne", Filter: `{"n": 1}`, Update: `{"$set": {"n": 2}}`, Upsert: &upsert}.writeModel()
+	require.NoError(t, err)
+	updateOne := model.(*mongo.UpdateOneModel)
+	assert.Equal(t, bson.M{"n": int32(1)}, updateOne.Filter)
+	assert.Equal(t, bson.M{"$set": bson.M{"n": int32(2)}}, updateOne.Update)
+	assert.True(t, *updateOne.Upsert)
+
+	model, err = BulkWriteOperation{Type: "insertOne", Document: `{"_id": {"$oid": "636a7c57d044a5a54b0f8cf7"}}`}.writeModel()
+	require.NoError(t, err)
+	id, _ := primitive.ObjectIDFromHex("636a7c57d044a5a54b0f8cf7")
+	assert.Equal(t, bson.M{"_id": id}, model.(*mongo.InsertOneModel).Document)
+
+	_, err = BulkWriteOperation{Type: "insertMany"}.writeModel()
+	assert.Error(t, err)
+	_, err = BulkWriteOperation{Type: "deleteOne", Filter: "{"}.writeModel()
+	assert.Error(t, err)
+}
+
+func TestParseOperationTime(t *testing.T) {
+	ts, err := parseOperationTime("1700000000")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1700000000), ts.T)
+
+	ts, err = parseOperationTime("2023-11-14T22:13:20Z")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1700000000), ts.T)
+
+	_, err = parseOperationTime("yesterday")
+	assert.Error(t, err)
+}
+
+func TestExecutor_runAction_UnknownType(t *testing.T) {
+	_, err := Executor{Collection: "cards"}.runAction(context.Background(), nil, map[string]any{"type": "upsert"})
+	assert.EqualError(t, err, `unknown action type "upsert"`)
+}
+
+func TestExecutor_runAction_NestedTransaction(t *testing.T) {
+	for _, actionType := range []string{"transaction", "watch"} {
+		_, err := Executor{Collection: "cards"}.runAction(context.Background(), nil, map[string]any{
+			"type":    "transaction",
+			"actions": []map[string]any{{"type": "insert"}, {"type": actionType}},
+		})
+		assert.EqualError(t, err, "transaction action #1: "+actionType+" can't run in a transaction")
+	}
+}
@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ovh/venom"
+)
+
+// ContextKey is the key used to store the clients in the context
+const ContextKey = venom.ContextKey("mongoContext")
+
+// clients holds a client, and its connection pool, by URI
+type clients struct {
+	mutex   sync.Mutex
+	clients map[string]*mongo.Client
+}
+
+type mongoContext struct {
+	clients *clients
+	// owned is true when the clients are not shared with the other testcases of the testsuite
+	owned bool
+}
+
+// Setup gets the clients shared by the testcases of the testsuite
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	if c := venom.TestSuiteResource(ctx, string(ContextKey), func() io.Closer { return newClients() }); c != nil {
+		return context.WithValue(ctx, ContextKey, &mongoContext{clients: c.(*clients)}), nil
+	}
+	return context.WithValue(ctx, ContextKey, &mongoContext{clients: newClients(), owned: true}), nil
+}
+
+// TearDown disconnects the clients if they are not shared with the testsuite
+func (Executor) TearDown(ctx context.Context) error {
+	mongoCtx := getMongoCtx(ctx)
+	if mongoCtx == nil || !mongoCtx.owned {
+		return nil
+	}
+	return mongoCtx.clients.Close()
+}
+
+func getMongoCtx(ctx context.Context) *mongoContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*mongoContext)
+}
+
+func newClients() *clients {
+	return &clients{clients: map[string]*mongo.Client{}}
+}
+
+// connect returns the client of the URI of the step, it is created on first use with the pool settings of the step.
+// Without Setup, a new client is returned, it must be disconnected by the caller.
+func (e Executor) connect(ctx context.Context) (*mongo.Client, bool, error) {
+	mongoCtx := getMongoCtx(ctx)
+	if mongoCtx == nil {
+		client, err := e.newClient(ctx)
+		return client, false, err
+	}
+
+	c := mongoCtx.clients
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if client, ok := c.clients[e.URI]; ok {
+		return client, true, nil
+	}
+	client, err := e.newClient(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	c.clients[e.URI] = client
+	return client, true, nil
+}
+
+func (e Executor) newClient(ctx context.Context) (*mongo.Client, error) {
+	venom.Debug(ctx, "connecting to database: %s\n", e.URI)
+	opts := options.Client().ApplyURI(e.URI)
+	if e.PoolSize > 0 {
+		opts.SetMaxPoolSize(uint64(e.PoolSize))
+	}
+	if e.IdleTimeout > 0 {
+		opts.SetMaxConnIdleTime(time.Duration(e.IdleTimeout) * time.Second)
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return client, nil
+}
+
+// Close disconnects all the clients
+func (c *clients) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var errs []string
+	for uri, client := range c.clients {
+		if err := client.Disconnect(context.Background()); err != nil {
+			errs = append(errs, err.Error())
+		}
+		delete(c.clients, uri)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to disconnect from databases: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
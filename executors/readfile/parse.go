@@ -0,0 +1,240 @@
+package readfile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/rockbears/yaml"
+	"gopkg.in/ini.v1"
+
+	"github.com/ovh/venom"
+)
+
+// Formats of the files parsed by the executor
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+	formatCSV  = "csv"
+	formatXML  = "xml"
+	formatINI  = "ini"
+	// formatText files are not parsed
+	formatText = "text"
+)
+
+var formats = []string{formatJSON, formatYAML, formatTOML, formatCSV, formatXML, formatINI, formatText}
+
+// formatFromExtension returns the format of the file according to its extension, or an empty string if it is unknown
+func formatFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return formatJSON
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	case ".csv", ".tsv":
+		return formatCSV
+	case ".xml":
+		return formatXML
+	case ".ini":
+		return formatINI
+	}
+	return ""
+}
+
+// parse returns the content parsed according to its format, as it would be decoded from JSON: numbers are json.Number
+func parse(content []byte, format string, separator rune) (interface{}, error) {
+	var v interface{}
+	var err error
+	switch format {
+	case formatJSON:
+		err = venom.JSONUnmarshal(content, &v)
+		return v, err
+	case formatYAML:
+		content, err = yaml.YAMLToJSON(content)
+		if err != nil {
+			return nil, err
+		}
+		err = venom.JSONUnmarshal(content, &v)
+		return v, err
+	case formatTOML:
+		m := map[string]interface{}{}
+		err = toml.Unmarshal(content, &m)
+		v = m
+	case formatCSV:
+		v, err = parseCSV(content, separator)
+	case formatXML:
+		v, err = parseXML(content)
+	case formatINI:
+		v, err = parseINI(content)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The values are converted as if they were decoded from JSON, to be asserted as the JSON contents
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var converted interface{}
+	err = venom.JSONUnmarshal(b, &converted)
+	return converted, err
+}
+
+// parseCSV returns the rows of the file as maps, the first row is the header
+func parseCSV(content []byte, separator rune) ([]map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = separator
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := []map[string]string{}
+	if len(records) == 0 {
+		return rows, nil
+	}
+	header := columnNames(records[0])
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// columnNames returns the names of the CSV columns, so that no column is lost:
+// an empty name is replaced by "column" and the position of the column, starting at 1,
+// and a name already used is suffixed by "_" and the number of its occurrences.
+func columnNames(header []string) []string {
+	names := make([]string, len(header))
+	used := make(map[string]bool, len(header))
+	for i, name := range header {
+		if strings.TrimSpace(name) == "" {
+			name = fmt.Sprintf("column%d", i+1)
+		}
+		unique := name
+		for n := 2; used[unique]; n++ {
+			unique = fmt.Sprintf("%s_%d", name, n)
+		}
+		used[unique] = true
+		names[i] = unique
+	}
+	return names
+}
+
+// parseXML returns the root element of the document, by its name.
+// The attributes of an element are prefixed with "-", its text is "#text" if it has attributes or children,
+// and the children with the same name are a list.
+func parseXML(content []byte) (map[string]interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root, err := parseXMLElement(d, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: root}, nil
+		}
+	}
+}
+
+// parseXMLElement returns the text of the element if it has no attributes and no children, or a map
+func parseXMLElement(d *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	m := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		m["-"+attr.Name.Local] = attr.Value
+	}
+	var text strings.Builder
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := parseXMLElement(d, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := m[name].(type) {
+			case nil:
+				m[name] = child
+			case []interface{}:
+				m[name] = append(existing, child)
+			default:
+				m[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m["#text"] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// parseINI returns the keys of the file, the keys of a section are in a map named after the section
+func parseINI(content []byte) (map[string]interface{}, error) {
+	f, err := ini.Load(content)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	for _, section := range f.Sections() {
+		keys := m
+		if section.Name() != ini.DefaultSection {
+			keys = map[string]interface{}{}
+			m[section.Name()] = keys
+		}
+		for _, key := range section.Keys() {
+			keys[key.Name()] = key.Value()
+		}
+	}
+	return m, nil
+}
+
+// separatorRune returns the CSV separator: the separator option, or a tab for .tsv files, or a comma
+func separatorRune(separator, path string) (rune, error) {
+	if separator == "" {
+		if strings.EqualFold(filepath.Ext(path), ".tsv") {
+			return '\t', nil
+		}
+		return ',', nil
+	}
+	if separator == `\t` {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(separator)
+	if size != len(separator) {
+		return 0, fmt.Errorf("separator %q must be a single character", separator)
+	}
+	return r, nil
+}
@@ -0,0 +1,212 @@
+package readfile
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		separator rune
+		content   string
+		want      interface{}
+		wantErr   bool
+	}{
+		{
+			name:    "yaml",
+			format:  formatYAML,
+			content: "name: foo\nsize: 42\ntags: [a, b]\n",
+			want:    map[string]interface{}{"name": "foo", "size": json.Number("42"), "tags": []interface{}{"a", "b"}},
+		},
+		{
+			name:    "toml",
+			format:  formatTOML,
+			content: "name = \"foo\"\n[server]\nport = 8080\n",
+			want:    map[string]interface{}{"name": "foo", "server": map[string]interface{}{"port": json.Number("8080")}},
+		},
+		{
+			name:      "csv",
+			format:    formatCSV,
+			separator: ';',
+			content:   "name;size\nfoo;42\nbar;7\n",
+			want: []interface{}{
+				map[string]interface{}{"name": "foo", "size": "42"},
+				map[string]interface{}{"name": "bar", "size": "7"},
+			},
+		},
+		{
+			name:      "csv with duplicate and empty columns",
+			format:    formatCSV,
+			separator: ',',
+			content:   "name,,name, ,name_2,name\nfoo,1,bar,2,baz,qux\n",
+			want: []interface{}{
+				map[string]interface{}{"name": "foo", "column2": "1", "name_2": "bar", "column4": "2", "name_2_2": "baz", "name_3": "qux"},
+			},
+		},
+		{
+			name:      "csv with a header only",
+			format:    formatCSV,
+			separator: ',',
+			content:   "name,size\n",
+			want:      []interface{}{},
+		},
+		{
+			name:      "csv with a missing field",
+			format:    formatCSV,
+			separator: ',',
+			content:   "name,size\nfoo\n",
+			wantErr:   true,
+		},
+		{
+			name:    "xml attributes and text",
+			format:  formatXML,
+			content: `<order id="42"><item sku="a">apple</item><item>pear</item><note>fragile</note> paid </order>`,
+			want: map[string]interface{}{"order": map[string]interface{}{
+				"-id":   "42",
+				"item":  []interface{}{map[string]interface{}{"-sku": "a", "#text": "apple"}, "pear"},
+				"note":  "fragile",
+				"#text": "paid",
+			}},
+		},
+		{
+			name:    "xml empty element",
+			format:  formatXML,
+			content: `<?xml version="1.0"?><order><note/></order>`,
+			want:    map[string]interface{}{"order": map[string]interface{}{"note": ""}},
+		},
+		{
+			name:    "xml without root element",
+			format:  formatXML,
+			content: `<?xml version="1.0"?>`,
+			wantErr: true,
+		},
+		{
+			name:    "ini without sections",
+			format:  formatINI,
+			content: "name = foo\nsize = 42\n",
+			want:    map[string]interface{}{"name": "foo", "size": "42"},
+		},
+		{
+			name:    "ini with sections",
+			format:  formatINI,
+			content: "name = foo\n[server]\nport = 8080\n",
+			want:    map[string]interface{}{"name": "foo", "server": map[string]interface{}{"port": "8080"}},
+		},
+		{
+			name:    "unknown format",
+			format:  "png",
+			content: "foo",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse([]byte(tt.content), tt.format, tt.separator)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExecutor_parse(t *testing.T) {
+	venom.InitTestLogger(t)
+	tests := []struct {
+		name       string
+		format     string
+		path       string
+		content    string
+		want       interface{}
+		wantParsed bool
+		wantErr    bool
+	}{
+		{
+			name:       "format guessed from the extension",
+			path:       "data.tsv",
+			content:    "name\tsize\nfoo\t42\n",
+			want:       []interface{}{map[string]interface{}{"name": "foo", "size": "42"}},
+			wantParsed: true,
+		},
+		{
+			name:       "format overriding the extension",
+			format:     formatINI,
+			path:       "settings.json",
+			content:    "name = foo\n",
+			want:       map[string]interface{}{"name": "foo"},
+			wantParsed: true,
+		},
+		{
+			name:    "invalid content with a format",
+			format:  formatJSON,
+			path:    "settings.json",
+			content: "name = foo\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid content without a format",
+			path:    "settings.json",
+			content: "name = foo\n",
+		},
+		{
+			name:       "unknown extension parsed as JSON",
+			path:       "settings.conf",
+			content:    `{"name": "foo"}`,
+			want:       map[string]interface{}{"name": "foo"},
+			wantParsed: true,
+		},
+		{
+			name:    "text format",
+			format:  formatText,
+			path:    "settings.json",
+			content: `{"name": "foo"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Executor{Format: tt.format}
+			got, parsed, err := e.parse(context.Background(), []byte(tt.content), tt.path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantParsed, parsed)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSeparatorRune(t *testing.T) {
+	tests := []struct {
+		separator, path string
+		want            rune
+		wantErr         bool
+	}{
+		{path: "data.csv", want: ','},
+		{path: "data.TSV", want: '\t'},
+		{separator: ";", path: "data.tsv", want: ';'},
+		{separator: `\t`, path: "data.csv", want: '\t'},
+		{separator: "|", path: "data.csv", want: '|'},
+		{separator: ";;", path: "data.csv", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := separatorRune(tt.separator, tt.path)
+		if tt.wantErr {
+			assert.Error(t, err, tt.separator)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.separator)
+	}
+}
@@ -4,16 +4,14 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/rockbears/yaml"
-
 	"github.com/mattn/go-zglob"
 	"github.com/mitchellh/mapstructure"
 
@@ -31,18 +29,26 @@ func New() venom.Executor {
 // Executor represents a Test Exec
 type Executor struct {
 	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Format of the files: json, yaml, toml, csv, xml, ini or text. Default is guessed from the extension of the files
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Separator of the CSV files. Default is a comma, or a tab for .tsv files
+	Separator string `json:"separator,omitempty" yaml:"separator,omitempty"`
 }
 
 // Result represents a step result
 type Result struct {
-	Content     string            `json:"content,omitempty" yaml:"content,omitempty"`
-	ContentJSON interface{}       `json:"contentjson,omitempty" yaml:"contentjson,omitempty"`
-	Err         string            `json:"err" yaml:"error"`
-	TimeSeconds float64           `json:"timeseconds,omitempty" yaml:"timeSeconds,omitempty"`
-	Md5sum      map[string]string `json:"md5sum,omitempty" yaml:"md5sum,omitempty"`
-	Size        map[string]int64  `json:"size,omitempty" yaml:"size,omitempty"`
-	ModTime     map[string]int64  `json:"modtime,omitempty" yaml:"modtime,omitempty"`
-	Mod         map[string]string `json:"mod,omitempty" yaml:"mod,omitempty"`
+	Content     string      `json:"content,omitempty" yaml:"content,omitempty"`
+	ContentJSON interface{} `json:"contentjson,omitempty" yaml:"contentjson,omitempty"`
+	// ContentParsed is the content parsed according to the format, as ContentJSON
+	ContentParsed interface{}       `json:"contentparsed,omitempty" yaml:"contentparsed,omitempty"`
+	Err           string            `json:"err" yaml:"error"`
+	TimeSeconds   float64           `json:"timeseconds,omitempty" yaml:"timeSeconds,omitempty"`
+	Md5sum        map[string]string `json:"md5sum,omitempty" yaml:"md5sum,omitempty"`
+	Size          map[string]int64  `json:"size,omitempty" yaml:"size,omitempty"`
+	ModTime       map[string]int64  `json:"modtime,omitempty" yaml:"modtime,omitempty"`
+	Mod           map[string]string `json:"mod,omitempty" yaml:"mod,omitempty"`
+	// Parsed is the parsed content of each file
+	Parsed map[string]interface{} `json:"parsed,omitempty" yaml:"parsed,omitempty"`
 }
 
 // ZeroValueResult return an empty implementation of this executor result
@@ -52,6 +58,7 @@ func (Executor) ZeroValueResult() interface{} {
 		Size:    make(map[string]int64),
 		ModTime: make(map[string]int64),
 		Mod:     make(map[string]string),
+		Parsed:  make(map[string]interface{}),
 	}
 	return r
 }
@@ -71,6 +78,9 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 	if e.Path == "" {
 		return nil, fmt.Errorf("Invalid path")
 	}
+	if e.Format != "" && !slices.Contains(formats, e.Format) {
+		return nil, fmt.Errorf("format %q must be one of %s", e.Format, strings.Join(formats, ", "))
+	}
 
 	start := time.Now()
 
@@ -113,6 +123,10 @@ func (e *Executor) readfile(ctx context.Context, workdir string) (Result, error)
 	size := make(map[string]int64)
 	modtime := make(map[string]int64)
 	mod := make(map[string]string)
+	parsed := make(map[string]interface{})
+	// content parsed by the last iteration, used when there is only one file
+	var v interface{}
+	var ok bool
 
 	for _, f := range filesPath {
 		f, errOpening := os.Open(f)
@@ -145,6 +159,15 @@ func (e *Executor) readfile(ctx context.Context, workdir string) (Result, error)
 		size[relativeName] = stat.Size()
 		modtime[relativeName] = stat.ModTime().Unix()
 		mod[relativeName] = stat.Mode().String()
+
+		var err error
+		v, ok, err = e.parse(ctx, b, f.Name())
+		if err != nil {
+			return result, fmt.Errorf("error while parsing file %s: %s", relativeName, err)
+		}
+		if ok {
+			parsed[relativeName] = v
+		}
 	}
 
 	result.Content = content
@@ -152,23 +175,64 @@ func (e *Executor) readfile(ctx context.Context, workdir string) (Result, error)
 	result.Size = size
 	result.ModTime = modtime
 	result.Mod = mod
+	result.Parsed = parsed
 	result.ContentJSON = []map[string]string{}
 
-	var m interface{}
-	if strings.HasSuffix(e.Path, "yaml") || strings.HasSuffix(e.Path, "yml") {
-		venom.Debug(ctx, "trying to parse yaml file")
-		resp, errConvert := yaml.YAMLToJSON([]byte(content))
-		if errConvert != nil {
-			venom.Warn(ctx, "could not convert payload from file")
-			return result, nil
+	// The content of several files is parsed as a whole, if possible
+	if len(filesPath) > 1 {
+		v, ok = nil, false
+		if format := e.format(e.Path); format != formatText {
+			separator, err := separatorRune(e.Separator, e.Path)
+			if err != nil {
+				return result, err
+			}
+			v, err = parse([]byte(content), format, separator)
+			ok = err == nil
 		}
-		content = string(resp)
 	}
-	decoder := json.NewDecoder(strings.NewReader(content))
-	decoder.UseNumber()
-	if err := decoder.Decode(&m); err == nil {
-		result.ContentJSON = m
+	if ok {
+		result.ContentJSON = v
+		result.ContentParsed = v
 	}
 
 	return result, nil
 }
+
+// parse returns the content of the file parsed according to the format of the executor, or to the extension of the file.
+// The files with an unknown extension are parsed as JSON if possible. A parsing error is returned only if the format is set.
+func (e *Executor) parse(ctx context.Context, content []byte, path string) (interface{}, bool, error) {
+	format := e.format(path)
+	if format == formatText {
+		return nil, false, nil
+	}
+	separator, err := separatorRune(e.Separator, path)
+	if err != nil {
+		return nil, false, err
+	}
+	if e.Format == "" && formatFromExtension(path) == "" {
+		v, err := parse(content, format, separator)
+		return v, err == nil, nil
+	}
+
+	venom.Debug(ctx, "trying to parse %s file %s", format, path)
+	v, err := parse(content, format, separator)
+	if err != nil {
+		if e.Format != "" {
+			return nil, false, err
+		}
+		venom.Warn(ctx, "could not parse %s as %s: %v", path, format, err)
+		return nil, false, nil
+	}
+	return v, true, nil
+}
+
+// format returns the format of the executor, or the format guessed from the extension of the file, or JSON
+func (e *Executor) format(path string) string {
+	if e.Format != "" {
+		return e.Format
+	}
+	if format := formatFromExtension(path); format != "" {
+		return format
+	}
+	return formatJSON
+}
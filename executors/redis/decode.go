@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeResponse converts a response to strings, numbers, lists and maps.
+// The hashes are decoded into maps, and the sorted sets with their scores into lists of members and scores.
+func decodeResponse(name string, args []interface{}, res interface{}) interface{} {
+	switch strings.ToUpper(name) {
+	case "HGETALL":
+		return decodeMap(res)
+	case "XREAD", "XREADGROUP":
+		return decodeStreams(args, res)
+	case "ZPOPMIN", "ZPOPMAX":
+		return decodeScoredMembers(res)
+	case "ZRANGE", "ZREVRANGE", "ZRANGEBYSCORE", "ZREVRANGEBYSCORE", "ZRANDMEMBER", "ZUNION", "ZINTER", "ZDIFF":
+		for _, arg := range args {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, "WITHSCORES") {
+				return decodeScoredMembers(res)
+			}
+		}
+	}
+	return decodeValue(res)
+}
+
+// decodeValue converts the lists and the RESP3 maps recursively
+func decodeValue(res interface{}) interface{} {
+	switch v := res.(type) {
+	case nil, string, int64, float64, bool:
+		return v
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = decodeValue(v[i])
+		}
+		return values
+	case map[interface{}]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			values[fmt.Sprint(k)] = decodeValue(value)
+		}
+		return values
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// decodeMap converts the list of fields and values of a RESP2 hash to a map
+func decodeMap(res interface{}) interface{} {
+	list, ok := res.([]interface{})
+	if !ok || len(list)%2 != 0 {
+		return decodeValue(res)
+	}
+	values := make(map[string]interface{}, len(list)/2)
+	for i := 0; i < len(list); i += 2 {
+		values[fmt.Sprint(list[i])] = decodeValue(list[i+1])
+	}
+	return values
+}
+
+// decodeStreams converts the RESP3 map of the streams read by XREAD to the RESP2 list of streams and entries,
+// in the order of the STREAMS argument, so that the responses have the same shape with both protocols
+func decodeStreams(args []interface{}, res interface{}) interface{} {
+	streams, ok := res.(map[interface{}]interface{})
+	if !ok {
+		return decodeValue(res)
+	}
+	values := make([]interface{}, 0, len(streams))
+	for _, arg := range args {
+		if entries, ok := streams[arg]; ok {
+			values = append(values, []interface{}{decodeValue(arg), decodeValue(entries)})
+		}
+	}
+	if len(values) != len(streams) {
+		return decodeValue(res)
+	}
+	return values
+}
+
+// decodeScoredMembers converts the members and the scores of a sorted set to a list of maps.
+// They are a flat list of members and scores with RESP2, and a list of pairs with RESP3.
+func decodeScoredMembers(res interface{}) interface{} {
+	list, ok := res.([]interface{})
+	if !ok {
+		return decodeValue(res)
+	}
+	var pairs [][]interface{}
+	for i := 0; i < len(list); i++ {
+		if pair, ok := list[i].([]interface{}); ok && len(pair) == 2 {
+			pairs = append(pairs, pair)
+			continue
+		}
+		if i+1 >= len(list) {
+			return decodeValue(res)
+		}
+		pairs = append(pairs, []interface{}{list[i], list[i+1]})
+		i++
+	}
+
+	members := make([]interface{}, len(pairs))
+	for i, pair := range pairs {
+		members[i] = map[string]interface{}{
+			"member": decodeValue(pair[0]),
+			"score":  decodeScore(pair[1]),
+		}
+	}
+	return members
+}
+
+func decodeScore(score interface{}) interface{} {
+	if s, ok := score.(string); ok {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return decodeValue(score)
+}
@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ovh/venom"
+)
+
+// ContextKey is the key used to store the clients in the context
+const ContextKey = venom.ContextKey("redisContext")
+
+const (
+	clusterScheme  = "+cluster"
+	sentinelScheme = "+sentinel"
+)
+
+// clients holds a client, and its connection pool, by dial URL
+type clients struct {
+	mutex   sync.Mutex
+	clients map[string]redis.UniversalClient
+}
+
+type redisContext struct {
+	clients *clients
+	// owned is true when the clients are not shared with the other testcases of the testsuite
+	owned bool
+}
+
+// Setup gets the clients shared by the testcases of the testsuite
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	if c := venom.TestSuiteResource(ctx, string(ContextKey), func() io.Closer { return newClients() }); c != nil {
+		return context.WithValue(ctx, ContextKey, &redisContext{clients: c.(*clients)}), nil
+	}
+	return context.WithValue(ctx, ContextKey, &redisContext{clients: newClients(), owned: true}), nil
+}
+
+// TearDown closes the clients if they are not shared with the testsuite
+func (Executor) TearDown(ctx context.Context) error {
+	redisCtx := getRedisCtx(ctx)
+	if redisCtx == nil || !redisCtx.owned {
+		return nil
+	}
+	return redisCtx.clients.Close()
+}
+
+func getRedisCtx(ctx context.Context) *redisContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*redisContext)
+}
+
+func newClients() *clients {
+	return &clients{clients: map[string]redis.UniversalClient{}}
+}
+
+// connect returns the client of the dial URL of the step, it is created on first use with the pool settings of the step.
+// Without Setup, a new client is returned, it must be closed by the caller.
+func (e Executor) connect(ctx context.Context) (redis.UniversalClient, bool, error) {
+	redisCtx := getRedisCtx(ctx)
+	if redisCtx == nil {
+		client, err := e.newClient()
+		return client, false, err
+	}
+
+	c := redisCtx.clients
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if client, ok := c.clients[e.DialURL]; ok {
+		return client, true, nil
+	}
+	client, err := e.newClient()
+	if err != nil {
+		return nil, false, err
+	}
+	c.clients[e.DialURL] = client
+	return client, true, nil
+}
+
+// newClient returns a client of a Redis server, a Redis Cluster with a redis+cluster:// URL,
+// or the master of a Redis Sentinel with a redis+sentinel:// URL
+func (e Executor) newClient() (redis.UniversalClient, error) {
+	u, err := url.Parse(e.DialURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dialURL: %w", err)
+	}
+
+	switch {
+	case strings.HasSuffix(u.Scheme, clusterScheme):
+		u.Scheme = strings.TrimSuffix(u.Scheme, clusterScheme)
+		opts, err := redis.ParseClusterURL(u.String())
+		if err != nil {
+			return nil, err
+		}
+		e.configure(&opts.PoolSize, &opts.ConnMaxIdleTime)
+		return redis.NewClusterClient(opts), nil
+	case strings.HasSuffix(u.Scheme, sentinelScheme):
+		u.Scheme = strings.TrimSuffix(u.Scheme, sentinelScheme)
+		opts, err := redis.ParseFailoverURL(u.String())
+		if err != nil {
+			return nil, err
+		}
+		e.configure(&opts.PoolSize, &opts.ConnMaxIdleTime)
+		return redis.NewFailoverClient(opts), nil
+	}
+
+	opts, err := redis.ParseURL(e.DialURL)
+	if err != nil {
+		return nil, err
+	}
+	e.configure(&opts.PoolSize, &opts.ConnMaxIdleTime)
+	return redis.NewClient(opts), nil
+}
+
+// configure applies the pool settings of the step to the options of a client
+func (e Executor) configure(poolSize *int, idleTimeout *time.Duration) {
+	if e.PoolSize > 0 {
+		*poolSize = e.PoolSize
+	}
+	if e.IdleTimeout > 0 {
+		*idleTimeout = time.Duration(e.IdleTimeout) * time.Second
+	}
+}
+
+// Close closes all the clients
+func (c *clients) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var errs []string
+	for dialURL, client := range c.clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		delete(c.clients, dialURL)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to close redis connections: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -3,15 +3,19 @@ package redis
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path"
+	"strconv"
+	"time"
 
-	"github.com/gomodule/redigo/redis"
 	shellwords "github.com/mattn/go-shellwords"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/ovh/venom"
 )
@@ -19,6 +23,14 @@ import (
 // Name of executor
 const Name = "redis"
 
+// defaultSubscribeTimeout is the duration of the collection of the published messages. In Milliseconds
+const defaultSubscribeTimeout = 5000
+
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
 // New returns a new Executor
 func New() venom.Executor {
 	return &Executor{}
@@ -26,9 +38,24 @@ func New() venom.Executor {
 
 // Executor represents the redis executor
 type Executor struct {
-	DialURL  string   `json:"dialURL,omitempty" yaml:"dialURL,omitempty" mapstructure:"dialURL"`
-	Commands []string `json:"commands,omitempty" yaml:"commands,omitempty"`
-	FilePath string   `json:"path,omitempty" yaml:"path,omitempty" mapstructure:"path"`
+	DialURL string `json:"dialURL,omitempty" yaml:"dialURL,omitempty" mapstructure:"dialURL"`
+	// Commands is a list of redis commands, each one is either a string or a Command with its name and its args
+	Commands []interface{} `json:"commands,omitempty" yaml:"commands,omitempty"`
+	FilePath string        `json:"path,omitempty" yaml:"path,omitempty" mapstructure:"path"`
+	// PoolSize is the maximum number of connections opened to the server
+	PoolSize int `json:"pool_size,omitempty" yaml:"pool_size,omitempty" mapstructure:"pool_size"`
+	// IdleTimeout closes the connections unused for this duration. In Seconds
+	IdleTimeout int `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty" mapstructure:"idle_timeout"`
+	// Subscribe collects the messages published on these channels
+	Subscribe []string `json:"subscribe,omitempty" yaml:"subscribe,omitempty"`
+	// PSubscribe collects the messages published on the channels matching these patterns
+	PSubscribe []string `json:"psubscribe,omitempty" yaml:"psubscribe,omitempty" mapstructure:"psubscribe"`
+	// MaxMessages stops the collection of the messages when this number of messages is received
+	MaxMessages int `json:"max_messages,omitempty" yaml:"max_messages,omitempty" mapstructure:"max_messages"`
+	// Timeout stops the collection of the messages. In Milliseconds. Default 5000
+	Timeout int `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// WaitUntil runs the commands again until the result matches its assertions
+	WaitUntil *venom.WaitUntil `json:"wait_until,omitempty" yaml:"wait_until,omitempty" mapstructure:"wait_until"`
 }
 
 // Command represents a redis command and the result
@@ -38,9 +65,20 @@ type Command struct {
 	Response interface{}   `json:"response,omitempty" yaml:"response,omitempty"`
 }
 
+// Message represents a message received on a subscribed channel
+type Message struct {
+	Channel string `json:"channel" yaml:"channel"`
+	// Pattern is the pattern matching the channel, with psubscribe
+	Pattern     string      `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Payload     string      `json:"payload" yaml:"payload"`
+	PayloadJSON interface{} `json:"payloadjson,omitempty" yaml:"payloadjson,omitempty"`
+}
+
 // Result represents a step result.
 type Result struct {
-	Commands []Command `json:"commands,omitempty" yaml:"commands,omitempty"`
+	Commands  []Command              `json:"commands,omitempty" yaml:"commands,omitempty"`
+	Messages  []Message              `json:"messages,omitempty" yaml:"messages,omitempty"`
+	WaitUntil *venom.WaitUntilResult `json:"wait_until,omitempty" yaml:"wait_until,omitempty"`
 }
 
 // ZeroValueResult return an empty implementation of this executor result
@@ -62,50 +100,217 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 		return nil, fmt.Errorf("missing dialURL")
 	}
 
-	redisClient, err := redis.DialURL(e.DialURL)
+	workdir := venom.StringVarFromCtx(ctx, "venom.testsuite.workdir")
+	commands, err := e.commands(workdir)
 	if err != nil {
 		return nil, err
 	}
 
-	workdir := venom.StringVarFromCtx(ctx, "venom.testsuite.workdir")
+	redisClient, pooled, err := e.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !pooled {
+		defer redisClient.Close()
+	}
 
-	var commands []string
+	if len(e.Subscribe) != 0 || len(e.PSubscribe) != 0 {
+		if e.WaitUntil != nil {
+			return nil, fmt.Errorf("wait_until can't be used with subscribe or psubscribe")
+		}
+		return e.subscribe(ctx, redisClient, commands)
+	}
+
+	if e.WaitUntil == nil {
+		return runCommands(ctx, redisClient, commands)
+	}
+	r, polling, err := e.WaitUntil.Poll(ctx, func() (interface{}, error) {
+		return runCommands(ctx, redisClient, commands)
+	})
+	if err != nil && !errors.Is(err, venom.ErrWaitUntilTimeout) {
+		return nil, err
+	}
+	result := r.(Result)
+	result.WaitUntil = &polling
+	if err != nil {
+		// the last result is reported with the timeout
+		return nil, &venom.ErrorWithResult{Result: result, Err: err}
+	}
+	return result, nil
+}
+
+// commands returns the commands of the file, or the commands of the step
+func (e Executor) commands(workdir string) ([]Command, error) {
 	if e.FilePath != "" {
-		commands, err = file2lines(path.Join(workdir, e.FilePath))
+		lines, err := file2lines(path.Join(workdir, e.FilePath))
 		if err != nil {
 			return nil, errors.Wrapf(err, "Failed to load file")
 		}
-	} else {
-		commands = e.Commands
+		var commands []Command
+		for _, line := range lines {
+			if line == "" {
+				continue
+			}
+			name, args, err := getCommandDetails(line)
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, Command{Name: name, Args: args})
+		}
+		return commands, nil
 	}
-	result := Result{Commands: []Command{}}
 
-	for i := range commands {
-		if commands[i] == "" {
-			continue
+	var commands []Command
+	for i, c := range e.Commands {
+		switch v := c.(type) {
+		case string:
+			if v == "" {
+				continue
+			}
+			name, args, err := getCommandDetails(v)
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, Command{Name: name, Args: args})
+		default:
+			var command Command
+			if err := mapstructure.Decode(v, &command); err != nil {
+				return nil, fmt.Errorf("invalid command #%d: %w", i, err)
+			}
+			if command.Name == "" {
+				return nil, fmt.Errorf("invalid command #%d: missing name", i)
+			}
+			for j, arg := range command.Args {
+				s, err := commandArg(arg)
+				if err != nil {
+					return nil, fmt.Errorf("invalid command #%d: %w", i, err)
+				}
+				command.Args[j] = s
+			}
+			commands = append(commands, command)
 		}
-		name, args, err := getCommandDetails(commands[i])
+	}
+	return commands, nil
+}
+
+// commandArg converts an argument to a string, the lists and the objects are encoded in JSON
+func commandArg(arg interface{}) (string, error) {
+	switch v := arg.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case map[string]interface{}, []interface{}:
+		btes, err := json.Marshal(v)
 		if err != nil {
-			return nil, err
+			return "", fmt.Errorf("unable to encode argument %v: %w", v, err)
 		}
+		return string(btes), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
 
-		res, err := redisClient.Do(name, args...)
-		if err != nil {
-			arg := fmt.Sprint(args)
-			return nil, fmt.Errorf("redis executor failed to execute command %s %s : %s", name, arg, res)
+// runCommands runs the commands and returns their responses
+func runCommands(ctx context.Context, redisClient redis.UniversalClient, commands []Command) (Result, error) {
+	// the commands of a step share a connection, for MULTI or SELECT. A cluster chooses the node of each command
+	var conn interface {
+		Do(ctx context.Context, args ...interface{}) *redis.Cmd
+	} = redisClient
+	if client, ok := redisClient.(*redis.Client); ok {
+		c := client.Conn()
+		defer c.Close()
+		conn = c
+	}
+
+	result := Result{Commands: []Command{}}
+	for _, command := range commands {
+		res, err := conn.Do(ctx, append([]interface{}{command.Name}, command.Args...)...).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			arg := fmt.Sprint(command.Args)
+			return Result{}, fmt.Errorf("redis executor failed to execute command %s %s : %s", command.Name, arg, err)
 		}
 
-		r := handleRedisResponse(res, err)
 		result.Commands = append(result.Commands, Command{
-			Name:     name,
-			Args:     args,
-			Response: r,
+			Name:     command.Name,
+			Args:     command.Args,
+			Response: decodeResponse(command.Name, command.Args, res),
 		})
+	}
+	return result, nil
+}
+
+// subscribe runs the commands once subscribed to the channels and to the patterns,
+// and collects the published messages until the maximum number of messages or the timeout
+func (e Executor) subscribe(ctx context.Context, redisClient redis.UniversalClient, commands []Command) (Result, error) {
+	var pubsub *redis.PubSub
+	if len(e.Subscribe) != 0 {
+		pubsub = redisClient.Subscribe(ctx, e.Subscribe...)
+		if len(e.PSubscribe) != 0 {
+			if err := pubsub.PSubscribe(ctx, e.PSubscribe...); err != nil {
+				pubsub.Close()
+				return Result{}, err
+			}
+		}
+	} else {
+		pubsub = redisClient.PSubscribe(ctx, e.PSubscribe...)
+	}
+	defer pubsub.Close()
+
+	timeout := time.Duration(e.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultSubscribeTimeout * time.Millisecond
+	}
+	receiveCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// the subscriptions are confirmed before running the commands, so that their messages are received
+	var messages []Message
+	for subscriptions := 0; subscriptions < len(e.Subscribe)+len(e.PSubscribe); {
+		msg, err := pubsub.Receive(receiveCtx)
+		if err != nil {
+			return Result{}, fmt.Errorf("unable to subscribe: %w", err)
+		}
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			subscriptions++
+		case *redis.Message:
+			messages = append(messages, newMessage(m))
+		}
+	}
 
+	result, err := runCommands(ctx, redisClient, commands)
+	if err != nil {
+		return Result{}, err
+	}
+
+	for e.MaxMessages <= 0 || len(messages) < e.MaxMessages {
+		m, err := pubsub.ReceiveMessage(receiveCtx)
+		if err != nil {
+			// the collection stops with an error when the timeout expires, the read deadline
+			// of the connection may expire just before the context
+			var netErr net.Error
+			if receiveCtx.Err() != nil || (errors.As(err, &netErr) && netErr.Timeout()) {
+				break
+			}
+			return Result{}, err
+		}
+		messages = append(messages, newMessage(m))
 	}
+	venom.Debug(ctx, "received %d message(s)", len(messages))
+	result.Messages = messages
 	return result, nil
 }
 
+func newMessage(m *redis.Message) Message {
+	msg := Message{Channel: m.Channel, Pattern: m.Pattern, Payload: m.Payload}
+	var payloadJSON interface{}
+	if err := json.Unmarshal([]byte(m.Payload), &payloadJSON); err == nil {
+		msg.PayloadJSON = payloadJSON
+	}
+	return msg
+}
+
 func getCommandDetails(command string) (name string, arg []interface{}, err error) {
 	cmd, err := shellwords.Parse(command)
 	if err != nil {
@@ -128,22 +333,6 @@ func sliceStringToSliceInterface(args []string) []interface{} {
 	return s
 }
 
-func handleRedisResponse(res interface{}, err error) interface{} {
-	switch p := res.(type) {
-	case []interface{}:
-		var result []interface{}
-		for i := range p {
-			u := p[i]
-			k := handleRedisResponse(u, err)
-			result = append(result, k)
-		}
-		return result
-	default:
-		t, _ := redis.String(res, err) // nolint
-		return t
-	}
-}
-
 func file2lines(filePath string) ([]string, error) {
 	var lines []string
 	f, err := os.Open(filePath)
@@ -0,0 +1,167 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+func run(t *testing.T, step venom.TestStep) Result {
+	venom.InitTestLogger(t)
+	if _, ok := step["dialURL"]; !ok {
+		step["dialURL"] = "redis://" + miniredis.RunT(t).Addr()
+	}
+	res, err := New().Run(context.Background(), step)
+	require.NoError(t, err)
+	return res.(Result)
+}
+
+func TestExecutor_Run_Commands(t *testing.T) {
+	result := run(t, venom.TestStep{
+		"commands": []interface{}{
+			map[string]interface{}{"name": "SET", "args": []interface{}{"doc", map[string]interface{}{"title": "hello world"}}},
+			"GET doc",
+			map[string]interface{}{"name": "HSET", "args": []interface{}{"user:1", "name", "John Doe", "age", 42.0}},
+			"HGETALL user:1",
+			"SADD tags b c a",
+			"SMEMBERS tags",
+			"ZADD scores 1.5 foo 2 bar",
+			"ZRANGE scores 0 -1 WITHSCORES",
+			"ZRANGE scores 0 -1",
+			"INCR counter",
+			"GET unknown",
+		},
+	})
+	require.Len(t, result.Commands, 11)
+	assert.Equal(t, "OK", result.Commands[0].Response)
+	assert.Equal(t, `{"title":"hello world"}`, result.Commands[1].Response)
+	assert.Equal(t, map[string]interface{}{"name": "John Doe", "age": "42"}, result.Commands[3].Response)
+	assert.Equal(t, []interface{}{"a", "b", "c"}, result.Commands[5].Response)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"member": "foo", "score": 1.5},
+		map[string]interface{}{"member": "bar", "score": 2.0},
+	}, result.Commands[7].Response)
+	assert.Equal(t, []interface{}{"foo", "bar"}, result.Commands[8].Response)
+	assert.Equal(t, int64(1), result.Commands[9].Response)
+	assert.Nil(t, result.Commands[10].Response)
+
+	dump, err := venom.Dump(result)
+	require.NoError(t, err)
+	assert.Equal(t, "John Doe", dump["result.commands.commands3.response.name"])
+	assert.Equal(t, "bar", dump["result.commands.commands7.response.response1.member"])
+}
+
+func TestExecutor_Run_Errors(t *testing.T) {
+	venom.InitTestLogger(t)
+	dialURL := "redis://" + miniredis.RunT(t).Addr()
+
+	_, err := New().Run(context.Background(), venom.TestStep{
+		"dialURL":  dialURL,
+		"commands": []interface{}{map[string]interface{}{"args": []interface{}{"foo"}}},
+	})
+	assert.Error(t, err)
+
+	_, err = New().Run(context.Background(), venom.TestStep{
+		"dialURL":  dialURL,
+		"commands": []interface{}{"UNKNOWN foo"},
+	})
+	assert.Error(t, err)
+}
+
+func TestExecutor_Run_Subscribe(t *testing.T) {
+	result := run(t, venom.TestStep{
+		"subscribe":    []interface{}{"orders"},
+		"psubscribe":   []interface{}{"events.*"},
+		"max_messages": 2,
+		"timeout":      5000,
+		"commands": []interface{}{
+			map[string]interface{}{"name": "PUBLISH", "args": []interface{}{"orders", map[string]interface{}{"id": 42.0}}},
+			"PUBLISH events.created foo",
+		},
+	})
+	require.Len(t, result.Messages, 2)
+	assert.Equal(t, Message{Channel: "orders", Payload: `{"id":42}`, PayloadJSON: map[string]interface{}{"id": 42.0}}, result.Messages[0])
+	assert.Equal(t, Message{Channel: "events.created", Pattern: "events.*", Payload: "foo"}, result.Messages[1])
+	assert.Equal(t, int64(1), result.Commands[0].Response)
+
+	// the collection stops at the timeout
+	result = run(t, venom.TestStep{
+		"psubscribe": []interface{}{"nothing.*"},
+		"timeout":    50,
+	})
+	assert.Empty(t, result.Messages)
+}
+
+func TestExecutor_Run_Pool(t *testing.T) {
+	venom.InitTestLogger(t)
+	dialURL := "redis://" + miniredis.RunT(t).Addr()
+
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{"redis.dialURL": dialURL})
+	require.NoError(t, err)
+
+	// the dialURL can be a variable
+	ctx = context.WithValue(ctx, venom.ContextKey("var.redis.dialURL"), dialURL)
+	for i := 0; i < 2; i++ {
+		res, err := e.Run(ctx, venom.TestStep{"pool_size": 2, "commands": []interface{}{"INCR n"}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(i+1), res.(Result).Commands[0].Response)
+	}
+
+	redisCtx := getRedisCtx(ctx)
+	require.Len(t, redisCtx.clients.clients, 1)
+	client := redisCtx.clients.clients[dialURL].(*redis.Client)
+	assert.Equal(t, 2, client.Options().PoolSize)
+	assert.Equal(t, 3, client.Options().Protocol)
+
+	require.NoError(t, e.TearDown(ctx))
+	assert.Empty(t, redisCtx.clients.clients)
+}
+
+func TestExecutor_newClient(t *testing.T) {
+	client, err := Executor{DialURL: "redis+cluster://localhost:7000?addr=localhost:7001", PoolSize: 3}.newClient()
+	require.NoError(t, err)
+	defer client.Close()
+	cluster := client.(*redis.ClusterClient)
+	assert.Equal(t, []string{"localhost:7000", "localhost:7001"}, cluster.Options().Addrs)
+	assert.Equal(t, 3, cluster.Options().PoolSize)
+
+	client, err = Executor{DialURL: "redis+sentinel://localhost:26379?master_name=mymaster&protocol=3"}.newClient()
+	require.NoError(t, err)
+	defer client.Close()
+	assert.IsType(t, &redis.Client{}, client)
+
+	// RESP2 is used only if the URL asks for it
+	client, err = Executor{DialURL: "redis://localhost:6379?protocol=2"}.newClient()
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, 2, client.(*redis.Client).Options().Protocol)
+
+	_, err = Executor{DialURL: "http://localhost"}.newClient()
+	assert.Error(t, err)
+}
+
+func TestDecodeResponse(t *testing.T) {
+	// RESP3 types
+	assert.Equal(t, map[string]interface{}{"name": "foo"}, decodeResponse("HGETALL", nil, map[interface{}]interface{}{"name": "foo"}))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"member": "foo", "score": 1.5},
+	}, decodeResponse("ZPOPMIN", nil, []interface{}{[]interface{}{"foo", 1.5}}))
+	assert.Equal(t, []interface{}{
+		[]interface{}{"b", []interface{}{"1-0"}},
+		[]interface{}{"a", []interface{}{"2-0"}},
+	}, decodeResponse("XREAD", []interface{}{"COUNT", "2", "STREAMS", "b", "a", "0-0", "0-0"}, map[interface{}]interface{}{
+		"a": []interface{}{"2-0"},
+		"b": []interface{}{"1-0"},
+	}))
+	// RESP2 flat list
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"member": "foo", "score": 1.5},
+	}, decodeResponse("zpopmax", nil, []interface{}{"foo", "1.5"}))
+}
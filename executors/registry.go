@@ -4,12 +4,15 @@ import (
 	"github.com/ovh/venom"
 	"github.com/ovh/venom/executors/amqp"
 	"github.com/ovh/venom/executors/couchbase"
+	"github.com/ovh/venom/executors/dbdiff"
 	"github.com/ovh/venom/executors/dbfixtures"
 	"github.com/ovh/venom/executors/exec"
 	"github.com/ovh/venom/executors/grpc"
 	"github.com/ovh/venom/executors/http"
 	"github.com/ovh/venom/executors/imap"
 	"github.com/ovh/venom/executors/kafka"
+	"github.com/ovh/venom/executors/mailserver"
+	"github.com/ovh/venom/executors/mockserver"
 	"github.com/ovh/venom/executors/mongo"
 	"github.com/ovh/venom/executors/mqtt"
 	"github.com/ovh/venom/executors/ovhapi"
@@ -20,6 +23,7 @@ import (
 	"github.com/ovh/venom/executors/sql"
 	"github.com/ovh/venom/executors/ssh"
 	"github.com/ovh/venom/executors/web"
+	"github.com/ovh/venom/executors/websocket"
 )
 
 type Constructor func() venom.Executor
@@ -27,12 +31,15 @@ type Constructor func() venom.Executor
 // Registry is a map of executors to executor constructor functions.
 var Registry map[string]Constructor = map[string]Constructor{
 	amqp.Name:       amqp.New,
+	dbdiff.Name:     dbdiff.New,
 	dbfixtures.Name: dbfixtures.New,
 	exec.Name:       exec.New,
 	grpc.Name:       grpc.New,
 	http.Name:       http.New,
 	imap.Name:       imap.New,
 	kafka.Name:      kafka.New,
+	mailserver.Name: mailserver.New,
+	mockserver.Name: mockserver.New,
 	mqtt.Name:       mqtt.New,
 	ovhapi.Name:     ovhapi.New,
 	rabbitmq.Name:   rabbitmq.New,
@@ -43,5 +50,6 @@ var Registry map[string]Constructor = map[string]Constructor{
 	ssh.Name:        ssh.New,
 	mongo.Name:      mongo.New,
 	web.Name:        web.New,
+	websocket.Name:  websocket.New,
 	couchbase.Name:  couchbase.New,
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"mime"
 	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"strings"
 	"time"
 
@@ -24,20 +26,27 @@ func New() venom.Executor {
 
 // Executor represents a Test Exec
 type Executor struct {
-	WithTLS  bool   `json:"withtls,omitempty" yaml:"withtls,omitempty"`
-	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
-	Port     string `json:"port,omitempty" yaml:"port,omitempty"`
-	User     string `json:"user,omitempty" yaml:"user,omitempty"`
-	Password string `json:"password,omitempty" yaml:"password,omitempty"`
-	To       string `json:"to,omitempty" yaml:"to,omitempty"`
-	From     string `json:"from,omitempty" yaml:"from,omitempty"`
-	Subject  string `json:"subject,omitempty" yaml:"subject,omitempty"`
-	Body     string `json:"body,omitempty" yaml:"body,omitempty"`
+	WithTLS     bool              `json:"withtls,omitempty" yaml:"withtls,omitempty"`
+	StartTLS    bool              `json:"starttls,omitempty" yaml:"starttls,omitempty"`
+	Host        string            `json:"host,omitempty" yaml:"host,omitempty"`
+	Port        string            `json:"port,omitempty" yaml:"port,omitempty"`
+	User        string            `json:"user,omitempty" yaml:"user,omitempty"`
+	Password    string            `json:"password,omitempty" yaml:"password,omitempty"`
+	To          string            `json:"to,omitempty" yaml:"to,omitempty"`
+	CC          string            `json:"cc,omitempty" yaml:"cc,omitempty"`
+	BCC         string            `json:"bcc,omitempty" yaml:"bcc,omitempty"`
+	From        string            `json:"from,omitempty" yaml:"from,omitempty"`
+	Subject     string            `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Body        string            `json:"body,omitempty" yaml:"body,omitempty"`
+	HTMLBody    string            `json:"htmlbody,omitempty" yaml:"htmlbody,omitempty"`
+	Attachments []string          `json:"attachments,omitempty" yaml:"attachments,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
 }
 
 // Result represents a step result
 type Result struct {
 	Err         string  `json:"err,omitempty" yaml:"error"`
+	MessageID   string  `json:"messageid,omitempty" yaml:"messageid,omitempty"`
 	TimeSeconds float64 `json:"timeseconds,omitempty" yaml:"timeSeconds,omitempty"`
 }
 
@@ -61,7 +70,8 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 	start := time.Now()
 
 	result := Result{}
-	err := e.sendEmail(ctx)
+	var err error
+	result.MessageID, err = e.sendEmail(ctx)
 	if err != nil {
 		result.Err = err.Error()
 		return result, err
@@ -73,36 +83,73 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 	return result, nil
 }
 
-func (e *Executor) sendEmail(ctx context.Context) error {
+// recipients returns the addresses of the To, CC and BCC lists
+func (e *Executor) recipients() ([]string, error) {
+	var recipients []string
+	for _, l := range []struct{ name, list string }{{"To", e.To}, {"CC", e.CC}, {"BCC", e.BCC}} {
+		if strings.TrimSpace(l.list) == "" {
+			continue
+		}
+		addresses, err := mail.ParseAddressList(l.list)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", l.name, err)
+		}
+		for _, a := range addresses {
+			recipients = append(recipients, a.Address)
+		}
+	}
+	return recipients, nil
+}
+
+// sendEmail sends the mail and returns its Message-ID
+func (e *Executor) sendEmail(ctx context.Context) (string, error) {
 	if e.To == "" {
-		return fmt.Errorf("invalid To")
+		return "", fmt.Errorf("invalid To")
 	}
 	if e.From == "" {
-		return fmt.Errorf("invalid From")
+		return "", fmt.Errorf("invalid From")
+	}
+	if e.WithTLS && e.StartTLS {
+		return "", fmt.Errorf("withtls and starttls can't be used together")
 	}
 
 	mailFrom := mail.Address{
 		Name:    "",
 		Address: e.From,
 	}
-	mailTo := mail.Address{
-		Name:    "",
-		Address: e.To,
+	recipients, err := e.recipients()
+	if err != nil {
+		return "", err
 	}
 
-	// Setup headers
-	headers := make(map[string]string)
-	headers["From"] = e.From
-	headers["To"] = e.To
-	headers["Subject"] = e.Subject
-	headers["Content-Type"] = "text/plain; charset=utf-8"
+	// Setup headers, the custom ones override the default ones
+	header := textproto.MIMEHeader{}
+	header.Set("From", e.From)
+	header.Set("To", e.To)
+	if e.CC != "" {
+		header.Set("Cc", e.CC)
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", e.Subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	messageID, err := newMessageID(e.From)
+	if err != nil {
+		return "", err
+	}
+	header.Set("Message-ID", messageID)
+	for k, v := range e.Headers {
+		header.Set(k, v)
+	}
+	messageID = header.Get("Message-ID")
 
 	// Setup message
-	message := ""
-	for k, v := range headers {
-		message += fmt.Sprintf("%s: %s\r\n", k, v)
+	attachments, err := readAttachments(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), e.Attachments)
+	if err != nil {
+		return "", err
+	}
+	message, err := e.buildMessage(header, attachments)
+	if err != nil {
+		return "", err
 	}
-	message += "\r\n" + e.Body
 
 	tlsconfig := &tls.Config{
 		InsecureSkipVerify: true,
@@ -116,53 +163,59 @@ func (e *Executor) sendEmail(ctx context.Context) error {
 	if e.WithTLS {
 		conn, err := tls.Dial("tcp", servername, tlsconfig)
 		if err != nil {
-			return fmt.Errorf("tls dial error: %w", err)
+			return "", fmt.Errorf("tls dial error: %w", err)
 		}
 
 		c, err = smtp.NewClient(conn, e.Host)
 		if err != nil {
-			return err
+			return "", err
 		}
 	} else {
 		var err error
 		c, err = smtp.Dial(servername)
 		if err != nil {
-			return fmt.Errorf("smtp dial error: %w", err)
+			return "", fmt.Errorf("smtp dial error: %w", err)
+		}
+	}
+	defer c.Close()
+
+	if e.StartTLS {
+		if err := c.StartTLS(tlsconfig); err != nil {
+			return "", fmt.Errorf("starttls error: %w", err)
 		}
-		defer c.Close()
 	}
 
 	if e.User != "" && e.Password != "" {
 		auth := smtp.PlainAuth("", e.User, e.Password, e.Host)
 		if err := c.Auth(auth); err != nil {
-			return fmt.Errorf("failed to authenticate: %w", err)
+			return "", fmt.Errorf("failed to authenticate: %w", err)
 		}
 	}
 
 	if err := c.Mail(mailFrom.Address); err != nil {
-		return err
+		return "", err
 	}
 
-	for _, toaddr := range strings.Split(mailTo.Address, ",") {
+	for _, toaddr := range recipients {
 		if err := c.Rcpt(toaddr); err != nil {
-			return fmt.Errorf("%s: %v", toaddr, err)
+			return "", fmt.Errorf("%s: %v", toaddr, err)
 		}
 	}
 
 	w, err := c.Data()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if _, err := w.Write([]byte(message)); err != nil {
-		return err
+	if _, err := w.Write(message); err != nil {
+		return "", err
 	}
 
 	if err := w.Close(); err != nil {
-		return err
+		return "", err
 	}
 
-	venom.Info(ctx, "mail sent to %s", mailTo.Address)
+	venom.Info(ctx, "mail %s sent to %s", messageID, strings.Join(recipients, ","))
 
-	return c.Quit()
+	return messageID, c.Quit()
 }
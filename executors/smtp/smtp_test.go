@@ -0,0 +1,286 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+	"github.com/ovh/venom/executors/mailserver"
+)
+
+// sendToMailServer sends the step through an in-process mail server and returns the result and the received message
+func sendToMailServer(t *testing.T, ctx context.Context, step venom.TestStep) (Result, map[string]interface{}) {
+	m := mailserver.New().(*mailserver.Executor)
+	ctx, err := m.Setup(context.WithValue(ctx, venom.ContextKey("var.venom.outputdir"), t.TempDir()), venom.H{})
+	require.NoError(t, err)
+	defer m.TearDown(ctx)
+
+	res, err := m.Run(ctx, venom.TestStep{"action": "start"})
+	require.NoError(t, err)
+	started := res.(mailserver.Result)
+
+	step["host"] = started.Host
+	step["port"] = strconv.Itoa(started.SMTPPort)
+	sent, err := New().Run(ctx, step)
+	require.NoError(t, err)
+
+	res, err = m.Run(ctx, venom.TestStep{"action": "messages"})
+	require.NoError(t, err)
+	messages := res.(mailserver.Result).Messages
+	require.Len(t, messages, 1)
+	return sent.(Result), messages[0].(map[string]interface{})
+}
+
+// part is a part of a multipart body, its quoted-printable content is decoded by multipart.Reader
+type part struct {
+	header  textproto.MIMEHeader
+	content string
+}
+
+// readParts returns the parts of a multipart body
+func readParts(t *testing.T, contentType string, body io.Reader) []part {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(mediaType, "multipart/"), mediaType)
+	var parts []part
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		require.NoError(t, err)
+		parts = append(parts, part{header: p.Header, content: readAll(t, p)})
+	}
+}
+
+func TestExecutor_Run_Message(t *testing.T) {
+	venom.InitTestLogger(t)
+	workdir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workdir, "invoice.txt"), []byte("Invoice #1"), 0o644))
+	ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.testsuite.workdir"), workdir)
+
+	result, received := sendToMailServer(t, ctx, venom.TestStep{
+		"from":        "sender@venom.local",
+		"to":          "Someone <someone@example.org>",
+		"cc":          "copy@example.org",
+		"bcc":         "hidden@example.org",
+		"subject":     "Order été confirmed",
+		"body":        "Your order is confirmed.",
+		"htmlbody":    "<p>Your order is confirmed.</p>",
+		"attachments": []interface{}{"invoice.txt"},
+		"headers": map[string]interface{}{
+			"X-Priority": "1",
+			"Message-ID": "<order-42@venom.local>",
+		},
+	})
+	assert.Equal(t, "<order-42@venom.local>", result.MessageID)
+	assert.Equal(t, "sender@venom.local", received["sender"])
+	assert.Equal(t, []interface{}{"someone@example.org", "copy@example.org", "hidden@example.org"}, received["recipients"])
+
+	msg, err := mail.ReadMessage(strings.NewReader(received["raw"].(string)))
+	require.NoError(t, err)
+	assert.Equal(t, "Someone <someone@example.org>", msg.Header.Get("To"))
+	assert.Equal(t, "copy@example.org", msg.Header.Get("Cc"))
+	assert.NotContains(t, msg.Header, "Bcc")
+	assert.NotContains(t, received["raw"], "hidden@example.org")
+	assert.Equal(t, "1", msg.Header.Get("X-Priority"))
+	assert.Equal(t, "<order-42@venom.local>", msg.Header.Get("Message-Id"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Order été confirmed", subject)
+
+	mixed := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	require.Len(t, mixed, 2)
+
+	alternative := readParts(t, mixed[0].header.Get("Content-Type"), strings.NewReader(mixed[0].content))
+	require.Len(t, alternative, 2)
+	assert.Equal(t, "text/plain; charset=utf-8", alternative[0].header.Get("Content-Type"))
+	assert.Equal(t, "Your order is confirmed.", alternative[0].content)
+	assert.Equal(t, "text/html; charset=utf-8", alternative[1].header.Get("Content-Type"))
+	assert.Equal(t, "<p>Your order is confirmed.</p>", alternative[1].content)
+
+	assert.Equal(t, "attachment; filename=invoice.txt", mixed[1].header.Get("Content-Disposition"))
+	assert.Equal(t, "text/plain; charset=utf-8; name=invoice.txt", mixed[1].header.Get("Content-Type"))
+	assert.Equal(t, "base64", mixed[1].header.Get("Content-Transfer-Encoding"))
+	assert.Equal(t, "SW52b2ljZSAjMQ==\r\n", mixed[1].content)
+}
+
+func TestExecutor_Run_TextMessage(t *testing.T) {
+	venom.InitTestLogger(t)
+	_, received := sendToMailServer(t, context.Background(), venom.TestStep{
+		"from":    "sender@venom.local",
+		"to":      "someone@example.org",
+		"subject": "Hello",
+		"body":    "Hello été",
+	})
+
+	msg, err := mail.ReadMessage(strings.NewReader(received["raw"].(string)))
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", msg.Header.Get("Content-Type"))
+	assert.Equal(t, "quoted-printable", msg.Header.Get("Content-Transfer-Encoding"))
+	assert.NotContains(t, msg.Header, "Cc")
+	assert.Equal(t, "Hello =C3=A9t=C3=A9\r\n", readAll(t, msg.Body))
+	assert.Regexp(t, `^<\d+\.[0-9a-f]{16}@venom\.local>$`, msg.Header.Get("Message-Id"))
+}
+
+func TestExecutor_Run_MissingAttachment(t *testing.T) {
+	venom.InitTestLogger(t)
+	ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.testsuite.workdir"), t.TempDir())
+	_, err := New().Run(ctx, venom.TestStep{
+		"host":        "127.0.0.1",
+		"port":        "1",
+		"from":        "sender@venom.local",
+		"to":          "someone@example.org",
+		"attachments": []interface{}{"missing.txt"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to read attachment missing.txt")
+}
+
+func readAll(t *testing.T, r io.Reader) string {
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// tlsServer is a SMTP server accepting a mail over an implicit TLS connection, or after STARTTLS
+type tlsServer struct {
+	listener net.Listener
+	config   *tls.Config
+	// encrypted is sent whether the mail was received on an encrypted connection
+	encrypted chan bool
+}
+
+func newTLSServer(t *testing.T, implicit bool) *tlsServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	config := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if implicit {
+		l = tls.NewListener(l, config)
+	}
+	s := &tlsServer{listener: l, config: config, encrypted: make(chan bool, 1)}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *tlsServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, encrypted := conn.(*tls.Conn)
+	r, w := bufio.NewReader(conn), conn
+	io.WriteString(w, "220 ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.Fields(line + " x")[0]); verb {
+		case "EHLO":
+			if encrypted {
+				io.WriteString(w, "250 localhost\r\n")
+			} else {
+				io.WriteString(w, "250-localhost\r\n250 STARTTLS\r\n")
+			}
+		case "STARTTLS":
+			io.WriteString(w, "220 go ahead\r\n")
+			tlsConn := tls.Server(conn, s.config)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, encrypted = tlsConn, true
+			r, w = bufio.NewReader(tlsConn), tlsConn
+		case "DATA":
+			io.WriteString(w, "354 end with .\r\n")
+			for line != ".\r\n" {
+				if line, err = r.ReadString('\n'); err != nil {
+					return
+				}
+			}
+			s.encrypted <- encrypted
+			io.WriteString(w, "250 OK\r\n")
+		case "QUIT":
+			io.WriteString(w, "221 Bye\r\n")
+			return
+		default:
+			io.WriteString(w, "250 OK\r\n")
+		}
+	}
+}
+
+func TestExecutor_Run_TLS(t *testing.T) {
+	venom.InitTestLogger(t)
+	for _, option := range []string{"withtls", "starttls"} {
+		t.Run(option, func(t *testing.T) {
+			s := newTLSServer(t, option == "withtls")
+			_, port, err := net.SplitHostPort(s.listener.Addr().String())
+			require.NoError(t, err)
+
+			_, err = New().Run(context.Background(), venom.TestStep{
+				"host": "127.0.0.1",
+				"port": port,
+				option: true,
+				"from": "sender@venom.local",
+				"to":   "someone@example.org",
+				"body": "Hello",
+			})
+			require.NoError(t, err)
+			select {
+			case encrypted := <-s.encrypted:
+				assert.True(t, encrypted)
+			case <-time.After(5 * time.Second):
+				t.Fatal("the mail was not received")
+			}
+		})
+	}
+}
+
+func TestExecutor_Run_TLSOptions(t *testing.T) {
+	_, err := New().Run(context.Background(), venom.TestStep{
+		"host":     "127.0.0.1",
+		"port":     "1",
+		"withtls":  true,
+		"starttls": true,
+		"from":     "sender@venom.local",
+		"to":       "someone@example.org",
+	})
+	require.EqualError(t, err, "withtls and starttls can't be used together")
+}
@@ -0,0 +1,125 @@
+package sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column represents a column of the rows returned by a SQL query.
+type Column struct {
+	Name string `json:"name" yaml:"name"`
+	// Type is the type of the column in the database, such as VARCHAR or INT
+	Type string `json:"type" yaml:"type"`
+}
+
+// numericTypes are the database types whose values are returned as numbers
+var numericTypes = []string{"INT", "DEC", "NUMERIC", "NUMBER", "FLOAT", "DOUBLE", "REAL", "SERIAL", "MONEY"}
+
+// dateLayouts are the layouts of the dates returned as text, by MySQL without parseTime=true in its DSN
+var dateLayouts = map[string]string{
+	"DATE":      "2006-01-02",
+	"DATETIME":  "2006-01-02 15:04:05.999999999",
+	"TIMESTAMP": "2006-01-02 15:04:05.999999999",
+}
+
+// normalizeValue converts a value scanned by a driver so that all the drivers return the same types:
+// strings, json.Number for the numbers, RFC3339 strings for the dates, booleans and nil for NULL.
+func normalizeValue(v interface{}, dbType string) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		// MySQL and PostgreSQL return the numbers they don't convert as text
+		if isNumericType(dbType) {
+			if _, err := strconv.ParseFloat(string(x), 64); err == nil {
+				return json.Number(x)
+			}
+		}
+		if layout, ok := dateLayouts[dbType]; ok {
+			// the dates are in UTC, as the MySQL driver parses them by default
+			if t, err := time.ParseInLocation(layout, string(x), time.UTC); err == nil {
+				return t.Format(time.RFC3339Nano)
+			}
+		}
+		return string(x)
+	case string:
+		return x
+	case bool:
+		return x
+	case float64:
+		return json.Number(strconv.FormatFloat(x, 'f', -1, 64))
+	case float32:
+		return json.Number(strconv.FormatFloat(float64(x), 'f', -1, 32))
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		// ClickHouse returns the nullable columns as pointers
+		if rv.IsNil() {
+			return nil
+		}
+		if _, ok := v.(fmt.Stringer); !ok {
+			return normalizeValue(rv.Elem().Interface(), dbType)
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return json.Number(strconv.FormatInt(rv.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return json.Number(strconv.FormatUint(rv.Uint(), 10))
+	}
+	if s, ok := toString(rv); ok {
+		// ClickHouse and DuckDB return the decimals and the big integers as structs
+		if isNumericType(dbType) {
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return json.Number(s)
+			}
+		}
+		return s
+	}
+	return v
+}
+
+// toString returns the string of a value implementing fmt.Stringer, even with a pointer receiver.
+func toString(rv reflect.Value) (string, bool) {
+	if s, ok := rv.Interface().(fmt.Stringer); ok {
+		return s.String(), true
+	}
+	p := reflect.New(rv.Type())
+	p.Elem().Set(rv)
+	if s, ok := p.Interface().(fmt.Stringer); ok {
+		return s.String(), true
+	}
+	return "", false
+}
+
+func isNumericType(dbType string) bool {
+	for _, t := range numericTypes {
+		if strings.Contains(dbType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// QuoteIdentifier quotes a table name, the name can be prefixed by its schema
+func QuoteIdentifier(driver, name string) string {
+	open, closing := `"`, `"`
+	switch driver {
+	case "mysql", "clickhouse":
+		open, closing = "`", "`"
+	case "sqlserver", "mssql":
+		open, closing = "[", "]"
+	}
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = open + strings.ReplaceAll(p, closing, closing+closing) + closing
+	}
+	return strings.Join(parts, ".")
+}
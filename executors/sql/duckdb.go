@@ -0,0 +1,8 @@
+//go:build duckdb && cgo
+
+package sql
+
+import (
+	// DuckDB driver, it needs cgo and the duckdb build tag
+	_ "github.com/marcboeker/go-duckdb"
+)
@@ -0,0 +1,39 @@
+//go:build duckdb && cgo
+
+package sql
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+func TestExecutor_Run_DuckDB(t *testing.T) {
+	venom.InitTestLogger(t)
+	dsn := filepath.Join(t.TempDir(), "test.duckdb")
+
+	result := run(t, venom.TestStep{
+		"driver": "duckdb",
+		"dsn":    dsn,
+		"commands": []interface{}{
+			map[string]interface{}{"query": "CREATE TABLE products (id UTINYINT, name VARCHAR, price DECIMAL(10, 2), stock HUGEINT, available BOOLEAN)", "exec": true},
+			map[string]interface{}{"query": "INSERT INTO products VALUES (?, ?, ?, ?, ?)", "args": []interface{}{1, "foo", 12.5, 3, true}, "exec": true},
+			"SELECT id, name, price, stock, available, NULL AS nothing FROM products",
+		},
+	})
+	require.Len(t, result.Queries, 3)
+	assert.Equal(t, int64(1), result.Queries[1].RowsAffected)
+	assert.Equal(t, Row{
+		"id":        json.Number("1"),
+		"name":      "foo",
+		"price":     json.Number("12.5"),
+		"stock":     json.Number("3"),
+		"available": true,
+		"nothing":   nil,
+	}, result.Queries[2].Rows[0])
+}
@@ -0,0 +1,262 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ovh/venom"
+)
+
+// IsolationContextKey is the key used to store the isolation of a testcase in the context
+const IsolationContextKey = venom.ContextKey("sqlIsolationContext")
+
+// Isolation holds the databases of a testcase whose statements all run in a single transaction,
+// rolled back when the isolation is closed. The transactions begun by the steps are savepoints of this transaction.
+type Isolation struct {
+	mutex sync.Mutex
+	dbs   map[string]*isolatedDB
+}
+
+type isolatedDB struct {
+	db   *sqlx.DB
+	conn *isolatedConn
+}
+
+// NewIsolation returns an isolation without any database
+func NewIsolation() *Isolation {
+	return &Isolation{dbs: map[string]*isolatedDB{}}
+}
+
+// WithIsolation returns a context holding the isolation, the steps connecting to one of its databases use its transaction
+func WithIsolation(ctx context.Context, i *Isolation) context.Context {
+	return context.WithValue(ctx, IsolationContextKey, i)
+}
+
+func getIsolation(ctx context.Context) *Isolation {
+	i := ctx.Value(IsolationContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*Isolation)
+}
+
+// Open returns the database of the driver and DSN running in the transaction of the isolation, it is opened on first use
+func (i *Isolation) Open(ctx context.Context, driverName, dsn string) (*sqlx.DB, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	key := driverName + "|" + dsn
+	if d, ok := i.dbs[key]; ok {
+		return d.db, nil
+	}
+
+	venom.Debug(ctx, "beginning the transaction of the testcase on database %s, %s\n", driverName, dsn)
+	conn, err := openIsolatedConn(ctx, driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(&isolatedConnector{conn: conn})
+	// all the statements must use the connection holding the transaction
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	i.dbs[key] = &isolatedDB{db: sqlx.NewDb(db, driverName), conn: conn}
+	return i.dbs[key].db, nil
+}
+
+// lookup returns the database of the driver and DSN if it has been opened by the isolation
+func (i *Isolation) lookup(driverName, dsn string) *sqlx.DB {
+	if i == nil {
+		return nil
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if d, ok := i.dbs[driverName+"|"+dsn]; ok {
+		return d.db
+	}
+	return nil
+}
+
+// Close rolls back the transactions and closes the databases
+func (i *Isolation) Close() error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	var errs []string
+	for key, d := range i.dbs {
+		if err := d.db.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if err := d.conn.tx.Rollback(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if err := d.conn.Conn.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		delete(i.dbs, key)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to rollback the transactions of the testcase: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// isolatedConnector always returns the same connection, so that database/sql reuses the transaction
+type isolatedConnector struct {
+	conn *isolatedConn
+}
+
+func (c *isolatedConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+
+func (c *isolatedConnector) Driver() driver.Driver {
+	return c.conn.driver
+}
+
+// isolatedConn is a driver connection in a transaction, the transactions begun on it are savepoints
+type isolatedConn struct {
+	driver.Conn
+	driver     driver.Driver
+	driverName string
+	tx         driver.Tx
+	savepoints int
+}
+
+func openIsolatedConn(ctx context.Context, driverName, dsn string) (*isolatedConn, error) {
+	// sql.Open only looks up the driver, it does not connect
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to database")
+	}
+	drv := db.Driver()
+	db.Close() // nolint
+
+	var conn driver.Conn
+	if d, ok := drv.(driver.DriverContext); ok {
+		connector, err := d.OpenConnector(dsn)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to database")
+		}
+		conn, err = connector.Connect(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to database")
+		}
+	} else if conn, err = drv.Open(dsn); err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to database")
+	}
+
+	var tx driver.Tx
+	if c, ok := conn.(driver.ConnBeginTx); ok {
+		tx, err = c.BeginTx(ctx, driver.TxOptions{})
+	} else {
+		tx, err = conn.Begin() // nolint
+	}
+	if err != nil {
+		conn.Close() // nolint
+		return nil, errors.Wrapf(err, "failed to begin transaction")
+	}
+	return &isolatedConn{Conn: conn, driver: drv, driverName: driverName, tx: tx}, nil
+}
+
+// Close keeps the connection opened, it is closed by the isolation
+func (c *isolatedConn) Close() error {
+	return nil
+}
+
+func (c *isolatedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *isolatedConn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	c.savepoints++
+	tx := &savepointTx{conn: c, name: fmt.Sprintf("venom_%d", c.savepoints)}
+	save, _, _ := tx.queries()
+	if err := c.exec(ctx, save); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (c *isolatedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *isolatedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *isolatedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *isolatedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (c *isolatedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// exec runs a statement without arguments, with a prepared statement if the driver can't execute it directly
+func (c *isolatedConn) exec(ctx context.Context, query string) error {
+	_, err := c.ExecContext(ctx, query, nil)
+	if err != driver.ErrSkip {
+		return err
+	}
+	stmt, err := c.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil) // nolint
+	return err
+}
+
+// savepointTx is a transaction begun in the transaction of the testcase
+type savepointTx struct {
+	conn *isolatedConn
+	name string
+}
+
+// queries returns the statements creating, releasing and rolling back to the savepoint
+func (tx *savepointTx) queries() (save, release, rollback string) {
+	switch tx.conn.driverName {
+	case "sqlserver", "mssql":
+		// SQL Server releases the savepoints with the transaction
+		return "SAVE TRANSACTION " + tx.name, "", "ROLLBACK TRANSACTION " + tx.name
+	}
+	return "SAVEPOINT " + tx.name, "RELEASE SAVEPOINT " + tx.name, "ROLLBACK TO SAVEPOINT " + tx.name
+}
+
+func (tx *savepointTx) Commit() error {
+	_, release, _ := tx.queries()
+	if release == "" {
+		return nil
+	}
+	return tx.conn.exec(context.Background(), release)
+}
+
+func (tx *savepointTx) Rollback() error {
+	_, _, rollback := tx.queries()
+	return tx.conn.exec(context.Background(), rollback)
+}
@@ -0,0 +1,76 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+func TestIsolation(t *testing.T) {
+	dsn := newTestDB(t)
+
+	isolation := NewIsolation()
+	ctx := WithIsolation(context.Background(), isolation)
+	db, err := isolation.Open(ctx, "sqlite", dsn)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (name, age) VALUES ('bar', 21)")
+	require.NoError(t, err)
+
+	isolatedCount := func() int64 {
+		res, err := New().Run(ctx, venom.TestStep{
+			"driver":   "sqlite",
+			"dsn":      dsn,
+			"commands": []interface{}{"SELECT count(*) AS n FROM users"},
+		})
+		require.NoError(t, err)
+		n, err := res.(Result).Queries[0].Rows[0]["n"].(json.Number).Int64()
+		require.NoError(t, err)
+		return n
+	}
+	assert.EqualValues(t, 2, isolatedCount())
+
+	// the transactions of the steps are savepoints of the transaction of the testcase
+	_, err = New().Run(ctx, venom.TestStep{
+		"driver":   "sqlite",
+		"dsn":      dsn,
+		"rollback": true,
+		"exec":     true,
+		"commands": []interface{}{"DELETE FROM users"},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, isolatedCount())
+
+	_, err = New().Run(ctx, venom.TestStep{
+		"driver":      "sqlite",
+		"dsn":         dsn,
+		"transaction": true,
+		"exec":        true,
+		"commands":    []interface{}{"INSERT INTO users (name, age) VALUES ('baz', 7)"},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, isolatedCount())
+
+	require.NoError(t, isolation.Close())
+	assert.EqualValues(t, 1, count(t, dsn))
+	assert.Nil(t, isolation.lookup("sqlite", dsn))
+}
+
+func TestSavepointTx_queries(t *testing.T) {
+	save, release, rollback := (&savepointTx{conn: &isolatedConn{driverName: "postgres"}, name: "venom_1"}).queries()
+	assert.Equal(t, "SAVEPOINT venom_1", save)
+	assert.Equal(t, "RELEASE SAVEPOINT venom_1", release)
+	assert.Equal(t, "ROLLBACK TO SAVEPOINT venom_1", rollback)
+
+	// SQL Server has no release of the savepoints
+	for _, driverName := range []string{"sqlserver", "mssql"} {
+		save, release, rollback = (&savepointTx{conn: &isolatedConn{driverName: driverName}, name: "venom_1"}).queries()
+		assert.Equal(t, "SAVE TRANSACTION venom_1", save)
+		assert.Empty(t, release)
+		assert.Equal(t, "ROLLBACK TRANSACTION venom_1", rollback)
+	}
+}
@@ -0,0 +1,125 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ovh/venom"
+)
+
+// ContextKey is the key used to store the connection pools in the context
+const ContextKey = venom.ContextKey("sqlContext")
+
+// pools holds a connection pool by driver and DSN
+type pools struct {
+	mutex sync.Mutex
+	dbs   map[string]*sqlx.DB
+}
+
+type sqlContext struct {
+	pools *pools
+	// owned is true when the pools are not shared with the other testcases of the testsuite
+	owned bool
+}
+
+// Setup gets the connection pools shared by the testcases of the testsuite
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	if p := venom.TestSuiteResource(ctx, string(ContextKey), func() io.Closer { return newPools() }); p != nil {
+		return context.WithValue(ctx, ContextKey, &sqlContext{pools: p.(*pools)}), nil
+	}
+	return context.WithValue(ctx, ContextKey, &sqlContext{pools: newPools(), owned: true}), nil
+}
+
+// TearDown closes the connection pools if they are not shared with the testsuite
+func (Executor) TearDown(ctx context.Context) error {
+	sqlCtx := getSQLCtx(ctx)
+	if sqlCtx == nil || !sqlCtx.owned {
+		return nil
+	}
+	return sqlCtx.pools.Close()
+}
+
+func getSQLCtx(ctx context.Context) *sqlContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*sqlContext)
+}
+
+func newPools() *pools {
+	return &pools{dbs: map[string]*sqlx.DB{}}
+}
+
+// connect returns the connection pool of the driver and DSN of the step, it is created on first use.
+// Without Setup, a new connection pool is returned, it must be closed by the caller.
+// The database of an isolated testcase is always returned, so that the step runs in its transaction.
+func (e Executor) connect(ctx context.Context) (*sqlx.DB, bool, error) {
+	if db := getIsolation(ctx).lookup(e.Driver, e.DSN); db != nil {
+		venom.Debug(ctx, "using the transaction of the testcase on database %s, %s\n", e.Driver, e.DSN)
+		return db, true, nil
+	}
+
+	sqlCtx := getSQLCtx(ctx)
+	if sqlCtx == nil {
+		venom.Debug(ctx, "connecting to database %s, %s\n", e.Driver, e.DSN)
+		db, err := sqlx.Connect(e.Driver, e.DSN)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to connect to database")
+		}
+		e.configurePool(db)
+		return db, false, nil
+	}
+
+	p := sqlCtx.pools
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := e.Driver + "|" + e.DSN
+	db, ok := p.dbs[key]
+	if !ok {
+		venom.Debug(ctx, "connecting to database %s, %s\n", e.Driver, e.DSN)
+		var err error
+		db, err = sqlx.Connect(e.Driver, e.DSN)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to connect to database")
+		}
+		p.dbs[key] = db
+	}
+	e.configurePool(db)
+	return db, true, nil
+}
+
+// configurePool applies the pool size and the idle timeout of the step, the settings of the previous steps are kept otherwise
+func (e Executor) configurePool(db *sqlx.DB) {
+	if e.PoolSize > 0 {
+		db.SetMaxOpenConns(e.PoolSize)
+		db.SetMaxIdleConns(e.PoolSize)
+	}
+	if e.IdleTimeout > 0 {
+		db.SetConnMaxIdleTime(time.Duration(e.IdleTimeout) * time.Second)
+	}
+}
+
+// Close closes all the connection pools
+func (p *pools) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var errs []string
+	for key, db := range p.dbs {
+		if err := db.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		delete(p.dbs, key)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to close database connections: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
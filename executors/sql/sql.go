@@ -2,16 +2,25 @@ package sql
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 
+	// ClickHouse driver
+	_ "github.com/ClickHouse/clickhouse-go/v2"
+
 	// MySQL drivers
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 
+	// SQL Server driver
+	_ "github.com/microsoft/go-mssqldb"
+
 	// Postgres driver
 	_ "github.com/lib/pq"
 
@@ -27,6 +36,11 @@ import (
 // Name of the executor.
 const Name = "sql"
 
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
 // New returns a new executor that can execute SQL queries
 func New() venom.Executor {
 	return &Executor{}
@@ -34,10 +48,32 @@ func New() venom.Executor {
 
 // Executor is a venom executor can execute SQL queries
 type Executor struct {
-	File     string   `json:"file,omitempty" yaml:"file,omitempty"`
-	Commands []string `json:"commands,omitempty" yaml:"commands,omitempty"`
-	Driver   string   `json:"driver" yaml:"driver"`
-	DSN      string   `json:"dsn" yaml:"dsn"`
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Commands is a list of SQL queries, each one is either a string or a Command
+	Commands []interface{} `json:"commands,omitempty" yaml:"commands,omitempty"`
+	Driver   string        `json:"driver" yaml:"driver"`
+	DSN      string        `json:"dsn" yaml:"dsn"`
+	// Exec runs the commands and the file as statements returning no rows
+	Exec bool `json:"exec,omitempty" yaml:"exec,omitempty"`
+	// Transaction runs the commands in a single transaction, committed at the end of the step
+	Transaction bool `json:"transaction,omitempty" yaml:"transaction,omitempty"`
+	// Rollback runs the commands in a single transaction, rolled back at the end of the step
+	Rollback bool `json:"rollback,omitempty" yaml:"rollback,omitempty"`
+	// PoolSize is the maximum number of connections opened to the database
+	PoolSize int `json:"pool_size,omitempty" yaml:"pool_size,omitempty" mapstructure:"pool_size"`
+	// IdleTimeout closes the connections unused for this duration. In Seconds
+	IdleTimeout int `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty" mapstructure:"idle_timeout"`
+	// WaitUntil runs the commands again until the result matches its assertions
+	WaitUntil *venom.WaitUntil `json:"wait_until,omitempty" yaml:"wait_until,omitempty" mapstructure:"wait_until"`
+}
+
+// Command represents a SQL query with its bound parameters.
+type Command struct {
+	Query string `json:"query" yaml:"query"`
+	// Args is a list of positional parameters, or a map of named parameters (such as :name)
+	Args interface{} `json:"args,omitempty" yaml:"args,omitempty"`
+	// Exec runs the query as a statement returning no rows
+	Exec bool `json:"exec,omitempty" yaml:"exec,omitempty"`
 }
 
 // Rows represents an array of Row
@@ -48,12 +84,18 @@ type Row map[string]interface{}
 
 // QueryResult represents a rows return by a SQL query execution.
 type QueryResult struct {
-	Rows Rows `json:"rows,omitempty" yaml:"rows,omitempty"`
+	Rows     Rows     `json:"rows,omitempty" yaml:"rows,omitempty"`
+	Columns  []Column `json:"columns,omitempty" yaml:"columns,omitempty"`
+	RowCount int      `json:"rowcount" yaml:"rowcount"`
+	// RowsAffected and LastInsertID are set by the commands run with exec
+	RowsAffected int64 `json:"rows_affected,omitempty" yaml:"rows_affected,omitempty"`
+	LastInsertID int64 `json:"last_insert_id,omitempty" yaml:"last_insert_id,omitempty"`
 }
 
 // Result represents a step result.
 type Result struct {
-	Queries []QueryResult `json:"queries,omitempty" yaml:"queries,omitempty"`
+	Queries   []QueryResult          `json:"queries,omitempty" yaml:"queries,omitempty"`
+	WaitUntil *venom.WaitUntilResult `json:"wait_until,omitempty" yaml:"wait_until,omitempty"`
 }
 
 // Run implements the venom.Executor interface for Executor.
@@ -62,29 +104,62 @@ func (e Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, er
 	if err := mapstructure.Decode(step, &e); err != nil {
 		return nil, err
 	}
-	// Connect to the database and ping it.
-	venom.Debug(ctx, "connecting to database %s, %s\n", e.Driver, e.DSN)
-	db, err := sqlx.Connect(e.Driver, e.DSN)
+	// Connect to the database and ping it, or reuse the connection pool of a previous step.
+	db, pooled, err := e.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !pooled {
+		defer db.Close()
+	}
+
+	commands, err := e.commands()
+	if err != nil {
+		return nil, err
+	}
+
+	if e.WaitUntil == nil {
+		return e.execute(ctx, db, commands)
+	}
+	r, polling, err := e.WaitUntil.Poll(ctx, func() (interface{}, error) {
+		return e.execute(ctx, db, commands)
+	})
+	if err != nil && !errors.Is(err, venom.ErrWaitUntilTimeout) {
+		return nil, err
+	}
+	result := r.(Result)
+	result.WaitUntil = &polling
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to connect to database")
+		// the last result is reported with the timeout
+		return nil, &venom.ErrorWithResult{Result: result, Err: err}
+	}
+	return result, nil
+}
+
+// execute runs the commands, or the file, and returns their results
+func (e Executor) execute(ctx context.Context, db *sqlx.DB, commands []Command) (Result, error) {
+	var ext sqlx.Ext = db
+	if e.Transaction || e.Rollback {
+		tx, err := db.Beginx()
+		if err != nil {
+			return Result{}, errors.Wrapf(err, "failed to begin transaction")
+		}
+		// Rollback has no effect once the transaction is committed
+		defer tx.Rollback() // nolint
+		ext = tx
 	}
-	defer db.Close()
 
 	results := []QueryResult{}
 	// Execute commands on database
 	// if the argument is specified.
-	if len(e.Commands) != 0 {
-		for i, s := range e.Commands {
+	if len(commands) != 0 {
+		for i, c := range commands {
 			venom.Debug(ctx, "Executing command number %d\n", i)
-			rows, err := db.Queryx(s)
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to exec command number %d", i)
-			}
-			r, err := handleRows(rows)
+			r, err := e.run(ext, c)
 			if err != nil {
-				return nil, errors.Wrapf(err, "failed to parse SQL rows for command number %d", i)
+				return Result{}, errors.Wrapf(err, "failed to exec command number %d", i)
 			}
-			results = append(results, QueryResult{Rows: r})
+			results = append(results, r)
 		}
 	} else if e.File != "" {
 		workdir := venom.StringVarFromCtx(ctx, "venom.testsuite.workdir")
@@ -92,20 +167,39 @@ func (e Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, er
 		venom.Debug(ctx, "loading SQL file from %s\n", file)
 		sbytes, errs := os.ReadFile(file)
 		if errs != nil {
-			return nil, errs
+			return Result{}, errs
 		}
-		rows, err := db.Queryx(string(sbytes))
+		r, err := e.run(ext, Command{Query: string(sbytes)})
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to exec SQL file %q", file)
+			return Result{}, errors.Wrapf(err, "failed to exec SQL file %q", file)
 		}
-		r, err := handleRows(rows)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to parse SQL rows for SQL file %q", file)
+		results = append(results, r)
+	}
+
+	if tx, ok := ext.(*sqlx.Tx); ok {
+		if e.Rollback {
+			venom.Debug(ctx, "rolling back transaction")
+			if err := tx.Rollback(); err != nil {
+				return Result{}, errors.Wrapf(err, "failed to rollback transaction")
+			}
+		} else if err := tx.Commit(); err != nil {
+			return Result{}, errors.Wrapf(err, "failed to commit transaction")
 		}
-		results = append(results, QueryResult{Rows: r})
 	}
-	r := Result{Queries: results}
-	return r, nil
+	return Result{Queries: results}, nil
+}
+
+// Query runs the queries on a database opened by another executor, the results have the same types as the sql executor ones
+func Query(ctx context.Context, db *sql.DB, driverName string, queries ...string) ([]QueryResult, error) {
+	commands := make([]Command, len(queries))
+	for i, q := range queries {
+		commands[i] = Command{Query: q}
+	}
+	res, err := Executor{Driver: driverName}.execute(ctx, sqlx.NewDb(db, driverName), commands)
+	if err != nil {
+		return nil, err
+	}
+	return res.Queries, nil
 }
 
 // ZeroValueResult return an empty implementation of this executor result
@@ -118,19 +212,107 @@ func (e Executor) GetDefaultAssertions() venom.StepAssertions {
 	return venom.StepAssertions{Assertions: []venom.Assertion{}}
 }
 
-// handleRows iter on each SQL rows result sets and serialize it into a []Row.
-func handleRows(rows *sqlx.Rows) ([]Row, error) {
+// commands returns the commands of the step, a string being a query without parameters
+func (e Executor) commands() ([]Command, error) {
+	commands := make([]Command, 0, len(e.Commands))
+	for i, c := range e.Commands {
+		switch v := c.(type) {
+		case string:
+			commands = append(commands, Command{Query: v})
+		default:
+			var command Command
+			if err := mapstructure.Decode(v, &command); err != nil {
+				return nil, errors.Wrapf(err, "invalid command number %d", i)
+			}
+			if command.Query == "" {
+				return nil, fmt.Errorf("mandatory field query was empty in command number %d", i)
+			}
+			commands = append(commands, command)
+		}
+	}
+	return commands, nil
+}
+
+// run executes a command, as a query returning rows or as a statement if exec is set
+func (e Executor) run(ext sqlx.Ext, c Command) (QueryResult, error) {
+	query, args, err := bindArgs(ext, c)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	if e.Exec || c.Exec {
+		res, err := ext.Exec(query, args...)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		return execResult(res), nil
+	}
+	rows, err := ext.Queryx(query, args...)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	r, columns, err := handleRows(rows)
+	if err != nil {
+		return QueryResult{}, errors.Wrapf(err, "failed to parse SQL rows")
+	}
+	return QueryResult{Rows: r, Columns: columns, RowCount: len(r)}, nil
+}
+
+// bindArgs returns the query with its positional parameters, named parameters being converted to the bindvar type of the driver
+func bindArgs(ext sqlx.Ext, c Command) (string, []interface{}, error) {
+	switch args := c.Args.(type) {
+	case nil:
+		return c.Query, nil, nil
+	case []interface{}:
+		return c.Query, args, nil
+	case map[string]interface{}:
+		query, positional, err := sqlx.Named(c.Query, args)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "failed to bind named parameters")
+		}
+		return ext.Rebind(query), positional, nil
+	default:
+		return "", nil, fmt.Errorf("args must be a list or a map, got %T", c.Args)
+	}
+}
+
+// execResult returns the number of rows affected and the last inserted id, when supported by the driver
+func execResult(res sql.Result) QueryResult {
+	var r QueryResult
+	if n, err := res.RowsAffected(); err == nil {
+		r.RowsAffected = n
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		r.LastInsertID = id
+	}
+	return r
+}
+
+// handleRows iter on each SQL rows result sets and serialize it into a []Row, with the columns of the result set.
+func handleRows(rows *sqlx.Rows) ([]Row, []Column, error) {
 	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make([]Column, len(columnTypes))
+	for i, ct := range columnTypes {
+		columns[i] = Column{Name: ct.Name(), Type: strings.ToUpper(ct.DatabaseTypeName())}
+	}
+
 	res := []Row{}
 	for rows.Next() {
-		row := make(Row)
-		if err := rows.MapScan(row); err != nil {
-			return nil, err
+		values, err := rows.SliceScan()
+		if err != nil {
+			return nil, nil, err
+		}
+		row := make(Row, len(values))
+		for i, v := range values {
+			row[columns[i].Name] = normalizeValue(v, columns[i].Type)
 		}
 		res = append(res, row)
 	}
 	if err := rows.Err(); err != nil {
-		return res, err
+		return res, columns, err
 	}
-	return res, nil
+	return res, columns, nil
 }
@@ -0,0 +1,267 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+func newTestDB(t *testing.T) string {
+	venom.InitTestLogger(t)
+	// the busy timeout lets the tests write concurrently
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)"
+	run(t, venom.TestStep{
+		"driver": "sqlite",
+		"dsn":    dsn,
+		"exec":   true,
+		"commands": []interface{}{
+			"CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INTEGER)",
+			"INSERT INTO users (name, age) VALUES ('foo', 42)",
+		},
+	})
+	return dsn
+}
+
+func run(t *testing.T, step venom.TestStep) Result {
+	res, err := New().Run(context.Background(), step)
+	require.NoError(t, err)
+	return res.(Result)
+}
+
+func count(t *testing.T, dsn string) int64 {
+	result := run(t, venom.TestStep{
+		"driver":   "sqlite",
+		"dsn":      dsn,
+		"commands": []interface{}{"SELECT count(*) AS n FROM users"},
+	})
+	n, err := result.Queries[0].Rows[0]["n"].(json.Number).Int64()
+	require.NoError(t, err)
+	return n
+}
+
+func TestExecutor_Run_Args(t *testing.T) {
+	dsn := newTestDB(t)
+
+	result := run(t, venom.TestStep{
+		"driver": "sqlite",
+		"dsn":    dsn,
+		"commands": []interface{}{
+			map[string]interface{}{"query": "INSERT INTO users (name, age) VALUES (?, ?)", "args": []interface{}{"o'brien", 21}, "exec": true},
+			map[string]interface{}{"query": "SELECT name FROM users WHERE age = :age", "args": map[string]interface{}{"age": 21}},
+		},
+	})
+	require.Len(t, result.Queries, 2)
+	assert.Equal(t, int64(1), result.Queries[0].RowsAffected)
+	assert.Equal(t, int64(2), result.Queries[0].LastInsertID)
+	assert.Equal(t, "o'brien", result.Queries[1].Rows[0]["name"])
+}
+
+func TestExecutor_Run_Transaction(t *testing.T) {
+	dsn := newTestDB(t)
+
+	run(t, venom.TestStep{
+		"driver":   "sqlite",
+		"dsn":      dsn,
+		"rollback": true,
+		"exec":     true,
+		"commands": []interface{}{"DELETE FROM users"},
+	})
+	assert.EqualValues(t, 1, count(t, dsn))
+
+	run(t, venom.TestStep{
+		"driver":      "sqlite",
+		"dsn":         dsn,
+		"transaction": true,
+		"exec":        true,
+		"commands":    []interface{}{"DELETE FROM users"},
+	})
+	assert.EqualValues(t, 0, count(t, dsn))
+
+	// a failing command rolls back the previous ones
+	_, err := New().Run(context.Background(), venom.TestStep{
+		"driver":      "sqlite",
+		"dsn":         dsn,
+		"transaction": true,
+		"exec":        true,
+		"commands":    []interface{}{"INSERT INTO users (name, age) VALUES ('bar', 1)", "INSERT INTO unknown VALUES (1)"},
+	})
+	require.Error(t, err)
+	assert.EqualValues(t, 0, count(t, dsn))
+}
+
+func TestExecutor_commands(t *testing.T) {
+	_, err := Executor{Commands: []interface{}{map[string]interface{}{"args": []interface{}{1}}}}.commands()
+	assert.Error(t, err)
+
+	_, _, err = bindArgs(nil, Command{Query: "SELECT 1", Args: "foo"})
+	assert.Error(t, err)
+}
+
+func TestExecutor_Run_Pool(t *testing.T) {
+	dsn := newTestDB(t)
+
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+
+	step := venom.TestStep{
+		"driver":       "sqlite",
+		"dsn":          dsn,
+		"pool_size":    2,
+		"idle_timeout": 10,
+		"commands":     []interface{}{"SELECT name FROM users"},
+	}
+	for i := 0; i < 3; i++ {
+		res, err := e.Run(ctx, step)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", res.(Result).Queries[0].Rows[0]["name"])
+	}
+
+	sqlCtx := getSQLCtx(ctx)
+	require.Len(t, sqlCtx.pools.dbs, 1)
+	db := sqlCtx.pools.dbs["sqlite|"+dsn]
+	assert.Equal(t, 2, db.Stats().MaxOpenConnections)
+
+	require.NoError(t, e.TearDown(ctx))
+	assert.Empty(t, sqlCtx.pools.dbs)
+	assert.Error(t, db.Ping())
+}
+
+func TestExecutor_Run_WaitUntil(t *testing.T) {
+	dsn := newTestDB(t)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = New().Run(context.Background(), venom.TestStep{
+			"driver":   "sqlite",
+			"dsn":      dsn,
+			"exec":     true,
+			"commands": []interface{}{"INSERT INTO users (name, age) VALUES ('bar', 1)"},
+		})
+	}()
+
+	result := run(t, venom.TestStep{
+		"driver":   "sqlite",
+		"dsn":      dsn,
+		"commands": []interface{}{"SELECT name FROM users WHERE name = 'bar'"},
+		"wait_until": map[string]interface{}{
+			"assertions": []interface{}{"result.queries.queries0.rows.__Len__ ShouldEqual 1"},
+			"interval":   10,
+			"backoff":    1.5,
+			"timeout":    5000,
+		},
+	})
+	require.NotNil(t, result.WaitUntil)
+	assert.Greater(t, result.WaitUntil.Polls, 1)
+	assert.Equal(t, "bar", result.Queries[0].Rows[0]["name"])
+
+	_, err := New().Run(context.Background(), venom.TestStep{
+		"driver":   "sqlite",
+		"dsn":      dsn,
+		"commands": []interface{}{"SELECT name FROM users WHERE name = 'baz'"},
+		"wait_until": map[string]interface{}{
+			"assertions": []interface{}{"result.queries.queries0.rows ShouldNotBeEmpty"},
+			"interval":   10,
+			"timeout":    100,
+		},
+	})
+	assert.ErrorIs(t, err, venom.ErrWaitUntilTimeout)
+	// the last result is returned with the timeout
+	var errWithResult *venom.ErrorWithResult
+	require.ErrorAs(t, err, &errWithResult)
+	last := errWithResult.Result.(Result)
+	require.NotNil(t, last.WaitUntil)
+	assert.Greater(t, last.WaitUntil.Polls, 1)
+	assert.Equal(t, 0, last.Queries[0].RowCount)
+}
+
+func TestExecutor_Run_Types(t *testing.T) {
+	dsn := newTestDB(t)
+
+	result := run(t, venom.TestStep{
+		"driver": "sqlite",
+		"dsn":    dsn,
+		"commands": []interface{}{
+			"SELECT id, name, age, 1.5 AS ratio, NULL AS empty FROM users",
+			"SELECT name FROM users WHERE 1 = 0",
+		},
+	})
+	require.Len(t, result.Queries, 2)
+	q := result.Queries[0]
+	assert.Equal(t, 1, q.RowCount)
+	assert.Equal(t, []Column{
+		{Name: "id", Type: "INTEGER"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "age", Type: "INTEGER"},
+		{Name: "ratio", Type: ""},
+		{Name: "empty", Type: ""},
+	}, q.Columns)
+	assert.Equal(t, Row{
+		"id":    json.Number("1"),
+		"name":  "foo",
+		"age":   json.Number("42"),
+		"ratio": json.Number("1.5"),
+		"empty": nil,
+	}, q.Rows[0])
+	assert.Equal(t, 0, result.Queries[1].RowCount)
+
+	dump, err := venom.Dump(result)
+	require.NoError(t, err)
+	assert.Equal(t, "TEXT", dump["result.queries.queries0.columns.columns1.type"])
+	// NULL is dumped as an empty value
+	assert.Equal(t, "", dump["result.queries.queries0.rows.rows0.empty"])
+	assert.Equal(t, json.Number("42"), dump["result.queries.queries0.rows.rows0.age"])
+}
+
+func TestNormalizeValue(t *testing.T) {
+	assert.Equal(t, json.Number("12.30"), normalizeValue([]byte("12.30"), "DECIMAL"))
+	assert.Equal(t, "12.30", normalizeValue([]byte("12.30"), "VARCHAR"))
+	assert.Equal(t, json.Number("7"), normalizeValue(uint64(7), "BIGINT UNSIGNED"))
+	assert.Equal(t, "2023-01-02T03:04:05Z", normalizeValue(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), "TIMESTAMP"))
+	assert.Equal(t, "2023-01-02T03:04:05Z", normalizeValue([]byte("2023-01-02 03:04:05"), "DATETIME"))
+	assert.Equal(t, "2023-01-02T03:04:05.5Z", normalizeValue([]byte("2023-01-02 03:04:05.500000"), "TIMESTAMP"))
+	assert.Equal(t, "2023-01-02T00:00:00Z", normalizeValue([]byte("2023-01-02"), "DATE"))
+	assert.Equal(t, "0000-00-00", normalizeValue([]byte("0000-00-00"), "DATE"))
+	assert.Equal(t, true, normalizeValue(true, "BOOL"))
+	assert.Equal(t, json.Number("-3"), normalizeValue(int8(-3), "INT8"))
+	assert.Equal(t, json.Number("200"), normalizeValue(uint8(200), "UINT8"))
+	assert.Equal(t, json.Number("123456789012345678901234567890"), normalizeValue(bigInt("123456789012345678901234567890"), "INT256"))
+	s := "foo"
+	assert.Equal(t, "foo", normalizeValue(&s, "NULLABLE(STRING)"))
+	assert.Nil(t, normalizeValue((*string)(nil), "NULLABLE(STRING)"))
+	assert.Equal(t, json.Number("1.25"), normalizeValue(decimal{"1.25"}, "DECIMAL(10,2)"))
+}
+
+// decimal implements fmt.Stringer with a pointer receiver
+type decimal struct {
+	s string
+}
+
+func (d *decimal) String() string {
+	return d.s
+}
+
+func bigInt(s string) *big.Int {
+	i, _ := new(big.Int).SetString(s, 10)
+	return i
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, `"public"."users"`, QuoteIdentifier("postgres", "public.users"))
+	assert.Equal(t, "`users`", QuoteIdentifier("mysql", "users"))
+	assert.Equal(t, "[dbo].[users]", QuoteIdentifier("sqlserver", "dbo.users"))
+	assert.Equal(t, "[dbo].[users]", QuoteIdentifier("mssql", "dbo.users"))
+	assert.Equal(t, "`default`.`events`", QuoteIdentifier("clickhouse", "default.events"))
+	// the closing quotes are doubled
+	assert.Equal(t, "[my]]table]", QuoteIdentifier("sqlserver", "my]table"))
+	assert.Equal(t, "`my``table`", QuoteIdentifier("clickhouse", "my`table"))
+	assert.Equal(t, `"my""table"`, QuoteIdentifier("postgres", `my"table`))
+}
@@ -0,0 +1,292 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/ovh/venom"
+)
+
+// sshContext holds the connections opened during the testcase, to reuse them between steps
+type sshContext struct {
+	mutex       sync.Mutex
+	connections map[string]*connection
+}
+
+func getSSHCtx(ctx context.Context) *sshContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*sshContext)
+}
+
+// connection is a client connected to the host, directly or through a jump host
+type connection struct {
+	client *ssh.Client
+	// closers are closed after the client, in reverse order: the jump host client and the agent connection
+	closers []io.Closer
+}
+
+// Close closes the client, then the jump host client and the agent connection
+func (c *connection) Close() error {
+	var err error
+	if c.client != nil {
+		err = c.client.Close()
+	}
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		c.closers[i].Close() // nolint
+	}
+	return err
+}
+
+// alive checks that the server still answers on the connection
+func (c *connection) alive() bool {
+	_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
+
+// connectionKey identifies the connections which can be reused by a step
+func (e Executor) connectionKey() string {
+	key := fmt.Sprintf("%s@%s|%s|%s|%s|%t|%s|%t", e.User, e.Host, e.Password, e.PrivateKey, e.Certificate, e.Agent, e.KnownHosts, e.InsecureIgnoreHostKey)
+	if e.JumpHost != nil {
+		key += fmt.Sprintf("|%+v", *e.JumpHost)
+	}
+	return key
+}
+
+// getConnection returns the connection of the testcase to the host, opened by a previous step if it is still alive.
+// Outside of a testcase, a new connection is opened and must be closed by the caller.
+func getConnection(ctx context.Context, e Executor) (conn *connection, release func(), err error) {
+	sshCtx := getSSHCtx(ctx)
+	if sshCtx == nil {
+		conn, err := e.dial(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, func() { conn.Close() }, nil // nolint
+	}
+
+	sshCtx.mutex.Lock()
+	defer sshCtx.mutex.Unlock()
+	key := e.connectionKey()
+	if conn, ok := sshCtx.connections[key]; ok {
+		if conn.alive() {
+			venom.Debug(ctx, "reusing ssh connection to %s", e.Host)
+			return conn, func() {}, nil
+		}
+		conn.Close() // nolint
+		delete(sshCtx.connections, key)
+	}
+	conn, err = e.dial(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	sshCtx.connections[key] = conn
+	return conn, func() {}, nil
+}
+
+// dial opens a connection to the host, through the jump host if any
+func (e Executor) dial(ctx context.Context) (_ *connection, err error) {
+	conn := &connection{}
+	defer func() {
+		if err != nil {
+			conn.Close() // nolint
+		}
+	}()
+
+	// Default user is current username
+	u := e.User
+	if u == "" {
+		osUser, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		u = osUser.Username
+	}
+
+	hostKeyCallback, err := e.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	var agentClient agent.ExtendedAgent
+	if e.Agent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, fmt.Errorf("unable to use ssh-agent: SSH_AUTH_SOCK is not set")
+		}
+		agentConn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to ssh-agent: %w", err)
+		}
+		conn.closers = append(conn.closers, agentConn)
+		agentClient = agent.NewClient(agentConn)
+	}
+
+	host := withDefaultPort(e.Host)
+	var netConn net.Conn
+	if e.JumpHost != nil {
+		jumpUser := e.JumpHost.User
+		if jumpUser == "" {
+			jumpUser = u
+		}
+		auth, err := authMethods(e.JumpHost.Password, e.JumpHost.PrivateKey, e.JumpHost.Certificate, agentClient)
+		if err != nil {
+			return nil, fmt.Errorf("jump host: %w", err)
+		}
+		jumpHost := withDefaultPort(e.JumpHost.Host)
+		venom.Debug(ctx, "connecting to jump host %s", jumpHost)
+		jump, err := ssh.Dial("tcp", jumpHost, &ssh.ClientConfig{User: jumpUser, Auth: auth, HostKeyCallback: hostKeyCallback})
+		if err != nil {
+			return nil, fmt.Errorf("jump host: %w", err)
+		}
+		conn.closers = append(conn.closers, jump)
+		if netConn, err = jump.Dial("tcp", host); err != nil {
+			return nil, fmt.Errorf("unable to reach %s from jump host: %w", host, err)
+		}
+	} else {
+		if netConn, err = net.Dial("tcp", host); err != nil {
+			return nil, err
+		}
+	}
+
+	auth, err := authMethods(e.Password, e.PrivateKey, e.Certificate, agentClient)
+	if err != nil {
+		netConn.Close() // nolint
+		return nil, err
+	}
+	venom.Debug(ctx, "connecting to %s as %s", host, u)
+	c, chans, reqs, err := ssh.NewClientConn(netConn, host, &ssh.ClientConfig{User: u, Auth: auth, HostKeyCallback: hostKeyCallback})
+	if err != nil {
+		netConn.Close() // nolint
+		return nil, err
+	}
+	conn.client = ssh.NewClient(c, chans, reqs)
+	return conn, nil
+}
+
+// withDefaultPort adds the default port to the host if it doesn't contain one
+func withDefaultPort(host string) string {
+	if !strings.Contains(host, ":") {
+		return host + ":22"
+	}
+	return host
+}
+
+// hostKeyCallback checks the host keys against the known_hosts file, unless InsecureIgnoreHostKey is set
+func (e Executor) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if e.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	// Default known_hosts file is $HOME/.ssh/known_hosts
+	file := e.KnownHosts
+	if file == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(usr.HomeDir, ".ssh", "known_hosts")
+	} else {
+		file = os.ExpandEnv(file)
+	}
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known_hosts file: %w", err)
+	}
+	return callback, nil
+}
+
+// authMethods returns the password if there is no private key, or the private key, then the ssh-agent keys if any
+func authMethods(pass, key, cert string, agentClient agent.ExtendedAgent) ([]ssh.AuthMethod, error) {
+	// The client tries each method once, so the private key and the agent keys are tried by the same method
+	var signers []ssh.Signer
+	publicKeys := ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		if agentClient == nil {
+			return signers, nil
+		}
+		agentSigners, err := agentClient.Signers()
+		if err != nil {
+			return nil, err
+		}
+		return append(signers, agentSigners...), nil
+	})
+
+	// If password is set, and we don't have key use it
+	if pass != "" && key == "" {
+		if agentClient != nil {
+			return []ssh.AuthMethod{publicKeys, ssh.Password(pass)}, nil
+		}
+		return []ssh.AuthMethod{ssh.Password(pass)}, nil
+	}
+	// The agent keys are enough if no key is set
+	if key == "" && agentClient != nil {
+		return []ssh.AuthMethod{publicKeys}, nil
+	}
+
+	// Load the the private key
+	signer, err := privateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if cert != "" {
+		if signer, err = certSigner(signer, cert); err != nil {
+			return nil, err
+		}
+	}
+	signers = append(signers, signer)
+	return []ssh.AuthMethod{publicKeys}, nil
+}
+
+func privateKey(file string) (key ssh.Signer, err error) {
+	// Default private key is $HOME/.ssh/id_rsa
+	if file == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(usr.HomeDir + "/.ssh/id_rsa")
+	} else {
+		file = os.ExpandEnv(file)
+	}
+
+	// Read the file
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	// Parse it
+	key, err = ssh.ParsePrivateKey(buf)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// certSigner returns a signer authenticating with the certificate of the private key
+func certSigner(key ssh.Signer, file string) (ssh.Signer, error) {
+	buf, err := os.ReadFile(os.ExpandEnv(file))
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(buf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate %s: %w", file, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", file)
+	}
+	return ssh.NewCertSigner(cert, key)
+}
@@ -0,0 +1,101 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/ovh/venom"
+)
+
+// upload copies the local file to the remote path, creating the remote directories.
+// A relative local path is relative to the directory of the testsuite.
+func (e Executor) upload(ctx context.Context, client *ssh.Client) (Result, error) {
+	local := e.Local
+	if !filepath.IsAbs(local) {
+		local = filepath.Join(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), local)
+	}
+	src, err := os.Open(local)
+	if err != nil {
+		return Result{}, err
+	}
+	defer src.Close()
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to start sftp session: %w", err)
+	}
+	defer sftpClient.Close()
+
+	if err := sftpClient.MkdirAll(path.Dir(e.Remote)); err != nil {
+		return Result{}, fmt.Errorf("unable to create remote directory %s: %w", path.Dir(e.Remote), err)
+	}
+	dst, err := sftpClient.OpenFile(e.Remote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to create remote file %s: %w", e.Remote, err)
+	}
+	defer dst.Close()
+
+	size, err := io.Copy(dst, src)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to upload %s: %w", e.Local, err)
+	}
+	if e.Mode != "" {
+		mode, err := strconv.ParseUint(e.Mode, 8, 32)
+		if err != nil {
+			return Result{}, fmt.Errorf("invalid mode %q: %w", e.Mode, err)
+		}
+		if err := dst.Chmod(os.FileMode(mode)); err != nil {
+			return Result{}, fmt.Errorf("unable to change mode of remote file %s: %w", e.Remote, err)
+		}
+	}
+	venom.Debug(ctx, "uploaded %s to %s (%d bytes)", local, e.Remote, size)
+	return Result{Code: "0", Path: e.Remote, Size: size}, nil
+}
+
+// download copies the remote file to the local path, creating the local directories.
+// A relative local path is relative to the output directory, default local path is the name of the remote file.
+func (e Executor) download(ctx context.Context, client *ssh.Client) (Result, error) {
+	local := e.Local
+	if local == "" {
+		local = path.Base(e.Remote)
+	}
+	if !filepath.IsAbs(local) {
+		local = filepath.Join(venom.StringVarFromCtx(ctx, "venom.outputdir"), local)
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to start sftp session: %w", err)
+	}
+	defer sftpClient.Close()
+
+	src, err := sftpClient.Open(e.Remote)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to open remote file %s: %w", e.Remote, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(local), os.ModePerm); err != nil {
+		return Result{}, err
+	}
+	dst, err := os.Create(local)
+	if err != nil {
+		return Result{}, err
+	}
+	defer dst.Close()
+
+	size, err := io.Copy(dst, src)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to download %s: %w", e.Remote, err)
+	}
+	venom.Debug(ctx, "downloaded %s to %s (%d bytes)", e.Remote, local, size)
+	return Result{Code: "0", Path: local, Size: size}, nil
+}
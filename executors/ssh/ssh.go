@@ -4,9 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
-	"os/user"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,12 +16,27 @@ import (
 	"github.com/ovh/venom"
 )
 
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
 // Name for test ssh
 const (
 	Name       = "ssh"
 	sudoprompt = "sudo_venom"
 )
 
+// ContextKey is the key used to store the connections opened during the testcase in the context
+const ContextKey = venom.ContextKey("sshContext")
+
+// Actions of the executor
+const (
+	actionExec     = "exec"
+	actionUpload   = "upload"
+	actionDownload = "download"
+)
+
 // New returns a new Test Exec
 func New() venom.Executor {
 	return &Executor{}
@@ -38,14 +51,44 @@ type Executor struct {
 	PrivateKey   string `json:"privatekey,omitempty" yaml:"privatekey,omitempty"`
 	Sudo         string `json:"sudo,omitempty" yaml:"sudo,omitempty"`
 	SudoPassword string `json:"sudopassword,omitempty" yaml:"sudopassword,omitempty"`
+	// Action is "exec", "upload" or "download". Default is "exec"
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+	// Certificate of the private key, signed by a certificate authority trusted by the server
+	Certificate string `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+	// Agent authenticates with the keys of the ssh-agent listening on SSH_AUTH_SOCK
+	Agent bool `json:"agent,omitempty" yaml:"agent,omitempty"`
+	// KnownHosts is the file used to check the host keys. Default is $HOME/.ssh/known_hosts
+	KnownHosts            string `json:"knownhosts,omitempty" yaml:"knownhosts,omitempty"`
+	InsecureIgnoreHostKey bool   `json:"insecure_ignore_host_key,omitempty" yaml:"insecure_ignore_host_key,omitempty" mapstructure:"insecure_ignore_host_key"`
+	// JumpHost is the bastion used to reach the host
+	JumpHost *JumpHost `json:"jump_host,omitempty" yaml:"jump_host,omitempty" mapstructure:"jump_host"`
+	// Env variables exported before running the command
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	// Local and Remote paths of the file to upload or download
+	Local  string `json:"local,omitempty" yaml:"local,omitempty"`
+	Remote string `json:"remote,omitempty" yaml:"remote,omitempty"`
+	// Mode of the uploaded file, in octal notation
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// JumpHost represents a bastion. Its host keys are checked, and the ssh-agent is used, as for the host
+type JumpHost struct {
+	Host        string `json:"host,omitempty" yaml:"host,omitempty"`
+	User        string `json:"user,omitempty" yaml:"user,omitempty"`
+	Password    string `json:"password,omitempty" yaml:"password,omitempty"`
+	PrivateKey  string `json:"privatekey,omitempty" yaml:"privatekey,omitempty"`
+	Certificate string `json:"certificate,omitempty" yaml:"certificate,omitempty"`
 }
 
 // Result represents a step result
 type Result struct {
-	Systemout   string  `json:"systemout,omitempty" yaml:"systemout,omitempty"`
-	Systemerr   string  `json:"systemerr,omitempty" yaml:"systemerr,omitempty"`
-	Err         string  `json:"err,omitempty" yaml:"err,omitempty"`
-	Code        string  `json:"code,omitempty" yaml:"code,omitempty"`
+	Systemout string `json:"systemout,omitempty" yaml:"systemout,omitempty"`
+	Systemerr string `json:"systemerr,omitempty" yaml:"systemerr,omitempty"`
+	Err       string `json:"err,omitempty" yaml:"err,omitempty"`
+	Code      string `json:"code,omitempty" yaml:"code,omitempty"`
+	// Path and Size of the uploaded or downloaded file
+	Path        string  `json:"path,omitempty" yaml:"path,omitempty"`
+	Size        int64   `json:"size,omitempty" yaml:"size,omitempty"`
 	TimeSeconds float64 `json:"timeseconds,omitempty" yaml:"timeseconds,omitempty"`
 }
 
@@ -59,6 +102,26 @@ func (Executor) GetDefaultAssertions() *venom.StepAssertions {
 	return &venom.StepAssertions{Assertions: []venom.Assertion{"result.code ShouldEqual 0"}}
 }
 
+// Setup prepares the holder of the connections opened during the testcase
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	return context.WithValue(ctx, ContextKey, &sshContext{connections: map[string]*connection{}}), nil
+}
+
+// TearDown closes all the connections opened during the testcase
+func (Executor) TearDown(ctx context.Context) error {
+	sshCtx := getSSHCtx(ctx)
+	if sshCtx == nil {
+		return nil
+	}
+	sshCtx.mutex.Lock()
+	defer sshCtx.mutex.Unlock()
+	for key, conn := range sshCtx.connections {
+		conn.Close() // nolint
+		delete(sshCtx.connections, key)
+	}
+	return nil
+}
+
 // Run execute TestStep of type exec
 func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, error) {
 	var e Executor
@@ -66,56 +129,42 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 		return nil, err
 	}
 
-	if e.Command == "" {
-		return nil, fmt.Errorf("Invalid command")
+	switch e.Action {
+	case "", actionExec:
+		if e.Command == "" {
+			return nil, fmt.Errorf("Invalid command")
+		}
+	case actionUpload:
+		if e.Local == "" || e.Remote == "" {
+			return nil, fmt.Errorf("local and remote are mandatory to upload a file")
+		}
+	case actionDownload:
+		if e.Remote == "" {
+			return nil, fmt.Errorf("remote is mandatory to download a file")
+		}
+	default:
+		return nil, fmt.Errorf("action %q must be %s, %s or %s", e.Action, actionExec, actionUpload, actionDownload)
 	}
 
 	start := time.Now()
 	result := Result{}
 
-	client, session, err := connectToHost(e.User, e.Password, e.PrivateKey, e.Host, e.Sudo)
+	conn, release, err := getConnection(ctx, e)
 	if err != nil {
 		result.Err = err.Error()
 	} else {
-		defer client.Close()
-		stdout := &Buffer{}
-		stderr := &Buffer{}
-
-		session.Stderr = stderr
-		session.Stdout = stdout
-		stdin, _ := session.StdinPipe()
-
-		// Handle sudo password
-		command := e.Command
-		quit := make(chan bool)
-		if e.Sudo != "" {
-			command = "TERM=xterm-mono sudo -S -p " + sudoprompt + " -u " + e.Sudo + " " + command
-			if e.SudoPassword == "" {
-				e.SudoPassword = e.Password
-			}
-			go handleSudo(stdin, stdout, quit, e.SudoPassword)
-		}
-
-		if err := session.Run(command); err != nil {
-			if exiterr, ok := err.(*ssh.ExitError); ok {
-				status := exiterr.ExitStatus()
-				result.Code = strconv.Itoa(status)
-			} else if _, ok := err.(*ssh.ExitMissingError); ok {
-				result.Code = strconv.Itoa(127)
-				result.Err = err.Error()
-			} else {
-				result.Code = strconv.Itoa(137)
-				result.Err = err.Error()
-			}
-		} else {
-			result.Code = "0"
+		defer release()
+		switch e.Action {
+		case actionUpload:
+			result, err = e.upload(ctx, conn.client)
+		case actionDownload:
+			result, err = e.download(ctx, conn.client)
+		default:
+			result, err = e.exec(conn.client)
 		}
-
-		if e.Sudo != "" {
-			quit <- true
+		if err != nil {
+			result.Err = err.Error()
 		}
-		result.Systemerr = strings.TrimSpace(stderr.String())
-		result.Systemout = strings.TrimSpace(stdout.String())
 	}
 
 	elapsed := time.Since(start)
@@ -124,108 +173,116 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 	return result, nil
 }
 
-func handleSudo(in io.Writer, out *Buffer, quit chan bool, password string) {
-	sudopromptlen := len(sudoprompt)
-	for {
-		select {
-		case <-quit:
-			return
-		default:
-			content := out.String()
-			bufferLen := utf8.RuneCountInString(content)
-
-			// Check if we have to enter password
-			if bufferLen >= sudopromptlen && strings.Contains(content[bufferLen-sudopromptlen:], sudoprompt) {
-				in.Write([]byte(password + "\n"))
-				out.Truncate(0)
-			}
-		}
-	}
-}
-
-func connectToHost(u, pass, key, host, sudo string) (*ssh.Client, *ssh.Session, error) {
-	// Default user is current username
-	if u == "" {
-		osUser, err := user.Current()
-		if err != nil {
-			return nil, nil, err
-		}
-		u = osUser.Username
-	}
-
-	// If password is set, and we don't have key use it
-	var auth []ssh.AuthMethod
-	if pass != "" && key == "" {
-		auth = []ssh.AuthMethod{ssh.Password(pass)}
-	} else {
-		// Load the the private key
-		key, err := privateKey(key)
-		if err != nil {
-			return nil, nil, err
-		}
-		auth = []ssh.AuthMethod{ssh.PublicKeys(key)}
-	}
-
-	sshConfig := &ssh.ClientConfig{
-		User:            u,
-		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	// If host doen't contain port, set the default port
-	if !strings.Contains(host, ":") {
-		host += ":22"
-	}
-
-	// Open the tcp connection
-	client, err := ssh.Dial("tcp", host, sshConfig)
-	if err != nil {
-		return nil, nil, err
-	}
+// exec runs the command in a new session
+func (e Executor) exec(client *ssh.Client) (Result, error) {
+	result := Result{}
 
 	// New ssh session
 	session, err := client.NewSession()
 	if err != nil {
-		client.Close()
-		return nil, nil, err
+		return result, err
 	}
+	defer session.Close()
 
 	// Request PTY for sudo cmd
-	if sudo != "" {
+	if e.Sudo != "" {
 		modes := ssh.TerminalModes{
 			ssh.TTY_OP_ISPEED: 14400, // input speed = 14.4kbaud
 			ssh.TTY_OP_OSPEED: 14400, // output speed = 14.4kbaud
 		}
 
 		if err := session.RequestPty("xterm", 40, 80, modes); err != nil {
-			return nil, nil, err
+			return result, err
 		}
 	}
 
-	return client, session, nil
-}
+	stdout := &Buffer{}
+	stderr := &Buffer{}
 
-func privateKey(file string) (key ssh.Signer, err error) {
-	// Default private key is $HOME/.ssh/id_rsa
-	if file == "" {
-		usr, err := user.Current()
-		if err != nil {
-			return nil, err
+	session.Stderr = stderr
+	session.Stdout = stdout
+	stdin, _ := session.StdinPipe()
+
+	// Handle sudo password
+	quit := make(chan bool)
+	if e.Sudo != "" {
+		if e.SudoPassword == "" {
+			e.SudoPassword = e.Password
+		}
+		go handleSudo(stdin, stdout, quit, e.SudoPassword)
+	}
+
+	if err := session.Run(e.commandLine()); err != nil {
+		if exiterr, ok := err.(*ssh.ExitError); ok {
+			status := exiterr.ExitStatus()
+			result.Code = strconv.Itoa(status)
+		} else if _, ok := err.(*ssh.ExitMissingError); ok {
+			result.Code = strconv.Itoa(127)
+			result.Err = err.Error()
+		} else {
+			result.Code = strconv.Itoa(137)
+			result.Err = err.Error()
 		}
-		file = filepath.Join(usr.HomeDir + "/.ssh/id_rsa")
 	} else {
-		file = os.ExpandEnv(file)
+		result.Code = "0"
 	}
 
-	// Read the file
-	buf, err := os.ReadFile(file)
-	if err != nil {
-		return nil, err
+	if e.Sudo != "" {
+		quit <- true
 	}
-	// Parse it
-	key, err = ssh.ParsePrivateKey(buf)
-	if err != nil {
-		return nil, err
+	result.Systemerr = strings.TrimSpace(stderr.String())
+	result.Systemout = strings.TrimSpace(stdout.String())
+	return result, nil
+}
+
+// commandLine returns the command run by the session, with its variables exported and run by sudo
+func (e Executor) commandLine() string {
+	// Servers usually refuse the environment requests, so the variables are exported by the command
+	command := exportEnv(e.Env) + e.Command
+	if e.Sudo == "" {
+		return command
+	}
+	// sudo resets the environment, so the variables are exported by the shell it runs
+	if len(e.Env) > 0 {
+		command = "sh -c " + shellQuote(command)
+	}
+	return "TERM=xterm-mono sudo -S -p " + sudoprompt + " -u " + e.Sudo + " " + command
+}
+
+// exportEnv returns the shell commands exporting the variables, sorted by name
+func exportEnv(env map[string]string) string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString("export " + name + "=" + shellQuote(env[name]) + "; ")
+	}
+	return b.String()
+}
+
+// shellQuote quotes the value for the shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func handleSudo(in io.Writer, out *Buffer, quit chan bool, password string) {
+	sudopromptlen := len(sudoprompt)
+	for {
+		select {
+		case <-quit:
+			return
+		default:
+			content := out.String()
+			bufferLen := utf8.RuneCountInString(content)
+
+			// Check if we have to enter password
+			if bufferLen >= sudopromptlen && strings.Contains(content[bufferLen-sudopromptlen:], sudoprompt) {
+				in.Write([]byte(password + "\n"))
+				out.Truncate(0)
+			}
+		}
 	}
-	return key, nil
 }
@@ -0,0 +1,366 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/ovh/venom"
+)
+
+// testServer is an in-process ssh server running the commands with sh, serving sftp and forwarding tcp connections
+type testServer struct {
+	addr        string
+	hostKey     ssh.Signer
+	connections atomic.Int32
+}
+
+func newTestServer(t *testing.T, config *ssh.ServerConfig) *testServer {
+	hostKey := newSigner(t)
+	config.AddHostKey(hostKey)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	s := &testServer{addr: l.Addr().String(), hostKey: hostKey}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(c net.Conn, config *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(c, config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	s.connections.Add(1)
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go serveSession(channel, requests)
+		case "direct-tcpip":
+			var target struct {
+				Host       string
+				Port       uint32
+				OriginHost string
+				OriginPort uint32
+			}
+			if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			targetConn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+			if err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				targetConn.Close()
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go func() {
+				io.Copy(targetConn, channel)
+				targetConn.Close()
+			}()
+			go func() {
+				io.Copy(channel, targetConn)
+				channel.Close()
+			}()
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+		}
+	}
+}
+
+func serveSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			ssh.Unmarshal(req.Payload, &payload)
+			req.Reply(true, nil)
+			cmd := exec.Command("sh", "-c", payload.Command)
+			cmd.Stdout, cmd.Stderr = channel, channel.Stderr()
+			status := uint32(0)
+			if err := cmd.Run(); err != nil {
+				status = 1
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					status = uint32(exitErr.ExitCode())
+				}
+			}
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			ssh.Unmarshal(req.Payload, &payload)
+			req.Reply(payload.Name == "sftp", nil)
+			if payload.Name != "sftp" {
+				continue
+			}
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return signer
+}
+
+// writePrivateKey writes a new private key in the directory, and returns its path and signer
+func writePrivateKey(t *testing.T, dir string) (string, ssh.Signer) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	file := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(block), 0o600))
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return file, signer
+}
+
+// writeKnownHosts writes a known_hosts file with the host keys of the servers
+func writeKnownHosts(t *testing.T, dir string, servers ...*testServer) string {
+	file := filepath.Join(dir, "known_hosts")
+	content := ""
+	for _, s := range servers {
+		content += knownhosts.Line([]string{knownhosts.Normalize(s.addr)}, s.hostKey.PublicKey()) + "\n"
+	}
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func passwordConfig(user, password string) *ssh.ServerConfig {
+	return &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid credentials")
+		},
+	}
+}
+
+func TestExecutor_Run(t *testing.T) {
+	venom.InitTestLogger(t)
+	s := newTestServer(t, passwordConfig("venom", "secret"))
+	dir := t.TempDir()
+	outputDir := t.TempDir()
+	knownHosts := writeKnownHosts(t, dir, s)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "script.sh"), []byte("echo uploaded\n"), 0o644))
+
+	e := New().(*Executor)
+	ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.testsuite.workdir"), dir)
+	ctx = context.WithValue(ctx, venom.ContextKey("var.venom.outputdir"), outputDir)
+	ctx, err := e.Setup(ctx, venom.H{})
+	require.NoError(t, err)
+
+	run := func(step venom.TestStep) Result {
+		step["host"] = s.addr
+		step["user"] = "venom"
+		step["password"] = "secret"
+		step["knownhosts"] = knownHosts
+		res, err := e.Run(ctx, step)
+		require.NoError(t, err)
+		return res.(Result)
+	}
+
+	res := run(venom.TestStep{
+		"command": "echo $GREETING; echo oops >&2; exit 3",
+		"env":     map[string]interface{}{"GREETING": "it's venom"},
+	})
+	assert.Empty(t, res.Err)
+	assert.Equal(t, "3", res.Code)
+	assert.Equal(t, "it's venom", res.Systemout)
+	assert.Equal(t, "oops", res.Systemerr)
+
+	remote := filepath.Join(t.TempDir(), "scripts", "script.sh")
+	res = run(venom.TestStep{"action": "upload", "local": "script.sh", "remote": remote, "mode": "0755"})
+	require.Empty(t, res.Err)
+	assert.Equal(t, "0", res.Code)
+	assert.Equal(t, int64(14), res.Size)
+	info, err := os.Stat(remote)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	res = run(venom.TestStep{"command": remote})
+	assert.Equal(t, "uploaded", res.Systemout)
+
+	res = run(venom.TestStep{"action": "download", "remote": remote})
+	require.Empty(t, res.Err)
+	assert.Equal(t, filepath.Join(outputDir, "script.sh"), res.Path)
+	content, err := os.ReadFile(res.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "echo uploaded\n", string(content))
+
+	res = run(venom.TestStep{"action": "download", "remote": filepath.Join(dir, "unknown")})
+	assert.Contains(t, res.Err, "unable to open remote file")
+	assert.Empty(t, res.Code)
+
+	// the connection is reused by all the steps of the testcase
+	assert.Equal(t, int32(1), s.connections.Load())
+	require.NoError(t, e.TearDown(ctx))
+	assert.Empty(t, getSSHCtx(ctx).connections)
+}
+
+func TestExecutor_Run_HostKey(t *testing.T) {
+	venom.InitTestLogger(t)
+	s := newTestServer(t, passwordConfig("venom", "secret"))
+	other := newTestServer(t, passwordConfig("venom", "secret"))
+	// the known_hosts file has the key of the other server for the address of the server
+	other.addr = s.addr
+	knownHosts := writeKnownHosts(t, t.TempDir(), other)
+
+	step := venom.TestStep{"host": s.addr, "user": "venom", "password": "secret", "command": "true", "knownhosts": knownHosts}
+	res, err := New().Run(context.Background(), step)
+	require.NoError(t, err)
+	assert.Contains(t, res.(Result).Err, "key mismatch")
+
+	step["insecure_ignore_host_key"] = true
+	res, err = New().Run(context.Background(), step)
+	require.NoError(t, err)
+	assert.Empty(t, res.(Result).Err)
+	assert.Equal(t, "0", res.(Result).Code)
+}
+
+func TestExecutor_Run_JumpHost(t *testing.T) {
+	venom.InitTestLogger(t)
+	dir := t.TempDir()
+
+	// the jump host accepts the key of the ssh-agent
+	_, agentKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	agentSigner, err := ssh.NewSignerFromKey(agentKey)
+	require.NoError(t, err)
+	keyring := agent.NewKeyring()
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: agentKey}))
+	jump := newTestServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == "bastion" && string(key.Marshal()) == string(agentSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	})
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, c)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	// the host accepts the certificates signed by the authority
+	authority := newSigner(t)
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(authority.PublicKey().Marshal())
+		},
+	}
+	host := newTestServer(t, &ssh.ServerConfig{PublicKeyCallback: checker.Authenticate})
+	keyFile, userKey := writePrivateKey(t, dir)
+	cert := &ssh.Certificate{
+		Key:             userKey.PublicKey(),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"venom"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, authority))
+	certFile := filepath.Join(dir, "id_ed25519-cert.pub")
+	require.NoError(t, os.WriteFile(certFile, ssh.MarshalAuthorizedKey(cert), 0o600))
+
+	step := venom.TestStep{
+		"host":        host.addr,
+		"user":        "venom",
+		"privatekey":  keyFile,
+		"certificate": certFile,
+		"agent":       true,
+		"knownhosts":  writeKnownHosts(t, dir, jump, host),
+		"jump_host":   map[string]interface{}{"host": jump.addr, "user": "bastion"},
+		"command":     "echo through the bastion",
+	}
+	res, err := New().Run(context.Background(), step)
+	require.NoError(t, err)
+	assert.Empty(t, res.(Result).Err)
+	assert.Equal(t, "through the bastion", res.(Result).Systemout)
+
+	delete(step, "certificate")
+	res, err = New().Run(context.Background(), step)
+	require.NoError(t, err)
+	assert.Contains(t, res.(Result).Err, "unable to authenticate")
+}
+
+func TestExecutor_Run_InvalidAction(t *testing.T) {
+	_, err := New().Run(context.Background(), venom.TestStep{"host": "localhost", "action": "copy"})
+	assert.EqualError(t, err, `action "copy" must be exec, upload or download`)
+
+	_, err = New().Run(context.Background(), venom.TestStep{"host": "localhost", "action": "upload", "remote": "/tmp/file"})
+	assert.EqualError(t, err, "local and remote are mandatory to upload a file")
+}
+
+func TestExecutor_commandLine(t *testing.T) {
+	e := Executor{Command: "echo $GREETING", Env: map[string]string{"GREETING": "it's venom"}}
+	assert.Equal(t, `export GREETING='it'\''s venom'; echo $GREETING`, e.commandLine())
+
+	// sudo resets the environment, the variables are exported by the shell it runs
+	e.Sudo = "root"
+	assert.Equal(t, `TERM=xterm-mono sudo -S -p sudo_venom -u root sh -c 'export GREETING='\''it'\''\'\'''\''s venom'\''; echo $GREETING'`, e.commandLine())
+	out, err := exec.Command("sh", "-c", strings.TrimPrefix(e.commandLine(), "TERM=xterm-mono sudo -S -p sudo_venom -u root ")).Output()
+	require.NoError(t, err)
+	assert.Equal(t, "it's venom\n", string(out))
+
+	e.Env = nil
+	assert.Equal(t, "TERM=xterm-mono sudo -S -p sudo_venom -u root echo $GREETING", e.commandLine())
+}
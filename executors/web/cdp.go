@@ -0,0 +1,715 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/gosimple/slug"
+	"github.com/kevinramage/venomWeb/common"
+	"github.com/pkg/errors"
+
+	"github.com/ovh/venom"
+)
+
+// defaultCDPTimeout is the timeout of the actions, as the web driver one
+const defaultCDPTimeout = 180 * time.Second
+
+// cdpSession drives a Chromium browser through the DevTools Protocol, without web driver
+type cdpSession struct {
+	cancels []context.CancelFunc
+	// ctx is the context of the current tab
+	ctx     context.Context
+	tabs    map[target.ID]context.Context
+	frame   *cdp.Node
+	timeout time.Duration
+
+	mutex        sync.Mutex
+	requests     []NetworkRequest
+	requestIndex map[network.RequestID]int
+	// inflight and lastNetwork are used to wait for the network to be idle
+	inflight     map[network.RequestID]bool
+	lastNetwork  time.Time
+	console      []ConsoleMessage
+	dialog       *page.EventJavascriptDialogOpening
+	dialogOpened chan struct{}
+}
+
+// newCDPSession starts the browser with the web variables of the testsuite
+func newCDPSession(ctx context.Context) (*cdpSession, error) {
+	s := &cdpSession{
+		tabs:         map[target.ID]context.Context{},
+		timeout:      defaultCDPTimeout,
+		requestIndex: map[network.RequestID]int{},
+		inflight:     map[network.RequestID]bool{},
+		dialogOpened: make(chan struct{}, 1),
+	}
+	if timeout := venom.IntVarFromCtx(ctx, "web.timeout"); timeout > 0 {
+		s.timeout = time.Duration(timeout) * time.Second
+	}
+
+	opts := []chromedp.ExecAllocatorOption{}
+	for _, o := range chromedp.DefaultExecAllocatorOptions {
+		opts = append(opts, o)
+	}
+	if !venom.BoolVarFromCtx(ctx, "web.headless") {
+		opts = append(opts, chromedp.Flag("headless", false))
+	}
+	if binaryPath := venom.StringVarFromCtx(ctx, "web.binaryPath"); binaryPath != "" {
+		opts = append(opts, chromedp.ExecPath(binaryPath))
+	}
+	if proxy := venom.StringVarFromCtx(ctx, "web.proxy"); proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(proxy))
+	}
+	width := venom.IntVarFromCtx(ctx, "web.width")
+	height := venom.IntVarFromCtx(ctx, "web.height")
+	if width > 0 && height > 0 {
+		opts = append(opts, chromedp.WindowSize(width, height))
+	}
+	for _, arg := range venom.StringSliceVarFromCtx(ctx, "web.args") {
+		name, value, found := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if found {
+			opts = append(opts, chromedp.Flag(name, value))
+		} else {
+			opts = append(opts, chromedp.Flag(name, true))
+		}
+	}
+	if prefs := venom.StringMapInterfaceVarFromCtx(ctx, "web.prefs"); len(prefs) > 0 {
+		venom.Warn(ctx, "web - prefs are not supported by the cdp driver, they are ignored")
+	}
+
+	ctxOpts := []chromedp.ContextOption{}
+	if venom.BoolVarFromCtx(ctx, "web.debug") || venom.StringVarFromCtx(ctx, "web.logLevel") == common.DEBUG {
+		ctxOpts = append(ctxOpts, chromedp.WithDebugf(func(format string, args ...interface{}) {
+			venom.Debug(ctx, format, args...)
+		}))
+	}
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	tabCtx, tabCancel := chromedp.NewContext(allocCtx, ctxOpts...)
+	s.cancels = append(s.cancels, tabCancel, allocCancel)
+	s.listen(tabCtx)
+
+	// the first run starts the browser, its context must not be canceled before the end of the testcase
+	if err := chromedp.Run(tabCtx); err != nil {
+		s.stop()
+		return nil, err
+	}
+	s.ctx = tabCtx
+	s.tabs[chromedp.FromContext(tabCtx).Target.TargetID] = tabCtx
+	return s, nil
+}
+
+// stop closes the tabs and the browser
+func (s *cdpSession) stop() {
+	for i := len(s.cancels) - 1; i >= 0; i-- {
+		s.cancels[i]()
+	}
+}
+
+// name identifies the current tab in the name of the generated files
+func (s *cdpSession) name() string {
+	return slug.Make("cdp-" + string(chromedp.FromContext(s.ctx).Target.TargetID))
+}
+
+// listen captures the requests, the console messages and the popups of a tab
+func (s *cdpSession) listen(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			s.inflight[ev.RequestID] = true
+			s.lastNetwork = time.Now()
+			// a redirection is sent with the same request id
+			s.requestIndex[ev.RequestID] = len(s.requests)
+			s.requests = append(s.requests, NetworkRequest{
+				Method: ev.Request.Method,
+				URL:    ev.Request.URL,
+				Type:   string(ev.Type),
+			})
+		case *network.EventResponseReceived:
+			if i, ok := s.requestIndex[ev.RequestID]; ok {
+				s.requests[i].Status = ev.Response.Status
+				s.requests[i].MimeType = ev.Response.MimeType
+			}
+		case *network.EventLoadingFinished:
+			delete(s.inflight, ev.RequestID)
+			s.lastNetwork = time.Now()
+		case *network.EventLoadingFailed:
+			delete(s.inflight, ev.RequestID)
+			s.lastNetwork = time.Now()
+			if i, ok := s.requestIndex[ev.RequestID]; ok {
+				s.requests[i].ErrorText = ev.ErrorText
+			}
+		case *runtime.EventConsoleAPICalled:
+			texts := make([]string, len(ev.Args))
+			for i, arg := range ev.Args {
+				texts[i] = remoteObjectText(arg)
+			}
+			s.console = append(s.console, ConsoleMessage{Level: string(ev.Type), Text: strings.Join(texts, " ")})
+		case *runtime.EventExceptionThrown:
+			text := ev.ExceptionDetails.Text
+			if ev.ExceptionDetails.Exception != nil {
+				text = remoteObjectText(ev.ExceptionDetails.Exception)
+			}
+			s.console = append(s.console, ConsoleMessage{Level: "error", Text: text})
+		case *page.EventJavascriptDialogOpening:
+			s.dialog = ev
+			select {
+			case s.dialogOpened <- struct{}{}:
+			default:
+			}
+		case *page.EventJavascriptDialogClosed:
+			s.dialog = nil
+		}
+	})
+}
+
+// remoteObjectText returns the text of a javascript value, as displayed by the console
+func remoteObjectText(o *runtime.RemoteObject) string {
+	if len(o.Value) > 0 {
+		var str string
+		if err := json.Unmarshal(o.Value, &str); err == nil {
+			return str
+		}
+		return string(o.Value)
+	}
+	if o.UnserializableValue != "" {
+		return string(o.UnserializableValue)
+	}
+	if o.Description != "" {
+		return o.Description
+	}
+	return string(o.Type)
+}
+
+// captured returns the requests and the console messages captured since the last call
+func (s *cdpSession) captured() ([]NetworkRequest, []ConsoleMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	requests, console := s.requests, s.console
+	s.requests, s.console = nil, nil
+	s.requestIndex = map[network.RequestID]int{}
+	return requests, console
+}
+
+func (s *cdpSession) dialogOpen() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dialog != nil
+}
+
+// run runs the actions on the current tab. As an action opening a popup is blocked until the popup is closed,
+// it returns once the popup is opened.
+func (s *cdpSession) run(actions ...chromedp.Action) error {
+	select {
+	case <-s.dialogOpened:
+	default:
+	}
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+		defer cancel()
+		done <- chromedp.Run(ctx, actions...)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-s.dialogOpened:
+		return nil
+	}
+}
+
+// queryOptions returns the options to query the elements with the locator, in the selected frame
+func (s *cdpSession) queryOptions(locator string, opts ...chromedp.QueryOption) []chromedp.QueryOption {
+	if locator == common.XPATH_SELECTOR {
+		// the search is done in the whole page, frames included
+		return append(opts, chromedp.BySearch)
+	}
+	return append(opts, chromedp.ByQueryAll, chromedp.FromNode(s.frame))
+}
+
+func (s *cdpSession) find(ctx context.Context, findElement interface{}) ([]*cdp.Node, error) {
+	selector, locator, err := readFindElement(ctx, findElement)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*cdp.Node
+	if err := s.run(chromedp.Nodes(selector, &nodes, s.queryOptions(locator, chromedp.AtLeast(0))...)); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// findOne returns the first element of a selector, after waiting for it to be visible if syncTimeout is set
+func (s *cdpSession) findOne(ctx context.Context, findElement interface{}, syncTimeout int64) (*cdp.Node, error) {
+	selector, locator, err := readFindElement(ctx, findElement)
+	if err != nil {
+		return nil, err
+	}
+	if syncTimeout > 0 {
+		syncCtx, cancel := context.WithTimeout(s.ctx, time.Duration(syncTimeout)*time.Second)
+		defer cancel()
+		if err := chromedp.Run(syncCtx, chromedp.WaitVisible(selector, s.queryOptions(locator)...)); err != nil {
+			return nil, errors.Wrapf(err, "element %q is not visible after %d seconds", selector, syncTimeout)
+		}
+	}
+	nodes, err := s.find(ctx, findElement)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("element %q not found", selector)
+	}
+	return nodes[0], nil
+}
+
+// callFunctionOn calls a javascript function with the element as this
+func callFunctionOn(node *cdp.Node, function string, res interface{}, args ...interface{}) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		obj, err := dom.ResolveNode().WithNodeID(node.NodeID).Do(ctx)
+		if err != nil {
+			return err
+		}
+		defer runtime.ReleaseObject(obj.ObjectID).Do(ctx) // nolint
+		return chromedp.CallFunctionOn(function, res, func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+			return p.WithObjectID(obj.ObjectID)
+		}, args...).Do(ctx)
+	})
+}
+
+// textAndValue returns the text and the value of an element
+func (s *cdpSession) textAndValue(node *cdp.Node) (string, string, error) {
+	var res []string
+	err := s.run(callFunctionOn(node, `function() {
+		return [this.innerText || this.textContent || "", this.value === undefined || this.value === null ? "" : String(this.value)];
+	}`, &res))
+	if err != nil || len(res) != 2 {
+		return "", "", err
+	}
+	return res[0], res[1], nil
+}
+
+// nextWindow switches to the next tab of the browser
+func (s *cdpSession) nextWindow() error {
+	targets, err := chromedp.Targets(s.ctx)
+	if err != nil {
+		return err
+	}
+	pages := []*target.Info{}
+	for _, t := range targets {
+		if t.Type == "page" {
+			pages = append(pages, t)
+		}
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("no window found")
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].TargetID < pages[j].TargetID })
+	current := chromedp.FromContext(s.ctx).Target.TargetID
+	next := pages[0]
+	for i, p := range pages {
+		if p.TargetID == current {
+			next = pages[(i+1)%len(pages)]
+		}
+	}
+
+	tabCtx, ok := s.tabs[next.TargetID]
+	if !ok {
+		var cancel context.CancelFunc
+		tabCtx, cancel = chromedp.NewContext(s.ctx, chromedp.WithTargetID(next.TargetID))
+		s.cancels = append(s.cancels, cancel)
+		s.listen(tabCtx)
+		if err := chromedp.Run(tabCtx); err != nil {
+			return err
+		}
+		s.tabs[next.TargetID] = tabCtx
+	}
+	s.ctx = tabCtx
+	s.frame = nil
+	return chromedp.Run(s.ctx, page.BringToFront())
+}
+
+// runCDP runs the action of the step with the cdp driver
+func (e Executor) runCDP(ctx context.Context, s *cdpSession, start time.Time) (interface{}, error) {
+	result, err := e.runCDPAction(ctx, s)
+	if err != nil {
+		if errg := s.generateErrorHTMLFile(ctx); errg != nil {
+			venom.Warn(ctx, "Error while generating the HTML file: %v", errg)
+		}
+		return nil, err
+	}
+
+	// take a screenshot
+	if e.Screenshot != "" {
+		var buf []byte
+		if err := s.run(chromedp.FullScreenshot(&buf, 100)); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(e.Screenshot, buf, 0o644); err != nil {
+			return nil, err
+		}
+		if err := s.generateErrorHTMLFile(ctx); err != nil {
+			venom.Warn(ctx, "Error while generating the HTML file: %v", err)
+			return nil, err
+		}
+	}
+
+	// compare a screenshot with its baseline
+	if e.MatchScreenshot != nil {
+		comparison, err := e.MatchScreenshot.match(ctx, s.capture)
+		if err != nil {
+			if errg := s.generateErrorHTMLFile(ctx); errg != nil {
+				venom.Warn(ctx, "Error while generating the HTML file: %v", errg)
+			}
+			return nil, err
+		}
+		result.Screenshot = comparison
+	}
+
+	// Get page title and url, they can't be read while a popup is opened
+	if !s.dialogOpen() {
+		if err := s.run(chromedp.Title(&result.Title), chromedp.Location(&result.URL)); err != nil {
+			return nil, fmt.Errorf("cannot get title and url: %s", err)
+		}
+	}
+
+	result.Requests, result.Console = s.captured()
+	result.TimeSeconds = time.Since(start).Seconds()
+
+	return result, nil
+}
+
+func (e Executor) runCDPAction(ctx context.Context, s *cdpSession) (*Result, error) {
+	r := &Result{}
+
+	if s.dialogOpen() && !e.Action.ConfirmPopup && !e.Action.CancelPopup && e.Action.Wait == 0 {
+		return nil, fmt.Errorf("a popup is opened, it must be confirmed or canceled")
+	}
+
+	// Click
+	if e.Action.Click != nil {
+		node, err := s.findOne(ctx, e.Action.Click.Find, e.Action.Click.SyncTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.run(dom.ScrollIntoViewIfNeeded().WithNodeID(node.NodeID), chromedp.MouseClickNode(node)); err != nil {
+			return nil, err
+		}
+		if e.Action.Click.Wait != 0 {
+			time.Sleep(time.Duration(e.Action.Click.Wait) * time.Second)
+		}
+
+		// Fill
+	} else if e.Action.Fill != nil {
+		for _, f := range e.Action.Fill {
+			node, err := s.findOne(ctx, f.Find, f.SyncTimeout)
+			if err != nil {
+				return nil, err
+			}
+			text := f.Text
+			if f.Key != nil {
+				key, ok := cdpKeys[*f.Key]
+				if !ok {
+					return nil, fmt.Errorf("key %q is not supported by the cdp driver", *f.Key)
+				}
+				text += key
+			}
+			if err := s.run(chromedp.SendKeys([]cdp.NodeID{node.NodeID}, text, chromedp.ByNodeID)); err != nil {
+				return nil, err
+			}
+		}
+
+		// Find
+	} else if e.Action.Find != nil {
+		nodes, err := s.find(ctx, e.Action.Find)
+		if err != nil {
+			return nil, err
+		}
+		r.Find = len(nodes)
+		if len(nodes) > 0 {
+			if r.Text, r.Value, err = s.textAndValue(nodes[0]); err != nil {
+				return nil, err
+			}
+		}
+		if e.Action.Read != nil && e.Action.Read.Elements {
+			r.Elements = make([]Element, len(nodes))
+			for i, node := range nodes {
+				if err := s.run(callFunctionOn(node, "function() { return ("+describeElementScript+")(this); }", &r.Elements[i])); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// Navigate
+	} else if e.Action.Navigate != nil {
+		if e.Action.Navigate.Reset {
+			if err := s.run(network.ClearBrowserCookies()); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.run(chromedp.Navigate(e.Action.Navigate.URL)); err != nil {
+			return nil, err
+		}
+		s.frame = nil
+
+		// Wait
+	} else if e.Action.Wait != 0 {
+		time.Sleep(time.Duration(e.Action.Wait) * time.Second)
+
+		// Confirm or cancel popup
+	} else if e.Action.ConfirmPopup || e.Action.CancelPopup {
+		if !s.dialogOpen() {
+			return nil, fmt.Errorf("no popup opened")
+		}
+		if err := chromedp.Run(s.ctx, page.HandleJavaScriptDialog(e.Action.ConfirmPopup)); err != nil {
+			return nil, err
+		}
+
+		// Select
+	} else if e.Action.Select != nil {
+		node, err := s.findOne(ctx, e.Action.Select.Find, e.Action.Select.SyncTimeout)
+		if err != nil {
+			return nil, err
+		}
+		var selected bool
+		err = s.run(callFunctionOn(node, `function(text) {
+			for (const option of this.options || []) {
+				if (option.text === text) {
+					this.value = option.value;
+					this.dispatchEvent(new Event("input", { bubbles: true }));
+					this.dispatchEvent(new Event("change", { bubbles: true }));
+					return true;
+				}
+			}
+			return false;
+		}`, &selected, e.Action.Select.Text))
+		if err != nil {
+			return nil, err
+		}
+		if !selected {
+			return nil, fmt.Errorf("option %q not found", e.Action.Select.Text)
+		}
+		if e.Action.Select.Wait != 0 {
+			time.Sleep(time.Duration(e.Action.Select.Wait) * time.Second)
+		}
+
+		// Upload file
+	} else if e.Action.UploadFile != nil {
+		node, err := s.findOne(ctx, e.Action.UploadFile.Find, e.Action.UploadFile.SyncTimeout)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]string, len(e.Action.UploadFile.Files))
+		for i, f := range e.Action.UploadFile.Files {
+			if files[i], err = filepath.Abs(f); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.run(chromedp.SetUploadFiles([]cdp.NodeID{node.NodeID}, files, chromedp.ByNodeID)); err != nil {
+			return nil, err
+		}
+		if e.Action.UploadFile.Wait != 0 {
+			time.Sleep(time.Duration(e.Action.UploadFile.Wait) * time.Second)
+		}
+
+		// Select frame
+	} else if e.Action.SelectFrame != nil {
+		node, err := s.findOne(ctx, e.Action.SelectFrame.Find, e.Action.SelectFrame.SyncTimeout)
+		if err != nil {
+			return nil, err
+		}
+		s.frame = node
+
+		// Select root frame
+	} else if e.Action.SelectRootFrame {
+		s.frame = nil
+
+		// Next window
+	} else if e.Action.NextWindow {
+		if err := s.nextWindow(); err != nil {
+			return nil, err
+		}
+
+		// Back, Forward, Refresh
+	} else if e.Action.HistoryAction != "" {
+		var action chromedp.Action
+		switch strings.ToLower(e.Action.HistoryAction) {
+		case "back":
+			action = chromedp.NavigateBack()
+		case "refresh":
+			action = chromedp.Reload()
+		case "forward":
+			action = chromedp.NavigateForward()
+		default:
+			return nil, fmt.Errorf("history action '%s' is invalid", e.Action.HistoryAction)
+		}
+		if err := s.run(action); err != nil {
+			return nil, err
+		}
+		s.frame = nil
+
+		// Execute
+	} else if e.Action.Execute != nil {
+		// the arguments are given to the script as for the web driver
+		args := make([]string, len(e.Action.Execute.Args))
+		for i, a := range e.Action.Execute.Args {
+			args[i] = strconv.Quote(a)
+		}
+		script := fmt.Sprintf("(function() { %s }).apply(null, [%s])", e.Action.Execute.Command, strings.Join(args, ", "))
+		if err := s.run(chromedp.Evaluate(script, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		})); err != nil {
+			return nil, err
+		}
+	}
+
+	// Wait for conditions
+	if e.Action.WaitFor != nil {
+		if err := e.Action.WaitFor.wait(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read browser data, the console messages are always captured
+	if e.Action.Read != nil {
+		if err := s.read(e.Action.Read, r); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// read reads the cookies and the storages requested by the action
+func (s *cdpSession) read(read *Read, r *Result) error {
+	if read.Cookies {
+		err := s.run(chromedp.ActionFunc(func(ctx context.Context) error {
+			cookies, err := network.GetCookies().Do(ctx)
+			if err != nil {
+				return err
+			}
+			r.Cookies = make([]Cookie, len(cookies))
+			for i, c := range cookies {
+				r.Cookies[i] = Cookie{
+					Name:     c.Name,
+					Value:    c.Value,
+					Domain:   c.Domain,
+					Path:     c.Path,
+					Expires:  c.Expires,
+					HTTPOnly: c.HTTPOnly,
+					Secure:   c.Secure,
+					SameSite: string(c.SameSite),
+				}
+			}
+			return nil
+		}))
+		if err != nil {
+			return errors.Wrapf(err, "unable to read cookies")
+		}
+	}
+	if read.LocalStorage {
+		if err := s.run(chromedp.Evaluate(`(function() {`+storageScript+`}).apply(null, ["localStorage"])`, &r.LocalStorage)); err != nil {
+			return errors.Wrapf(err, "unable to read local storage")
+		}
+	}
+	if read.SessionStorage {
+		if err := s.run(chromedp.Evaluate(`(function() {`+storageScript+`}).apply(null, ["sessionStorage"])`, &r.SessionStorage)); err != nil {
+			return errors.Wrapf(err, "unable to read session storage")
+		}
+	}
+	return nil
+}
+
+func (s *cdpSession) element(ctx context.Context, findElement interface{}) (*elementState, error) {
+	nodes, err := s.find(ctx, findElement)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	var state elementState
+	err = s.run(callFunctionOn(nodes[0], `function() {
+		const style = window.getComputedStyle(this);
+		const rect = this.getBoundingClientRect();
+		return {
+			visible: rect.width > 0 && rect.height > 0 && style.display !== "none" && style.visibility !== "hidden",
+			enabled: !this.disabled,
+			text: this.innerText || this.textContent || "",
+		};
+	}`, &state))
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (s *cdpSession) url() (string, error) {
+	var url string
+	err := s.run(chromedp.Location(&url))
+	return url, err
+}
+
+func (s *cdpSession) script(expression string) (bool, error) {
+	var res bool
+	err := s.run(chromedp.Evaluate(fmt.Sprintf("!!(%s)", expression), &res))
+	return res, err
+}
+
+// networkIdle checks no request is pending and no response has been received for the idle duration
+func (s *cdpSession) networkIdle(idle time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.inflight) == 0 && time.Since(s.lastNetwork) >= idle, nil
+}
+
+// capture takes a screenshot of the page, or of the element if findElement is set
+func (s *cdpSession) capture(ctx context.Context, findElement interface{}, filename string) error {
+	if findElement == nil {
+		return s.screenshot(filename)
+	}
+	node, err := s.findOne(ctx, findElement, 0)
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	if err := s.run(chromedp.Screenshot([]cdp.NodeID{node.NodeID}, &buf, chromedp.ByNodeID)); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf, 0o644)
+}
+
+func (s *cdpSession) screenshot(filename string) error {
+	var buf []byte
+	if err := s.run(chromedp.FullScreenshot(&buf, 100)); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf, 0o644)
+}
+
+// generateErrorHTMLFile generates an HTML file of the current page to identify clearly the error
+func (s *cdpSession) generateErrorHTMLFile(ctx context.Context) error {
+	if s.dialogOpen() {
+		return nil
+	}
+	var html string
+	if err := s.run(chromedp.OuterHTML("html", &html, chromedp.ByQuery)); err != nil {
+		return err
+	}
+	filename := s.name() + ".dump.html"
+	venom.Info(ctx, "Content of the HTML page is saved in %s", filename)
+	return os.WriteFile(filename, []byte(html), 0o644)
+}
@@ -1,5 +1,7 @@
 package web
 
+import "github.com/chromedp/chromedp/kb"
+
 // Keys map returning key code by its name
 var Keys = map[string]string{
 	"NULL":            "\uE000",
@@ -67,3 +69,70 @@ var Keys = map[string]string{
 	"COMMAND":         "\uE03D",
 	"ZENKAKU_HANKAKU": "\uE040",
 }
+
+// cdpKeys map returning the key sent by the cdp driver by its name, the keys without equivalent are not supported
+var cdpKeys = map[string]string{
+	"CANCEL":          kb.Cancel,
+	"HELP":            kb.Help,
+	"BACK_SPACE":      kb.Backspace,
+	"TAB":             kb.Tab,
+	"CLEAR":           kb.Clear,
+	"RETURN":          kb.Enter,
+	"ENTER":           kb.Enter,
+	"SHIFT":           kb.Shift,
+	"LEFT_SHIFT":      kb.Shift,
+	"CONTROL":         kb.Control,
+	"LEFT_CONTROL":    kb.Control,
+	"ALT":             kb.Alt,
+	"LEFT_ALT":        kb.Alt,
+	"PAUSE":           kb.Pause,
+	"ESCAPE":          kb.Escape,
+	"SPACE":           " ",
+	"PAGE_UP":         kb.PageUp,
+	"PAGE_DOWN":       kb.PageDown,
+	"END":             kb.End,
+	"HOME":            kb.Home,
+	"LEFT":            kb.ArrowLeft,
+	"ARROW_LEFT":      kb.ArrowLeft,
+	"UP":              kb.ArrowUp,
+	"ARROW_UP":        kb.ArrowUp,
+	"RIGHT":           kb.ArrowRight,
+	"ARROW_RIGHT":     kb.ArrowRight,
+	"DOWN":            kb.ArrowDown,
+	"ARROW_DOWN":      kb.ArrowDown,
+	"INSERT":          kb.Insert,
+	"DELETE":          kb.Delete,
+	"SEMICOLON":       ";",
+	"EQUALS":          "=",
+	"NUMPAD0":         "0",
+	"NUMPAD1":         "1",
+	"NUMPAD2":         "2",
+	"NUMPAD3":         "3",
+	"NUMPAD4":         "4",
+	"NUMPAD5":         "5",
+	"NUMPAD6":         "6",
+	"NUMPAD7":         "7",
+	"NUMPAD8":         "8",
+	"NUMPAD9":         "9",
+	"MULTIPLY":        "*",
+	"ADD":             "+",
+	"SEPARATOR":       ",",
+	"SUBTRACT":        "-",
+	"DECIMAL":         ".",
+	"DIVIDE":          "/",
+	"F1":              kb.F1,
+	"F2":              kb.F2,
+	"F3":              kb.F3,
+	"F4":              kb.F4,
+	"F5":              kb.F5,
+	"F6":              kb.F6,
+	"F7":              kb.F7,
+	"F8":              kb.F8,
+	"F9":              kb.F9,
+	"F10":             kb.F10,
+	"F11":             kb.F11,
+	"F12":             kb.F12,
+	"META":            kb.Meta,
+	"COMMAND":         kb.Meta,
+	"ZENKAKU_HANKAKU": kb.ZenkakuHankaku,
+}
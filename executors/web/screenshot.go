@@ -0,0 +1,208 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosimple/slug"
+
+	"github.com/ovh/venom"
+)
+
+// MatchScreenshot represents the comparison of a screenshot of the page, or of an element, with a baseline
+type MatchScreenshot struct {
+	Baseline       string         `yaml:"baseline,omitempty"`
+	Find           interface{}    `yaml:"find,omitempty"`
+	Threshold      float64        `yaml:"threshold,omitempty"`
+	PixelThreshold int64          `yaml:"pixelThreshold,omitempty"`
+	Tolerance      uint8          `yaml:"tolerance,omitempty"`
+	Ignore         []IgnoreRegion `yaml:"ignore,omitempty"`
+}
+
+// IgnoreRegion represents a region of the screenshot not compared, in pixels
+type IgnoreRegion struct {
+	X      int `yaml:"x"`
+	Y      int `yaml:"y"`
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+}
+
+// ScreenshotComparison represents the result of the comparison of a screenshot with its baseline
+type ScreenshotComparison struct {
+	Match       bool    `json:"match" yaml:"match"`
+	Updated     bool    `json:"updated,omitempty" yaml:"updated,omitempty"`
+	Baseline    string  `json:"baseline" yaml:"baseline"`
+	DiffPixels  int64   `json:"diffpixels" yaml:"diffpixels"`
+	DiffPercent float64 `json:"diffpercent" yaml:"diffpercent"`
+	Diff        string  `json:"diff,omitempty" yaml:"diff,omitempty"`
+	Actual      string  `json:"actual,omitempty" yaml:"actual,omitempty"`
+	Message     string  `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// screenshotCapture takes a screenshot of the page, or of the element if findElement is set, in a PNG file
+type screenshotCapture func(ctx context.Context, findElement interface{}, filename string) error
+
+// match takes the screenshot and compares it with the baseline. With the update-screenshots option, the baseline is rewritten.
+// On mismatch, the screenshot and a diff image are written in the output directory.
+func (m *MatchScreenshot) match(ctx context.Context, capture screenshotCapture) (*ScreenshotComparison, error) {
+	if m.Baseline == "" {
+		return nil, fmt.Errorf("matchScreenshot: baseline is mandatory")
+	}
+	baseline := m.Baseline
+	if !filepath.IsAbs(baseline) {
+		baseline = filepath.Join(venom.StringVarFromCtx(ctx, "venom.testsuite.workdir"), baseline)
+	}
+	res := &ScreenshotComparison{Baseline: baseline}
+
+	outputDir := venom.StringVarFromCtx(ctx, "venom.outputdir")
+	name := slug.Make(strings.TrimSuffix(filepath.Base(m.Baseline), filepath.Ext(m.Baseline)))
+	actualFile, err := os.CreateTemp("", name+"-*.png")
+	if err != nil {
+		return nil, err
+	}
+	actualFile.Close()                 // nolint
+	defer os.Remove(actualFile.Name()) // nolint
+	if err := capture(ctx, m.Find, actualFile.Name()); err != nil {
+		return nil, fmt.Errorf("matchScreenshot: unable to take the screenshot: %s", err)
+	}
+	actual, err := readPNG(actualFile.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if venom.BoolVarFromCtx(ctx, "venom.updatescreenshots") {
+		if err := os.MkdirAll(filepath.Dir(baseline), 0o755); err != nil {
+			return nil, err
+		}
+		if err := writePNG(baseline, actual); err != nil {
+			return nil, err
+		}
+		venom.Info(ctx, "Baseline %s updated", baseline)
+		res.Match, res.Updated = true, true
+		return res, nil
+	}
+
+	expected, err := readPNG(baseline)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("matchScreenshot: baseline %s not found, run venom with --update-screenshots to create it", baseline)
+	} else if err != nil {
+		return nil, err
+	}
+
+	diff, diffPixels, comparedPixels := compareImages(expected, actual, m.Tolerance, m.Ignore)
+	res.DiffPixels = diffPixels
+	if comparedPixels > 0 {
+		res.DiffPercent = float64(diffPixels) * 100 / float64(comparedPixels)
+	}
+	switch {
+	case diff == nil:
+		res.Message = fmt.Sprintf("screenshot size %v differs from baseline %s size %v", actual.Bounds().Size(), baseline, expected.Bounds().Size())
+	case m.Threshold > 0 || m.PixelThreshold > 0:
+		res.Match = (m.Threshold <= 0 || res.DiffPercent <= m.Threshold) && (m.PixelThreshold <= 0 || diffPixels <= m.PixelThreshold)
+	default:
+		res.Match = diffPixels == 0
+	}
+	if res.Match {
+		return res, nil
+	}
+
+	res.Actual = filepath.Join(outputDir, name+".actual.png")
+	if err := writePNG(res.Actual, actual); err != nil {
+		return nil, err
+	}
+	if diff != nil {
+		res.Diff = filepath.Join(outputDir, name+".diff.png")
+		if err := writePNG(res.Diff, diff); err != nil {
+			return nil, err
+		}
+		res.Message = fmt.Sprintf("screenshot differs from baseline %s: %d pixels (%.2f%%), diff image written in %s", baseline, diffPixels, res.DiffPercent, res.Diff)
+	}
+	venom.Info(ctx, "%s", res.Message)
+	return res, nil
+}
+
+// compareImages returns an image highlighting in red the pixels which differ, the number of pixels which differ and the number of pixels compared.
+// The image is nil if the sizes differ.
+func compareImages(expected, actual image.Image, tolerance uint8, ignore []IgnoreRegion) (*image.RGBA, int64, int64) {
+	bounds := actual.Bounds()
+	if bounds.Size() != expected.Bounds().Size() {
+		return nil, 0, 0
+	}
+	ignored := make([]image.Rectangle, len(ignore))
+	for i, r := range ignore {
+		ignored[i] = image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+	}
+
+	diff := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	var diffPixels, comparedPixels int64
+	offset := expected.Bounds().Min.Sub(bounds.Min)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			p := image.Pt(x, y)
+			a := color.RGBAModel.Convert(actual.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.RGBA)
+			// the unchanged pixels are lightened to make the differences visible
+			faded := color.RGBA{R: 255 - (255-a.R)/4, G: 255 - (255-a.G)/4, B: 255 - (255-a.B)/4, A: 255}
+			if inRegions(p, ignored) {
+				diff.Set(x, y, faded)
+				continue
+			}
+			comparedPixels++
+			e := color.RGBAModel.Convert(expected.At(bounds.Min.X+x+offset.X, bounds.Min.Y+y+offset.Y)).(color.RGBA)
+			if channelDiff(a.R, e.R) > tolerance || channelDiff(a.G, e.G) > tolerance ||
+				channelDiff(a.B, e.B) > tolerance || channelDiff(a.A, e.A) > tolerance {
+				diffPixels++
+				diff.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				diff.Set(x, y, faded)
+			}
+		}
+	}
+	return diff, diffPixels, comparedPixels
+}
+
+func inRegions(p image.Point, regions []image.Rectangle) bool {
+	for _, r := range regions {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func channelDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func readPNG(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode PNG file %s: %s", filename, err)
+	}
+	return img, nil
+}
+
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close() // nolint
+		return err
+	}
+	return f.Close()
+}
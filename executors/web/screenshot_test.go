@@ -0,0 +1,246 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+// newImage returns a white image of the size, with the pixels set to their color
+func newImage(width, height int, pixels map[image.Point]color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+	for p, c := range pixels {
+		img.Set(p.X, p.Y, c)
+	}
+	return img
+}
+
+func TestCompareImages(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	grey := color.RGBA{R: 250, G: 250, B: 250, A: 255}
+	tests := []struct {
+		name           string
+		actual         image.Image
+		tolerance      uint8
+		ignore         []IgnoreRegion
+		wantDiff       bool
+		diffPixels     int64
+		comparedPixels int64
+	}{
+		{
+			name:           "same images",
+			actual:         newImage(4, 3, nil),
+			wantDiff:       true,
+			comparedPixels: 12,
+		},
+		{
+			name:           "different pixels",
+			actual:         newImage(4, 3, map[image.Point]color.RGBA{{X: 0, Y: 0}: red, {X: 3, Y: 2}: grey}),
+			wantDiff:       true,
+			diffPixels:     2,
+			comparedPixels: 12,
+		},
+		{
+			name:           "pixel within tolerance",
+			actual:         newImage(4, 3, map[image.Point]color.RGBA{{X: 0, Y: 0}: red, {X: 3, Y: 2}: grey}),
+			tolerance:      5,
+			wantDiff:       true,
+			diffPixels:     1,
+			comparedPixels: 12,
+		},
+		{
+			name:           "ignored region",
+			actual:         newImage(4, 3, map[image.Point]color.RGBA{{X: 0, Y: 0}: red, {X: 3, Y: 2}: grey}),
+			ignore:         []IgnoreRegion{{X: 0, Y: 0, Width: 2, Height: 2}},
+			wantDiff:       true,
+			diffPixels:     1,
+			comparedPixels: 8,
+		},
+		{
+			name:   "different sizes",
+			actual: newImage(3, 4, nil),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, diffPixels, comparedPixels := compareImages(newImage(4, 3, nil), tt.actual, tt.tolerance, tt.ignore)
+			assert.Equal(t, tt.wantDiff, diff != nil)
+			assert.Equal(t, tt.diffPixels, diffPixels)
+			assert.Equal(t, tt.comparedPixels, comparedPixels)
+		})
+	}
+}
+
+func TestCompareImages_DiffImage(t *testing.T) {
+	expected := newImage(2, 1, nil)
+	// the images can have different origins, such as a sub image of an element
+	actual := newImage(3, 1, map[image.Point]color.RGBA{{X: 2, Y: 0}: {A: 255}}).SubImage(image.Rect(1, 0, 3, 1))
+
+	diff, diffPixels, _ := compareImages(expected, actual, 0, nil)
+	require.NotNil(t, diff)
+	assert.Equal(t, int64(1), diffPixels)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), diff.Bounds())
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, diff.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, diff.RGBAAt(1, 0))
+}
+
+func TestMatchScreenshot_match(t *testing.T) {
+	venom.InitTestLogger(t)
+	red := color.RGBA{R: 255, A: 255}
+	// 1 pixel of 10 differs from the baseline
+	changed := newImage(5, 2, map[image.Point]color.RGBA{{X: 4, Y: 1}: red})
+	tests := []struct {
+		name            string
+		screenshot      image.Image
+		match           MatchScreenshot
+		update          bool
+		wantMatch       bool
+		wantDiffPixels  int64
+		wantDiffPercent float64
+		wantFiles       bool
+		wantMessage     string
+	}{
+		{
+			name:       "same screenshot",
+			screenshot: newImage(5, 2, nil),
+			wantMatch:  true,
+		},
+		{
+			name:            "different screenshot",
+			screenshot:      changed,
+			wantDiffPixels:  1,
+			wantDiffPercent: 10,
+			wantFiles:       true,
+			wantMessage:     "screenshot differs from baseline",
+		},
+		{
+			name:            "below threshold",
+			screenshot:      changed,
+			match:           MatchScreenshot{Threshold: 10},
+			wantMatch:       true,
+			wantDiffPixels:  1,
+			wantDiffPercent: 10,
+		},
+		{
+			name:            "above threshold",
+			screenshot:      changed,
+			match:           MatchScreenshot{Threshold: 5},
+			wantDiffPixels:  1,
+			wantDiffPercent: 10,
+			wantFiles:       true,
+			wantMessage:     "screenshot differs from baseline",
+		},
+		{
+			name:            "below pixel threshold",
+			screenshot:      changed,
+			match:           MatchScreenshot{PixelThreshold: 1},
+			wantMatch:       true,
+			wantDiffPixels:  1,
+			wantDiffPercent: 10,
+		},
+		{
+			name:            "below threshold but above pixel threshold",
+			screenshot:      newImage(5, 2, map[image.Point]color.RGBA{{X: 0, Y: 0}: red, {X: 4, Y: 1}: red}),
+			match:           MatchScreenshot{Threshold: 50, PixelThreshold: 1},
+			wantDiffPixels:  2,
+			wantDiffPercent: 20,
+			wantFiles:       true,
+			wantMessage:     "screenshot differs from baseline",
+		},
+		{
+			name:       "ignored region",
+			screenshot: changed,
+			match:      MatchScreenshot{Ignore: []IgnoreRegion{{X: 4, Y: 0, Width: 1, Height: 2}}},
+			wantMatch:  true,
+		},
+		{
+			name:        "different size",
+			screenshot:  newImage(2, 5, nil),
+			wantMessage: "screenshot size (2,5) differs from baseline",
+		},
+		{
+			name:       "update mode",
+			screenshot: changed,
+			update:     true,
+			wantMatch:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workdir, outputDir := t.TempDir(), t.TempDir()
+			require.NoError(t, writePNG(filepath.Join(workdir, "home.png"), newImage(5, 2, nil)))
+			ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.testsuite.workdir"), workdir)
+			ctx = context.WithValue(ctx, venom.ContextKey("var.venom.outputdir"), outputDir)
+			ctx = context.WithValue(ctx, venom.ContextKey("var.venom.updatescreenshots"), tt.update)
+
+			m := tt.match
+			m.Baseline = "home.png"
+			res, err := m.match(ctx, func(ctx context.Context, findElement interface{}, filename string) error {
+				return writePNG(filename, tt.screenshot)
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMatch, res.Match)
+			assert.Equal(t, tt.update, res.Updated)
+			assert.Equal(t, filepath.Join(workdir, "home.png"), res.Baseline)
+			assert.Equal(t, tt.wantDiffPixels, res.DiffPixels)
+			assert.InDelta(t, tt.wantDiffPercent, res.DiffPercent, 0.001)
+			assert.Contains(t, res.Message, tt.wantMessage)
+			if tt.wantFiles {
+				assert.Equal(t, filepath.Join(outputDir, "home.actual.png"), res.Actual)
+				assert.Equal(t, filepath.Join(outputDir, "home.diff.png"), res.Diff)
+				assert.FileExists(t, res.Actual)
+				assert.FileExists(t, res.Diff)
+			} else {
+				assert.Empty(t, res.Diff)
+			}
+
+			baseline, err := readPNG(res.Baseline)
+			require.NoError(t, err)
+			if tt.update {
+				_, diffPixels, _ := compareImages(tt.screenshot, baseline, 0, nil)
+				assert.Zero(t, diffPixels, "the baseline is replaced by the screenshot")
+			} else {
+				_, diffPixels, _ := compareImages(newImage(5, 2, nil), baseline, 0, nil)
+				assert.Zero(t, diffPixels, "the baseline is unchanged")
+			}
+		})
+	}
+}
+
+func TestMatchScreenshot_match_Errors(t *testing.T) {
+	venom.InitTestLogger(t)
+	workdir := t.TempDir()
+	ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.testsuite.workdir"), workdir)
+	capture := func(ctx context.Context, findElement interface{}, filename string) error {
+		return writePNG(filename, newImage(1, 1, nil))
+	}
+
+	_, err := (&MatchScreenshot{}).match(ctx, capture)
+	assert.EqualError(t, err, "matchScreenshot: baseline is mandatory")
+
+	_, err = (&MatchScreenshot{Baseline: "missing.png"}).match(ctx, capture)
+	assert.EqualError(t, err, "matchScreenshot: baseline "+filepath.Join(workdir, "missing.png")+" not found, run venom with --update-screenshots to create it")
+
+	_, err = (&MatchScreenshot{Baseline: "home.png"}).match(ctx, func(ctx context.Context, findElement interface{}, filename string) error {
+		return errors.New("no such element")
+	})
+	assert.EqualError(t, err, "matchScreenshot: unable to take the screenshot: no such element")
+
+	require.NoError(t, os.WriteFile(filepath.Join(workdir, "invalid.png"), []byte("not a png"), 0o644))
+	_, err = (&MatchScreenshot{Baseline: "invalid.png"}).match(ctx, capture)
+	assert.ErrorContains(t, err, "unable to decode PNG file")
+}
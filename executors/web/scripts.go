@@ -0,0 +1,53 @@
+package web
+
+// Javascript functions shared by the drivers to read the state of the page
+
+// describeElementScript returns the Element of a DOM element
+const describeElementScript = `function(elt) {
+	const style = window.getComputedStyle(elt);
+	const rect = elt.getBoundingClientRect();
+	const attributes = {};
+	for (const attr of elt.attributes) {
+		attributes[attr.name] = attr.value;
+	}
+	return {
+		tag: elt.tagName.toLowerCase(),
+		text: elt.innerText || elt.textContent || "",
+		value: elt.value === undefined || elt.value === null ? "" : String(elt.value),
+		attributes: attributes,
+		visible: rect.width > 0 && rect.height > 0 && style.display !== "none" && style.visibility !== "hidden",
+		rect: { x: rect.x, y: rect.y, width: rect.width, height: rect.height },
+	};
+}`
+
+// storageScript returns the items of the storage named by arguments[0] (localStorage or sessionStorage)
+const storageScript = `
+	const storage = window[arguments[0]];
+	const items = {};
+	for (let i = 0; i < storage.length; i++) {
+		const key = storage.key(i);
+		items[key] = storage.getItem(key);
+	}
+	return items;`
+
+// documentCookiesScript returns the cookies readable by the page, httpOnly cookies excluded
+const documentCookiesScript = `
+	return document.cookie.split(";").filter(c => c.trim() !== "").map(c => {
+		const i = c.indexOf("=");
+		return i < 0 ? { name: "", value: c.trim() } : { name: c.slice(0, i).trim(), value: c.slice(i + 1).trim() };
+	});`
+
+// consoleScript returns the console messages captured since the previous call, and installs the capture in the page
+const consoleScript = `
+	if (!window.__venomConsole) {
+		window.__venomConsole = [];
+		for (const level of ["log", "debug", "info", "warn", "error"]) {
+			const original = console[level];
+			console[level] = function(...args) {
+				window.__venomConsole.push({ level: level, text: args.map(a => typeof a === "string" ? a : JSON.stringify(a)).join(" ") });
+				return original.apply(console, args);
+			};
+		}
+		window.addEventListener("error", e => window.__venomConsole.push({ level: "error", text: e.message }));
+	}
+	return window.__venomConsole.splice(0);`
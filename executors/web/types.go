@@ -16,6 +16,8 @@ type Action struct {
 	NextWindow      bool         `yaml:"nextWindow,omitempty"`
 	HistoryAction   string       `yaml:"historyAction,omitempy"`
 	Execute         *Execute     `yaml:"execute,omitempy"`
+	WaitFor         *WaitFor     `yaml:"wait_for,omitempty" mapstructure:"wait_for"`
+	Read            *Read        `yaml:"read,omitempty"`
 }
 
 // Fill represents informations needed to fill input/textarea
@@ -66,3 +68,59 @@ type Execute struct {
 	Command string   `yaml:"command,omitempty"`
 	Args    []string `yaml:"args,omitempty"`
 }
+
+// NetworkRequest represents a request sent by the browser, captured by the cdp driver
+type NetworkRequest struct {
+	Method    string `json:"method" yaml:"method"`
+	URL       string `json:"url" yaml:"url"`
+	Type      string `json:"type,omitempty" yaml:"type,omitempty"`
+	Status    int64  `json:"status,omitempty" yaml:"status,omitempty"`
+	MimeType  string `json:"mimetype,omitempty" yaml:"mimetype,omitempty"`
+	ErrorText string `json:"errortext,omitempty" yaml:"errortext,omitempty"`
+}
+
+// ConsoleMessage represents a message of the browser console, captured by the cdp driver
+type ConsoleMessage struct {
+	Level string `json:"level" yaml:"level"`
+	Text  string `json:"text" yaml:"text"`
+}
+
+// Read represents the browser data to read into the result after an action
+type Read struct {
+	Cookies        bool `yaml:"cookies,omitempty"`
+	LocalStorage   bool `yaml:"localStorage,omitempty"`
+	SessionStorage bool `yaml:"sessionStorage,omitempty"`
+	Console        bool `yaml:"console,omitempty"`
+	// Elements reads the details of the elements found by the find action
+	Elements bool `yaml:"elements,omitempty"`
+}
+
+// Element represents an element found by the find action
+type Element struct {
+	Tag        string            `json:"tag" yaml:"tag"`
+	Text       string            `json:"text,omitempty" yaml:"text,omitempty"`
+	Value      string            `json:"value,omitempty" yaml:"value,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	Visible    bool              `json:"visible" yaml:"visible"`
+	Rect       ElementRect       `json:"rect" yaml:"rect"`
+}
+
+// ElementRect represents the bounding box of an element, in pixels
+type ElementRect struct {
+	X      float64 `json:"x" yaml:"x"`
+	Y      float64 `json:"y" yaml:"y"`
+	Width  float64 `json:"width" yaml:"width"`
+	Height float64 `json:"height" yaml:"height"`
+}
+
+// Cookie represents a cookie of the current page
+type Cookie struct {
+	Name     string  `json:"name" yaml:"name"`
+	Value    string  `json:"value" yaml:"value"`
+	Domain   string  `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Path     string  `json:"path,omitempty" yaml:"path,omitempty"`
+	Expires  float64 `json:"expires,omitempty" yaml:"expires,omitempty"`
+	HTTPOnly bool    `json:"httponly,omitempty" yaml:"httponly,omitempty"`
+	Secure   bool    `json:"secure,omitempty" yaml:"secure,omitempty"`
+	SameSite string  `json:"samesite,omitempty" yaml:"samesite,omitempty"`
+}
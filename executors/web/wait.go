@@ -0,0 +1,198 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ovh/venom"
+)
+
+// Default values of the wait conditions
+const (
+	defaultWaitTimeout  = 30000 // milliseconds
+	defaultWaitInterval = 500   // milliseconds
+)
+
+// WaitFor represents the conditions to wait for after an action, they must all be met before the timeout
+type WaitFor struct {
+	Visible     interface{}  `yaml:"visible,omitempty"`
+	Hidden      interface{}  `yaml:"hidden,omitempty"`
+	Enabled     interface{}  `yaml:"enabled,omitempty"`
+	Text        *WaitForText `yaml:"text,omitempty"`
+	URL         string       `yaml:"url,omitempty"`
+	NetworkIdle int64        `yaml:"network_idle,omitempty" mapstructure:"network_idle"`
+	Script      string       `yaml:"script,omitempty"`
+	Interval    int64        `yaml:"interval,omitempty"`
+	Timeout     int64        `yaml:"timeout,omitempty"`
+}
+
+// WaitForText represents an element which must contain a text
+type WaitForText struct {
+	Find interface{} `yaml:"find,omitempty"`
+	Text string      `yaml:"text,omitempty"`
+}
+
+// elementState is the state of the first element found by a selector
+type elementState struct {
+	Visible bool   `json:"visible"`
+	Enabled bool   `json:"enabled"`
+	Text    string `json:"text"`
+}
+
+// waitBrowser reads the state of the page for the wait conditions, it is implemented by each driver
+type waitBrowser interface {
+	name() string
+	// element returns nil if the element is not found
+	element(ctx context.Context, findElement interface{}) (*elementState, error)
+	url() (string, error)
+	script(expression string) (bool, error)
+	networkIdle(idle time.Duration) (bool, error)
+	screenshot(filename string) error
+}
+
+// waitCondition returns true once the condition is met
+type waitCondition struct {
+	description string
+	check       func() (bool, error)
+}
+
+// conditions returns the conditions to check, the find elements and the URL pattern are validated first
+func (w *WaitFor) conditions(ctx context.Context, b waitBrowser) ([]waitCondition, error) {
+	conditions := []waitCondition{}
+	elementCondition := func(state string, findElement interface{}, met func(*elementState) bool) error {
+		selector, _, err := readFindElement(ctx, findElement)
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, waitCondition{
+			description: fmt.Sprintf("element %q %s", selector, state),
+			check: func() (bool, error) {
+				elt, err := b.element(ctx, findElement)
+				if err != nil {
+					return false, err
+				}
+				return met(elt), nil
+			},
+		})
+		return nil
+	}
+
+	if w.Visible != nil {
+		if err := elementCondition("visible", w.Visible, func(elt *elementState) bool {
+			return elt != nil && elt.Visible
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if w.Hidden != nil {
+		if err := elementCondition("hidden", w.Hidden, func(elt *elementState) bool {
+			return elt == nil || !elt.Visible
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if w.Enabled != nil {
+		if err := elementCondition("enabled", w.Enabled, func(elt *elementState) bool {
+			return elt != nil && elt.Enabled
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if w.Text != nil {
+		if err := elementCondition(fmt.Sprintf("contains text %q", w.Text.Text), w.Text.Find, func(elt *elementState) bool {
+			return elt != nil && strings.Contains(elt.Text, w.Text.Text)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if w.URL != "" {
+		pattern, err := regexp.Compile(w.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url pattern %q: %s", w.URL, err)
+		}
+		conditions = append(conditions, waitCondition{
+			description: fmt.Sprintf("url matches %q", w.URL),
+			check: func() (bool, error) {
+				url, err := b.url()
+				if err != nil {
+					return false, err
+				}
+				return pattern.MatchString(url), nil
+			},
+		})
+	}
+	if w.NetworkIdle > 0 {
+		idle := time.Duration(w.NetworkIdle) * time.Millisecond
+		conditions = append(conditions, waitCondition{
+			description: fmt.Sprintf("network idle for %s", idle),
+			check:       func() (bool, error) { return b.networkIdle(idle) },
+		})
+	}
+	if w.Script != "" {
+		conditions = append(conditions, waitCondition{
+			description: fmt.Sprintf("script %q is true", w.Script),
+			check:       func() (bool, error) { return b.script(w.Script) },
+		})
+	}
+
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("wait_for must define at least one condition")
+	}
+	return conditions, nil
+}
+
+// wait polls the conditions until they are all met or the step is canceled. On timeout, a screenshot is taken and an error describes the condition not met.
+func (w *WaitFor) wait(ctx context.Context, b waitBrowser) error {
+	conditions, err := w.conditions(ctx, b)
+	if err != nil {
+		return err
+	}
+	timeout := time.Duration(defaultWaitTimeout) * time.Millisecond
+	if w.Timeout > 0 {
+		timeout = time.Duration(w.Timeout) * time.Millisecond
+	}
+	interval := time.Duration(defaultWaitInterval) * time.Millisecond
+	if w.Interval > 0 {
+		interval = time.Duration(w.Interval) * time.Millisecond
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		// the conditions are checked in order, the first one not met is reported on timeout
+		var pending *waitCondition
+		var lastErr error
+		for i := range conditions {
+			met, err := conditions[i].check()
+			if err != nil || !met {
+				pending, lastErr = &conditions[i], err
+				break
+			}
+		}
+		if pending == nil {
+			return nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			filename := filepath.Join(venom.StringVarFromCtx(ctx, "venom.outputdir"), b.name()+".timeout.png")
+			if err := b.screenshot(filename); err != nil {
+				venom.Warn(ctx, "Error while taking the screenshot: %v", err)
+			} else {
+				venom.Info(ctx, "Screenshot of the page is saved in %s", filename)
+			}
+			if lastErr != nil {
+				return fmt.Errorf("wait_for: %s not met after %s: %s", pending.description, timeout, lastErr)
+			}
+			return fmt.Errorf("wait_for: %s not met after %s", pending.description, timeout)
+		}
+		venom.Debug(ctx, "wait_for: %s not met, next check in %s", pending.description, interval)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait_for: %s not met: %w", pending.description, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
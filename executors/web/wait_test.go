@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/venom"
+)
+
+// fakeBrowser is on the url "/done" after a number of checks
+type fakeBrowser struct {
+	checks      atomic.Int32
+	readyAt     int32
+	screenshots []string
+}
+
+func (b *fakeBrowser) name() string { return "fake" }
+
+func (b *fakeBrowser) element(ctx context.Context, findElement interface{}) (*elementState, error) {
+	return nil, nil
+}
+
+func (b *fakeBrowser) url() (string, error) {
+	if b.checks.Add(1) >= b.readyAt {
+		return "http://localhost/done", nil
+	}
+	return "http://localhost/loading", nil
+}
+
+func (b *fakeBrowser) script(expression string) (bool, error) { return false, nil }
+
+func (b *fakeBrowser) networkIdle(idle time.Duration) (bool, error) { return false, nil }
+
+func (b *fakeBrowser) screenshot(filename string) error {
+	b.screenshots = append(b.screenshots, filename)
+	return nil
+}
+
+func TestWaitFor_wait(t *testing.T) {
+	venom.InitTestLogger(t)
+	w := &WaitFor{URL: "/done$", Interval: 10, Timeout: 1000}
+
+	b := &fakeBrowser{readyAt: 3}
+	assert.NoError(t, w.wait(context.Background(), b))
+	assert.Equal(t, int32(3), b.checks.Load())
+
+	// the wait stops when the step is canceled, such as on step timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := w.wait(ctx, &fakeBrowser{readyAt: 1000})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, `wait_for: url matches "/done$" not met`)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestWaitFor_wait_Timeout(t *testing.T) {
+	venom.InitTestLogger(t)
+	outputDir := t.TempDir()
+	ctx := context.WithValue(context.Background(), venom.ContextKey("var.venom.outputdir"), outputDir)
+	w := &WaitFor{URL: "/done$", Interval: 10, Timeout: 50}
+
+	// the timeout is in milliseconds, the screenshot is saved in the output directory
+	b := &fakeBrowser{readyAt: 1000}
+	start := time.Now()
+	err := w.wait(ctx, b)
+	assert.EqualError(t, err, `wait_for: url matches "/done$" not met after 50ms`)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{filepath.Join(outputDir, "fake.timeout.png")}, b.screenshots)
+}
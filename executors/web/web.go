@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/gosimple/slug"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
+	"golang.org/x/net/html"
 
 	"github.com/kevinramage/venomWeb/common"
 	venomWeb "github.com/kevinramage/venomWeb/wrapper"
@@ -36,12 +38,16 @@ func New() venom.Executor {
 type WebContext struct {
 	wd      venomWeb.WebDriver
 	session venomWeb.Session
+	// cdp drives the browser when the driver is cdp, instead of the web driver
+	cdp *cdpSession
 }
 
 // Executor struct
 type Executor struct {
 	Action     Action `json:"action,omitempty" yaml:"action"`
 	Screenshot string `json:"screenshot,omitempty" yaml:"screenshot"`
+	// MatchScreenshot compares a screenshot with a baseline
+	MatchScreenshot *MatchScreenshot `json:"matchScreenshot,omitempty" yaml:"matchScreenshot"`
 }
 
 // Result represents a step result
@@ -53,6 +59,17 @@ type Result struct {
 	URL         string  `json:"url,omitempty" yaml:"url,omitempty"`
 	Text        string  `json:"text,omitempty" yaml:"text,omitempty"`
 	Value       string  `json:"value,omitempty" yaml:"value,omitempty"`
+	// Requests and Console are captured during the step by the cdp driver
+	Requests []NetworkRequest `json:"requests,omitempty" yaml:"requests,omitempty"`
+	Console  []ConsoleMessage `json:"console,omitempty" yaml:"console,omitempty"`
+	// Elements are the elements found by the find action
+	Elements []Element `json:"elements,omitempty" yaml:"elements,omitempty"`
+	// Cookies, LocalStorage and SessionStorage are read when the action requests them
+	Cookies        []Cookie          `json:"cookies,omitempty" yaml:"cookies,omitempty"`
+	LocalStorage   map[string]string `json:"localstorage,omitempty" yaml:"localstorage,omitempty"`
+	SessionStorage map[string]string `json:"sessionstorage,omitempty" yaml:"sessionstorage,omitempty"`
+	// Screenshot is the comparison of the screenshot with its baseline
+	Screenshot *ScreenshotComparison `json:"screenshot,omitempty" yaml:"screenshot,omitempty"`
 }
 
 // ZeroValueResult return an empty implementation of this executor result
@@ -63,7 +80,15 @@ func (Executor) ZeroValueResult() interface{} {
 func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
 	venom.Info(ctx, "Setup")
 	var webCtx WebContext
-	driver := venom.StringVarFromCtx(ctx, "web.driver") // Possible values: chrome, gecko
+	driver := venom.StringVarFromCtx(ctx, "web.driver") // Possible values: chrome, gecko, cdp
+	if driver == "cdp" {
+		session, err := newCDPSession(ctx)
+		if err != nil {
+			return ctx, errors.Wrapf(err, "Unable to start chromium")
+		}
+		webCtx.cdp = session
+		return context.WithValue(ctx, ContextKey, &webCtx), nil
+	}
 	args := venom.StringSliceVarFromCtx(ctx, "web.args")
 	prefs := venom.StringMapInterfaceVarFromCtx(ctx, "web.prefs")
 
@@ -138,7 +163,12 @@ func getWebCtx(ctx context.Context) *WebContext {
 
 func (Executor) TearDown(ctx context.Context) error {
 	venom.Info(ctx, "TearDown")
-	return getWebCtx(ctx).wd.Stop()
+	webCtx := getWebCtx(ctx)
+	if webCtx.cdp != nil {
+		webCtx.cdp.stop()
+		return nil
+	}
+	return webCtx.wd.Stop()
 }
 
 // Run execute TestStep
@@ -153,6 +183,10 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 		return nil, err
 	}
 
+	if webCtx.cdp != nil {
+		return e.runCDP(ctx, webCtx.cdp, start)
+	}
+
 	result, err := e.runAction(ctx, webCtx.session)
 	if err != nil {
 		if errg := generateErrorHTMLFile(ctx, webCtx.session, slug.Make(webCtx.session.String())); errg != nil {
@@ -173,6 +207,18 @@ func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, erro
 		}
 	}
 
+	// compare a screenshot with its baseline
+	if e.MatchScreenshot != nil {
+		comparison, err := e.MatchScreenshot.match(ctx, captureSession(webCtx.session))
+		if err != nil {
+			if errg := generateErrorHTMLFile(ctx, webCtx.session, slug.Make(webCtx.session.String())); errg != nil {
+				venom.Warn(ctx, "Error while generating the HTML file: %v", errg)
+			}
+			return nil, err
+		}
+		result.Screenshot = comparison
+	}
+
 	// Get page title (Check the absence of popup before the page title collect to avoid error)
 	result.Title = ""
 	if _, err := webCtx.session.GetAlertText(); err != nil {
@@ -249,6 +295,14 @@ func (e Executor) runAction(ctx context.Context, session venomWeb.Session) (*Res
 				r.Value = value
 			}
 		}
+		if e.Action.Read != nil && e.Action.Read.Elements {
+			r.Elements = make([]Element, len(elts))
+			for i, elt := range elts {
+				if r.Elements[i], err = describeElement(elt); err != nil {
+					return nil, errors.Wrapf(err, "unable to read element %d", i)
+				}
+			}
+		}
 
 		// Navigate
 	} else if e.Action.Navigate != nil {
@@ -359,9 +413,134 @@ func (e Executor) runAction(ctx context.Context, session venomWeb.Session) (*Res
 		}
 	}
 
+	// Wait for conditions
+	if e.Action.WaitFor != nil {
+		if err := e.Action.WaitFor.wait(ctx, sessionWaitBrowser{session: session}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read browser data
+	if e.Action.Read != nil {
+		if err := readSession(session, e.Action.Read, r); err != nil {
+			return nil, err
+		}
+	}
+
 	return r, nil
 }
 
+// evaluate runs a script returning a value and decodes its result. The web driver wrapper does not return the result of a script,
+// so it is stored in a property of the document element and read back.
+func evaluate(session venomWeb.Session, script string, args []string, res interface{}) error {
+	wrapped := fmt.Sprintf("document.documentElement.__venomResult = JSON.stringify((function() { %s }).apply(null, arguments));", script)
+	if err := session.ExecuteScript(wrapped, args); err != nil {
+		return err
+	}
+	elt, err := session.FindElement("html", common.CSS_SELECTOR)
+	if err != nil {
+		return err
+	}
+	value, err := elt.GetElementProperty("__venomResult")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(value), res)
+}
+
+// readSession reads the browser data requested by the action. The cookies are read from the document, without the httpOnly ones,
+// and the console messages are the ones logged in the page since the previous step reading them.
+func readSession(session venomWeb.Session, read *Read, r *Result) error {
+	if read.Cookies {
+		if err := evaluate(session, documentCookiesScript, []string{}, &r.Cookies); err != nil {
+			return errors.Wrapf(err, "unable to read cookies")
+		}
+	}
+	if read.LocalStorage {
+		if err := evaluate(session, storageScript, []string{"localStorage"}, &r.LocalStorage); err != nil {
+			return errors.Wrapf(err, "unable to read local storage")
+		}
+	}
+	if read.SessionStorage {
+		if err := evaluate(session, storageScript, []string{"sessionStorage"}, &r.SessionStorage); err != nil {
+			return errors.Wrapf(err, "unable to read session storage")
+		}
+	}
+	if read.Console {
+		if err := evaluate(session, consoleScript, []string{}, &r.Console); err != nil {
+			return errors.Wrapf(err, "unable to read console")
+		}
+	}
+	return nil
+}
+
+// sessionWaitBrowser reads the state of the page with the web driver
+type sessionWaitBrowser struct {
+	session venomWeb.Session
+}
+
+func (b sessionWaitBrowser) name() string {
+	return slug.Make(b.session.String())
+}
+
+func (b sessionWaitBrowser) element(ctx context.Context, findElement interface{}) (*elementState, error) {
+	selector, locator, err := readFindElement(ctx, findElement)
+	if err != nil {
+		return nil, err
+	}
+	elts, err := b.session.FindElements(selector, locator)
+	if err != nil || len(elts) == 0 {
+		return nil, err
+	}
+
+	// the web driver has no visibility command, an element is visible if it has a size and is not hidden by its style
+	var state elementState
+	rect, err := elts[0].GetElementRect()
+	if err != nil {
+		return nil, err
+	}
+	display, err := elts[0].GetElementCSSValue("display")
+	if err != nil {
+		return nil, err
+	}
+	visibility, err := elts[0].GetElementCSSValue("visibility")
+	if err != nil {
+		return nil, err
+	}
+	state.Visible = rect.Width > 0 && rect.Height > 0 && display != "none" && visibility != "hidden"
+	if state.Enabled, err = elts[0].IsElementEnabled(); err != nil {
+		return nil, err
+	}
+	if state.Text, err = elts[0].GetElementText(); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (b sessionWaitBrowser) url() (string, error) {
+	return b.session.GetURL()
+}
+
+// script throws an error when the expression is false, as the web driver does not return the result of a script
+func (b sessionWaitBrowser) script(expression string) (bool, error) {
+	err := b.session.ExecuteScript(fmt.Sprintf("if (!(%s)) { throw new Error('condition not met'); }", expression), []string{})
+	return err == nil, err
+}
+
+// networkIdle checks the page is loaded and no resource has been received for the idle duration
+func (b sessionWaitBrowser) networkIdle(idle time.Duration) (bool, error) {
+	err := b.session.ExecuteScript(`
+		const last = Math.max(0, ...performance.getEntriesByType("resource").map(r => r.responseEnd));
+		if (document.readyState !== "complete" || performance.now() - last < arguments[0]) {
+			throw new Error("network not idle");
+		}`, []string{fmt.Sprint(idle.Milliseconds())})
+	return err == nil, nil
+}
+
+func (b sessionWaitBrowser) screenshot(filename string) error {
+	return b.session.TakeScreenshot(filename)
+}
+
 func find(ctx context.Context, session venomWeb.Session, findElement interface{}, r *Result) ([]venomWeb.Element, error) {
 	// Identify selector and locator strategy
 	selector, locator, err := readFindElement(ctx, findElement)
@@ -385,6 +564,66 @@ func find(ctx context.Context, session venomWeb.Session, findElement interface{}
 	return elts, nil
 }
 
+// elementReader reads the state of an element with the web driver commands
+type elementReader interface {
+	GetElementTagName() (string, error)
+	GetElementText() (string, error)
+	GetElementProperty(propertyName string) (string, error)
+	GetElementCSSValue(propertyName string) (string, error)
+	GetElementRect() (common.Rect, error)
+}
+
+// describeElement returns the Element of an element found by the web driver, without running a script in the page
+func describeElement(elt elementReader) (Element, error) {
+	tag, err := elt.GetElementTagName()
+	if err != nil {
+		return Element{}, err
+	}
+	rect, err := elt.GetElementRect()
+	if err != nil {
+		return Element{}, err
+	}
+	element := Element{
+		Tag:  strings.ToLower(tag),
+		Rect: ElementRect{X: float64(rect.X), Y: float64(rect.Y), Width: float64(rect.Width), Height: float64(rect.Height)},
+	}
+	element.Text, _ = elt.GetElementText()
+	element.Value, _ = elt.GetElementProperty("value")
+	display, _ := elt.GetElementCSSValue("display")
+	visibility, _ := elt.GetElementCSSValue("visibility")
+	element.Visible = rect.Width > 0 && rect.Height > 0 && display != "none" && visibility != "hidden"
+
+	// the attributes are read from the start tag of the element
+	outerHTML, err := elt.GetElementProperty("outerHTML")
+	if err != nil {
+		return Element{}, err
+	}
+	z := html.NewTokenizer(strings.NewReader(outerHTML))
+	if tt := z.Next(); tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+		for _, attr := range z.Token().Attr {
+			if element.Attributes == nil {
+				element.Attributes = map[string]string{}
+			}
+			element.Attributes[attr.Key] = attr.Val
+		}
+	}
+	return element, nil
+}
+
+// captureSession takes the screenshots with the web driver
+func captureSession(session venomWeb.Session) screenshotCapture {
+	return func(ctx context.Context, findElement interface{}, filename string) error {
+		if findElement == nil {
+			return session.TakeScreenshot(filename)
+		}
+		elt, err := findOne(ctx, session, findElement, 0)
+		if err != nil {
+			return err
+		}
+		return elt.TakeScreenshot(filename)
+	}
+}
+
 // Find element from a selector
 func findOne(ctx context.Context, session venomWeb.Session, findElement interface{}, syncTimeout int64) (venomWeb.Element, error) {
 	// Identify selector and locator strategy
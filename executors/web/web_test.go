@@ -0,0 +1,99 @@
+package web
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kevinramage/venomWeb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeElement is an element read with the web driver commands
+type fakeElement struct {
+	tag        string
+	text       string
+	properties map[string]string
+	css        map[string]string
+	rect       common.Rect
+}
+
+func (e fakeElement) GetElementTagName() (string, error) { return e.tag, nil }
+
+func (e fakeElement) GetElementText() (string, error) { return e.text, nil }
+
+func (e fakeElement) GetElementProperty(propertyName string) (string, error) {
+	if v, ok := e.properties[propertyName]; ok {
+		return v, nil
+	}
+	return "", errors.New("no such property")
+}
+
+func (e fakeElement) GetElementCSSValue(propertyName string) (string, error) {
+	return e.css[propertyName], nil
+}
+
+func (e fakeElement) GetElementRect() (common.Rect, error) { return e.rect, nil }
+
+func TestDescribeElement(t *testing.T) {
+	tests := []struct {
+		name string
+		elt  fakeElement
+		want Element
+	}{
+		{
+			name: "button",
+			elt: fakeElement{
+				tag:        "BUTTON",
+				text:       "Save",
+				properties: map[string]string{"outerHTML": `<button class="primary" data-state="ready" disabled>Save <b>now</b></button>`},
+				css:        map[string]string{"display": "inline-block", "visibility": "visible"},
+				rect:       common.Rect{X: 10, Y: 20, Width: 80, Height: 30},
+			},
+			want: Element{
+				Tag:        "button",
+				Text:       "Save",
+				Attributes: map[string]string{"class": "primary", "data-state": "ready", "disabled": ""},
+				Visible:    true,
+				Rect:       ElementRect{X: 10, Y: 20, Width: 80, Height: 30},
+			},
+		},
+		{
+			name: "input without attributes",
+			elt: fakeElement{
+				tag:        "input",
+				properties: map[string]string{"outerHTML": `<input>`, "value": "foo"},
+				rect:       common.Rect{Width: 100, Height: 20},
+			},
+			want: Element{Tag: "input", Value: "foo", Visible: true, Rect: ElementRect{Width: 100, Height: 20}},
+		},
+		{
+			name: "hidden",
+			elt: fakeElement{
+				tag:        "div",
+				properties: map[string]string{"outerHTML": `<div id="spinner"></div>`},
+				css:        map[string]string{"visibility": "hidden"},
+				rect:       common.Rect{Width: 10, Height: 10},
+			},
+			want: Element{Tag: "div", Attributes: map[string]string{"id": "spinner"}, Rect: ElementRect{Width: 10, Height: 10}},
+		},
+		{
+			name: "empty",
+			elt: fakeElement{
+				tag:        "span",
+				properties: map[string]string{"outerHTML": `<span/>`},
+			},
+			want: Element{Tag: "span"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := describeElement(tt.elt)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := describeElement(fakeElement{tag: "div"})
+	assert.EqualError(t, err, "no such property")
+}
@@ -0,0 +1,398 @@
+package websocket
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+
+	"github.com/ovh/venom"
+	venomhttp "github.com/ovh/venom/executors/http"
+)
+
+var (
+	_ venom.Executor          = new(Executor)
+	_ venom.ExecutorWithSetup = new(Executor)
+)
+
+// Name of executor
+const Name = "websocket"
+
+// ContextKey is the key used to store opened connections in the context
+const ContextKey = venom.ContextKey("websocketContext")
+
+const (
+	defaultExecutorTimeoutMs = 5000
+	defaultConnectTimeoutMs  = 5000
+	closeTimeout             = time.Second
+	receiveBufferSize        = 1024
+)
+
+// New returns a new Executor
+func New() venom.Executor {
+	return &Executor{}
+}
+
+// Headers represents HTTP headers sent during the handshake
+type Headers map[string]string
+
+// Executor struct. Json and yaml descriptor are used for json output
+type Executor struct {
+	URL          string   `json:"url" yaml:"url"`
+	Headers      Headers  `json:"headers" yaml:"headers"`
+	Subprotocols []string `json:"subprotocols" yaml:"subprotocols"`
+
+	IgnoreVerifySSL bool   `json:"ignore_verify_ssl" yaml:"ignore_verify_ssl" mapstructure:"ignore_verify_ssl"`
+	TLSClientCert   string `json:"tls_client_cert" yaml:"tls_client_cert" mapstructure:"tls_client_cert"`
+	TLSClientKey    string `json:"tls_client_key" yaml:"tls_client_key" mapstructure:"tls_client_key"`
+	TLSRootCA       string `json:"tls_root_ca" yaml:"tls_root_ca" mapstructure:"tls_root_ca"`
+
+	// Messages sent once the connection is opened, in order
+	Messages []Message `json:"messages" yaml:"messages"`
+
+	// Represents the limit of message will be read. After limit, the step stops reading messages
+	MessageLimit int `json:"message_limit" yaml:"message_limit" mapstructure:"message_limit"`
+
+	// Represents the websocket handshake timeout. In Milliseconds. Default 5000
+	ConnectTimeout int64 `json:"connect_timeout,omitempty" yaml:"connect_timeout,omitempty" mapstructure:"connect_timeout"`
+
+	// Represents the timeout for reading messages. In Milliseconds. Default 5000
+	Timeout int64 `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// Close the connection at the end of the step instead of keeping it for the next steps of the testcase
+	Close bool `json:"close,omitempty" yaml:"close,omitempty"`
+}
+
+// Message represents a message sent to the websocket server
+type Message struct {
+	// Type must be "text", "binary" or "json". Default is text
+	Type string `json:"type" yaml:"type"`
+	// Content is a string for text, a base64 encoded string for binary or any value for json
+	Content interface{} `json:"content" yaml:"content"`
+}
+
+// Result represents a step result.
+type Result struct {
+	TimeSeconds  float64       `json:"timeseconds" yaml:"timeSeconds"`
+	Subprotocol  string        `json:"subprotocol,omitempty" yaml:"subprotocol,omitempty"`
+	Messages     []interface{} `json:"messages" yaml:"messages"`
+	MessagesJSON []interface{} `json:"messagesjson" yaml:"messagesJSON"`
+	// Dropped is the number of messages received while the buffer was full, since the previous step
+	Dropped int64  `json:"dropped,omitempty" yaml:"dropped,omitempty"`
+	Err     string `json:"err" yaml:"error"`
+}
+
+// webSocketContext holds the connections opened during a testcase, indexed by url
+type webSocketContext struct {
+	mutex sync.Mutex
+	conns map[string]*connection
+}
+
+// connection wraps a websocket connection. A single goroutine reads the connection
+// so that messages received between two steps are not lost.
+type connection struct {
+	conn        *ws.Conn
+	subprotocol string
+	received    chan receivedMessage
+	done        chan struct{}
+	err         error
+	// dropped counts the messages received while nobody reads the full buffer
+	dropped atomic.Int64
+}
+
+type receivedMessage struct {
+	messageType int
+	data        []byte
+}
+
+// ZeroValueResult return an empty implementation of this executor result
+func (Executor) ZeroValueResult() interface{} {
+	return Result{}
+}
+
+// GetDefaultAssertions return default assertions for this executor
+func (Executor) GetDefaultAssertions() *venom.StepAssertions {
+	return &venom.StepAssertions{Assertions: []venom.Assertion{"result.err ShouldBeEmpty"}}
+}
+
+// Setup prepares the connection holder shared by the steps of a testcase
+func (Executor) Setup(ctx context.Context, vars venom.H) (context.Context, error) {
+	return context.WithValue(ctx, ContextKey, &webSocketContext{conns: map[string]*connection{}}), nil
+}
+
+// TearDown closes all the connections opened during the testcase
+func (Executor) TearDown(ctx context.Context) error {
+	wsCtx := getWebSocketCtx(ctx)
+	if wsCtx == nil {
+		return nil
+	}
+	wsCtx.mutex.Lock()
+	defer wsCtx.mutex.Unlock()
+	for url, c := range wsCtx.conns {
+		venom.Debug(ctx, "closing websocket connection to %s", url)
+		c.close()
+		delete(wsCtx.conns, url)
+	}
+	return nil
+}
+
+func getWebSocketCtx(ctx context.Context) *webSocketContext {
+	i := ctx.Value(ContextKey)
+	if i == nil {
+		return nil
+	}
+	return i.(*webSocketContext)
+}
+
+// Run execute TestStep
+func (Executor) Run(ctx context.Context, step venom.TestStep) (interface{}, error) {
+	// transform step to Executor Instance
+	var e Executor
+	if err := mapstructure.Decode(step, &e); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	result := Result{}
+
+	if e.URL == "" {
+		return nil, errors.New("url is mandatory")
+	}
+	if e.Timeout == 0 {
+		e.Timeout = defaultExecutorTimeoutMs
+	}
+	if e.ConnectTimeout == 0 {
+		e.ConnectTimeout = defaultConnectTimeoutMs
+	}
+
+	wsCtx := getWebSocketCtx(ctx)
+	if wsCtx == nil {
+		// executor used without setup, the connection only lives for this step
+		wsCtx = &webSocketContext{conns: map[string]*connection{}}
+		e.Close = true
+	}
+
+	c, err := e.getConnection(ctx, wsCtx)
+	if err != nil {
+		return nil, err
+	}
+	result.Subprotocol = c.subprotocol
+
+	if e.Close {
+		defer func() {
+			wsCtx.mutex.Lock()
+			delete(wsCtx.conns, e.URL)
+			wsCtx.mutex.Unlock()
+			c.close()
+		}()
+	}
+
+	result.Messages, result.MessagesJSON, err = e.exchangeMessages(ctx, c)
+	if err != nil {
+		result.Err = err.Error()
+	}
+	if result.Dropped = c.dropped.Swap(0); result.Dropped > 0 {
+		venom.Warn(ctx, "%d websocket message(s) dropped, more than %d messages were received without being read", result.Dropped, receiveBufferSize)
+	}
+
+	result.TimeSeconds = time.Since(start).Seconds()
+	return result, nil
+}
+
+// getConnection returns the connection already opened on the same url or dials a new one
+func (e Executor) getConnection(ctx context.Context, wsCtx *webSocketContext) (*connection, error) {
+	wsCtx.mutex.Lock()
+	defer wsCtx.mutex.Unlock()
+
+	if c, ok := wsCtx.conns[e.URL]; ok {
+		select {
+		case <-c.done:
+			venom.Debug(ctx, "websocket connection to %s was closed: %v, reconnecting", e.URL, c.err)
+		default:
+			venom.Debug(ctx, "reusing websocket connection to %s", e.URL)
+			return c, nil
+		}
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wsCtx.conns[e.URL] = c
+	return c, nil
+}
+
+func (e Executor) dial(ctx context.Context) (*connection, error) {
+	httpExecutor := venomhttp.Executor{
+		IgnoreVerifySSL: e.IgnoreVerifySSL,
+		TLSClientCert:   e.TLSClientCert,
+		TLSClientKey:    e.TLSClientKey,
+		TLSRootCA:       e.TLSRootCA,
+	}
+	tlsOptions, err := httpExecutor.TLSOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tr := &http.Transport{}
+	for _, o := range tlsOptions {
+		if err := o(tr); err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := ws.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: time.Duration(e.ConnectTimeout) * time.Millisecond,
+		Subprotocols:     e.Subprotocols,
+		TLSClientConfig:  tr.TLSClientConfig,
+	}
+
+	header := http.Header{}
+	for k, v := range e.Headers {
+		header.Set(k, v)
+	}
+
+	venom.Debug(ctx, "opening websocket connection to %s", e.URL)
+	conn, resp, err := dialer.DialContext(ctx, e.URL, header)
+	if err != nil {
+		if resp != nil {
+			return nil, errors.Wrapf(err, "unable to connect to %s (status %d)", e.URL, resp.StatusCode)
+		}
+		return nil, errors.Wrapf(err, "unable to connect to %s", e.URL)
+	}
+
+	c := &connection{
+		conn:        conn,
+		subprotocol: conn.Subprotocol(),
+		received:    make(chan receivedMessage, receiveBufferSize),
+		done:        make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+// read forwards the received messages to the received channel until the connection is closed.
+// The messages are dropped when the channel is full, so that the connection can still be closed.
+func (c *connection) read() {
+	defer close(c.done)
+	for {
+		messageType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		select {
+		case c.received <- receivedMessage{messageType: messageType, data: data}:
+		default:
+			c.dropped.Add(1)
+		}
+	}
+}
+
+func (c *connection) close() {
+	msg := ws.FormatCloseMessage(ws.CloseNormalClosure, "")
+	_ = c.conn.WriteControl(ws.CloseMessage, msg, time.Now().Add(closeTimeout))
+	select {
+	case <-c.done:
+	case <-time.After(closeTimeout):
+	}
+	_ = c.conn.Close()
+}
+
+// exchangeMessages sends the configured messages then reads until the message limit or the timeout
+func (e Executor) exchangeMessages(ctx context.Context, c *connection) ([]interface{}, []interface{}, error) {
+	for i, m := range e.Messages {
+		messageType, data, err := m.encode()
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid messages[%d]", i)
+		}
+		if err := c.conn.WriteMessage(messageType, data); err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to send messages[%d]", i)
+		}
+		venom.Debug(ctx, "message[%d] sent, len(%d)", i, len(data))
+	}
+
+	messages := []interface{}{}
+	messagesJSON := []interface{}{}
+
+	if e.MessageLimit <= 0 {
+		return messages, messagesJSON, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, time.Duration(e.Timeout)*time.Millisecond)
+	defer cancel()
+
+	for len(messages) < e.MessageLimit {
+		var m receivedMessage
+		select {
+		case m = <-c.received:
+		case <-c.done:
+			// drain the messages received before the connection was closed
+			select {
+			case m = <-c.received:
+			default:
+				return messages, messagesJSON, errors.Wrapf(c.err, "connection closed after %d message(s)", len(messages))
+			}
+		case <-ctxTimeout.Done():
+			return messages, messagesJSON, fmt.Errorf("timeout after %d message(s), expected %d", len(messages), e.MessageLimit)
+		}
+
+		venom.Debug(ctx, "message received, len(%d)", len(m.data))
+		if m.messageType == ws.BinaryMessage {
+			messages = append(messages, base64.StdEncoding.EncodeToString(m.data))
+		} else {
+			messages = append(messages, string(m.data))
+		}
+
+		var bodyJSONArray []interface{}
+		if err := venom.JSONUnmarshal(m.data, &bodyJSONArray); err != nil {
+			bodyJSONMap := map[string]interface{}{}
+			if err := venom.JSONUnmarshal(m.data, &bodyJSONMap); err != nil {
+				venom.Debug(ctx, "unable to decode message as json")
+			}
+			messagesJSON = append(messagesJSON, bodyJSONMap)
+		} else {
+			messagesJSON = append(messagesJSON, bodyJSONArray)
+		}
+	}
+
+	return messages, messagesJSON, nil
+}
+
+// encode returns the websocket message type and the payload of the message
+func (m Message) encode() (int, []byte, error) {
+	switch m.Type {
+	case "", "text":
+		s, ok := m.Content.(string)
+		if !ok {
+			return 0, nil, fmt.Errorf("content of a text message must be a string, got %T", m.Content)
+		}
+		return ws.TextMessage, []byte(s), nil
+	case "binary":
+		s, ok := m.Content.(string)
+		if !ok {
+			return 0, nil, fmt.Errorf("content of a binary message must be a base64 encoded string, got %T", m.Content)
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return 0, nil, errors.Wrap(err, "unable to decode base64 content")
+		}
+		return ws.BinaryMessage, data, nil
+	case "json":
+		data, err := json.Marshal(m.Content)
+		if err != nil {
+			return 0, nil, errors.Wrap(err, "unable to encode json content")
+		}
+		return ws.TextMessage, data, nil
+	default:
+		return 0, nil, fmt.Errorf("type %q must be text, binary or json", m.Type)
+	}
+}
@@ -0,0 +1,165 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/venom"
+)
+
+// newEchoServer starts a websocket server sending back every message it receives
+func newEchoServer(t *testing.T) string {
+	venom.InitTestLogger(t)
+	upgrader := ws.Upgrader{Subprotocols: []string{"venom"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if token := r.Header.Get("X-Token"); token != "" {
+			_ = conn.WriteMessage(ws.TextMessage, []byte(token))
+		}
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestExecutor_Run_MissingURL(t *testing.T) {
+	_, err := New().Run(context.Background(), venom.TestStep{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "url is mandatory")
+}
+
+func TestExecutor_Run_Echo(t *testing.T) {
+	url := newEchoServer(t)
+
+	step := venom.TestStep{
+		"url":          url,
+		"subprotocols": []interface{}{"venom"},
+		"headers":      map[string]interface{}{"X-Token": "hello"},
+		"messages": []interface{}{
+			map[string]interface{}{"content": "ping"},
+			map[string]interface{}{"type": "json", "content": map[string]interface{}{"foo": "bar"}},
+			map[string]interface{}{"type": "binary", "content": "AAEC"},
+		},
+		"message_limit": 4,
+	}
+
+	res, err := New().Run(context.Background(), step)
+	require.NoError(t, err)
+	result := res.(Result)
+	require.Empty(t, result.Err)
+	assert.Equal(t, "venom", result.Subprotocol)
+	assert.Equal(t, []interface{}{"hello", "ping", `{"foo":"bar"}`, "AAEC"}, result.Messages)
+	require.Len(t, result.MessagesJSON, 4)
+	assert.Equal(t, map[string]interface{}{"foo": "bar"}, result.MessagesJSON[2])
+}
+
+func TestExecutor_Run_Timeout(t *testing.T) {
+	url := newEchoServer(t)
+
+	step := venom.TestStep{
+		"url":           url,
+		"messages":      []interface{}{map[string]interface{}{"content": "ping"}},
+		"message_limit": 2,
+		"timeout":       100,
+	}
+
+	res, err := New().Run(context.Background(), step)
+	require.NoError(t, err)
+	result := res.(Result)
+	assert.Contains(t, result.Err, "timeout after 1 message(s)")
+	assert.Equal(t, []interface{}{"ping"}, result.Messages)
+}
+
+func TestExecutor_Run_KeepConnection(t *testing.T) {
+	url := newEchoServer(t)
+
+	e := New().(*Executor)
+	ctx, err := e.Setup(context.Background(), venom.H{})
+	require.NoError(t, err)
+
+	// the first step only sends a message, the answer is read by the second step
+	_, err = e.Run(ctx, venom.TestStep{
+		"url":      url,
+		"messages": []interface{}{map[string]interface{}{"content": "first"}},
+	})
+	require.NoError(t, err)
+
+	res, err := e.Run(ctx, venom.TestStep{
+		"url":           url,
+		"message_limit": 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"first"}, res.(Result).Messages)
+
+	wsCtx := getWebSocketCtx(ctx)
+	require.Len(t, wsCtx.conns, 1)
+	require.NoError(t, e.TearDown(ctx))
+	assert.Empty(t, wsCtx.conns)
+}
+
+func TestMessage_encode(t *testing.T) {
+	_, _, err := Message{Type: "binary", Content: "not base64!"}.encode()
+	assert.Error(t, err)
+
+	_, _, err = Message{Type: "xml", Content: "<a/>"}.encode()
+	assert.Error(t, err)
+
+	messageType, data, err := Message{Type: "json", Content: []interface{}{1, "a"}}.encode()
+	require.NoError(t, err)
+	assert.Equal(t, ws.TextMessage, messageType)
+	assert.Equal(t, `[1,"a"]`, string(data))
+}
+
+func TestConnection_read_FullBuffer(t *testing.T) {
+	venom.InitTestLogger(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&ws.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for i := 0; i < 5; i++ {
+			_ = conn.WriteMessage(ws.TextMessage, []byte("message"))
+		}
+		// wait for the close of the client
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	conn, _, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	c := &connection{conn: conn, received: make(chan receivedMessage, 2), done: make(chan struct{})}
+	go c.read()
+
+	// the messages are dropped when nobody reads the full buffer
+	require.Eventually(t, func() bool { return c.dropped.Load() == 3 }, time.Second, 10*time.Millisecond)
+	start := time.Now()
+	c.close()
+	assert.Less(t, time.Since(start), closeTimeout)
+	<-c.done
+	assert.Len(t, c.received, 2)
+}
@@ -157,6 +157,8 @@ func (v *Venom) readFiles(ctx context.Context, filesPath []string) (err error) {
 		ts.Vars.Add("venom.testsuite.filepath", ts.Filepath)
 		ts.Vars.Add("venom.datetime", time.Now().Format(time.RFC3339))
 		ts.Vars.Add("venom.timestamp", fmt.Sprintf("%d", time.Now().Unix()))
+		ts.Vars.Add("venom.outputdir", v.OutputDir)
+		ts.Vars.Add("venom.updatescreenshots", v.UpdateScreenshots)
 
 		v.Tests.TestSuites = append(v.Tests.TestSuites, ts)
 	}
@@ -140,6 +140,7 @@ func (v *Venom) runTestCase(ctx context.Context, ts *TestSuite, tc *TestCase) {
 	tc.Vars = ts.Vars.Clone()
 	tc.Vars.Add("venom.testcase", tc.Name)
 	tc.Vars.AddAll(ts.ComputedVars)
+	tc.Vars.AddAll(testSuiteVars(ctx))
 	tc.Vars.Add("venom.testcase.totalSteps", len(tc.RawTestSteps))
 	tc.computedVars = H{}
 
@@ -211,6 +212,7 @@ func (v *Venom) runTestSteps(ctx context.Context, tc *TestCase, tsIn *TestStepRe
 loopRawTestSteps:
 	for stepIndex, rawStep := range tc.RawTestSteps {
 		stepVars := tc.Vars.Clone()
+		stepVars.AddAll(testSuiteVars(ctx))
 		stepVars.AddAll(previousStepVars)
 		stepVars.AddAllWithPrefix(tc.Name, tc.computedVars)
 
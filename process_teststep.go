@@ -3,6 +3,7 @@ package venom
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,6 +13,9 @@ import (
 	"github.com/ovh/venom/interpolate"
 )
 
+// timeoutGracePeriod is the time left to an executor to return its partial result after the timeout of a step
+const timeoutGracePeriod = time.Second
+
 type dumpFile struct {
 	Variables H           `json:"variables"`
 	TestStep  TestStep    `json:"step"`
@@ -31,15 +35,20 @@ func (v *Venom) RunTestStep(ctx context.Context, e ExecutorRunner, tc *TestCase,
 			time.Sleep(time.Duration(e.Delay()) * time.Second)
 		}
 
-		var err error
-		result, err = v.runTestStepExecutor(ctx, e, tc, tsResult, step)
-		if err != nil {
+		var stepErr error
+		result, stepErr = v.runTestStepExecutor(ctx, e, tc, tsResult, step)
+		if stepErr != nil {
 			// we save the failure only if it's the last attempt
 			if tsResult.Retries == e.Retry() {
-				failure := newFailure(ctx, *tc, stepNumber, rangedIndex, "", err)
+				failure := newFailure(ctx, *tc, stepNumber, rangedIndex, "", stepErr)
 				tsResult.appendFailure(*failure)
 			}
-			continue
+			// the result returned with the error is checked and reported, the step still fails
+			var errWithResult *ErrorWithResult
+			if !errors.As(stepErr, &errWithResult) || errWithResult.Result == nil {
+				continue
+			}
+			result = errWithResult.Result
 		}
 
 		Debug(ctx, "result of executor: %s", result)
@@ -102,6 +111,9 @@ func (v *Venom) RunTestStep(ctx context.Context, e ExecutorRunner, tc *TestCase,
 			}
 		}
 
+		if stepErr != nil {
+			assertRes.OK = false
+		}
 		tsResult.AssertionsApplied = assertRes
 		tsResult.ComputedVars.AddAll(H(mapResult))
 
@@ -145,15 +157,16 @@ func (v *Venom) runTestStepExecutor(ctx context.Context, e ExecutorRunner, tc *T
 	ctxTimeout, cancel := context.WithTimeout(ctx, time.Duration(e.Timeout())*time.Second)
 	defer cancel()
 
-	ch := make(chan interface{})
-	cherr := make(chan error)
+	// The channels are buffered, so that the goroutine ends even if the executor returns after the timeout
+	ch := make(chan interface{}, 1)
+	cherr := make(chan error, 1)
 	go func(e ExecutorRunner, step TestStep) {
 		var err error
 		var result interface{}
 		if e.Type() == "user" {
-			result, err = v.RunUserExecutor(ctx, e, tc, ts, step)
+			result, err = v.RunUserExecutor(ctxTimeout, e, tc, ts, step)
 		} else {
-			result, err = e.Run(ctx, step)
+			result, err = e.Run(ctxTimeout, step)
 		}
 		if err != nil {
 			cherr <- err
@@ -168,6 +181,33 @@ func (v *Venom) runTestStepExecutor(ctx context.Context, e ExecutorRunner, tc *T
 	case result := <-ch:
 		return result, nil
 	case <-ctxTimeout.Done():
-		return nil, fmt.Errorf("Timeout after %d second(s)", e.Timeout())
+	}
+
+	// The executors stopped by the cancellation of the context return their partial result, its output is kept in the step result
+	select {
+	case result := <-ch:
+		appendPartialOutput(ts, result)
+	case <-cherr:
+	case <-time.After(timeoutGracePeriod):
+		Warn(ctx, "executor %s is still running after the timeout", e.Name())
+	}
+	return nil, fmt.Errorf("Timeout after %d second(s)", e.Timeout())
+}
+
+// appendPartialOutput appends the systemout and systemerr of the result of a step which timed out to the step result
+func appendPartialOutput(ts *TestStepResult, result interface{}) {
+	var output struct {
+		Systemout string `json:"systemout"`
+		Systemerr string `json:"systemerr"`
+	}
+	b, err := json.Marshal(result)
+	if err != nil || json.Unmarshal(b, &output) != nil {
+		return
+	}
+	if output.Systemout != "" {
+		ts.Systemout += output.Systemout + "\n"
+	}
+	if output.Systemerr != "" {
+		ts.Systemerr += output.Systemerr + "\n"
 	}
 }
@@ -0,0 +1,56 @@
+package venom
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cancellableExecutor writes a line of output, then waits for the cancellation of the context
+type cancellableExecutor struct{}
+
+func (cancellableExecutor) Run(ctx context.Context, _ TestStep) (interface{}, error) {
+	<-ctx.Done()
+	return struct {
+		Systemout string `json:"systemout"`
+		Err       string `json:"err"`
+	}{Systemout: "partial output", Err: ctx.Err().Error()}, nil
+}
+
+func TestRunTestStepExecutor_Timeout(t *testing.T) {
+	InitTestLogger(t)
+	v := New()
+	ts := &TestStepResult{}
+	e := newExecutorRunner(cancellableExecutor{}, "cancellable", "builtin", 0, nil, 0, 1, nil)
+
+	start := time.Now()
+	_, err := v.runTestStepExecutor(context.Background(), e, &TestCase{}, ts, TestStep{})
+	require.EqualError(t, err, "Timeout after 1 second(s)")
+	assert.Less(t, time.Since(start), 1*time.Second+timeoutGracePeriod)
+	assert.Equal(t, "partial output\n", ts.Systemout)
+}
+
+// failingExecutor fails with its result, as on a wait_until timeout
+type failingExecutor struct{}
+
+func (failingExecutor) Run(ctx context.Context, _ TestStep) (interface{}, error) {
+	return nil, &ErrorWithResult{Result: Result{Count: 2}, Err: errors.New("still failing")}
+}
+
+func TestRunTestStep_ErrorWithResult(t *testing.T) {
+	InitTestLogger(t)
+	v := New()
+	tsResult := &TestStepResult{ComputedVars: H{}}
+	e := newExecutorRunner(failingExecutor{}, "failing", "builtin", 0, nil, 0, 0, nil)
+
+	v.RunTestStep(context.Background(), e, &TestCase{}, tsResult, 1, 0, TestStep{"assertions": []interface{}{"result.count ShouldEqual 3"}})
+	// the step fails with the error of the executor, and with its assertions checked on the result
+	require.Len(t, tsResult.Errors, 2)
+	assert.Contains(t, tsResult.Errors[0].Value, "still failing")
+	assert.Contains(t, tsResult.Errors[1].Value, "result.count")
+	assert.Equal(t, 2, tsResult.ComputedVars["result.count"])
+}
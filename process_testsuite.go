@@ -57,6 +57,9 @@ func (v *Venom) runTestSuite(ctx context.Context, ts *TestSuite) error {
 	Info(ctx, "Starting testsuite")
 	defer Info(ctx, "Ending testsuite")
 
+	ctx, closeResources := withTestSuiteResources(ctx)
+	defer closeResources(ctx)
+
 	totalSteps := 0
 	for _, tc := range ts.TestCases {
 		totalSteps += len(tc.RawTestSteps)
@@ -0,0 +1,40 @@
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS posts_tags;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS users;
+
+CREATE TABLE posts (
+	id UInt32
+	,title String
+	,content String
+	,created_at DateTime
+	,updated_at DateTime
+) ENGINE = MergeTree ORDER BY id;
+
+CREATE TABLE tags (
+	id UInt32
+	,name String
+	,created_at DateTime
+	,updated_at DateTime
+) ENGINE = MergeTree ORDER BY id;
+
+CREATE TABLE posts_tags (
+	post_id UInt32
+	,tag_id UInt32
+) ENGINE = MergeTree ORDER BY (post_id, tag_id);
+
+CREATE TABLE comments (
+	id UInt32
+	,post_id UInt32
+	,author_name String
+	,author_email String
+	,content String
+	,created_at DateTime
+	,updated_at DateTime
+) ENGINE = MergeTree ORDER BY id;
+
+CREATE TABLE users (
+	id UInt32
+	,attributes String
+) ENGINE = MergeTree ORDER BY id;
@@ -0,0 +1,44 @@
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS posts_tags;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS users;
+
+CREATE TABLE posts (
+	id INT IDENTITY PRIMARY KEY
+	,title NVARCHAR(255) NOT NULL
+	,content NVARCHAR(MAX) NOT NULL
+	,created_at DATETIME2 NOT NULL
+	,updated_at DATETIME2 NOT NULL
+);
+
+CREATE TABLE tags (
+	id INT IDENTITY PRIMARY KEY
+	,name NVARCHAR(255) NOT NULL
+	,created_at DATETIME2 NOT NULL
+	,updated_at DATETIME2 NOT NULL
+);
+
+CREATE TABLE posts_tags (
+	post_id INT NOT NULL
+	,tag_id INT NOT NULL
+	,PRIMARY KEY (post_id, tag_id)
+	,FOREIGN KEY (post_id) REFERENCES posts (id)
+	,FOREIGN KEY (tag_id) REFERENCES tags (id)
+);
+
+CREATE TABLE comments (
+	id INT IDENTITY PRIMARY KEY
+	,post_id INT NOT NULL
+	,author_name NVARCHAR(255) NOT NULL
+	,author_email NVARCHAR(255) NOT NULL
+	,content NVARCHAR(MAX) NOT NULL
+	,created_at DATETIME2 NOT NULL
+	,updated_at DATETIME2 NOT NULL
+	,FOREIGN KEY (post_id) REFERENCES posts (id)
+);
+
+CREATE TABLE users (
+	id INT IDENTITY PRIMARY KEY
+	,attributes NVARCHAR(MAX) NOT NULL
+);
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/gosimple/slug"
 	"github.com/ovh/venom/interpolate"
@@ -160,6 +162,93 @@ type ExecutorWithSetup interface {
 	TearDown(ctx context.Context) error
 }
 
+// ErrorWithResult is returned by an executor failing with a result, such as the last result of a wait_until timeout.
+// The step fails with Err, and Result is still checked by the assertions and kept in the step result.
+type ErrorWithResult struct {
+	Result interface{}
+	Err    error
+}
+
+func (e *ErrorWithResult) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrorWithResult) Unwrap() error {
+	return e.Err
+}
+
+const testSuiteResourcesKey = ContextKey("testsuite.resources")
+
+// testSuiteResources holds the resources shared by the testcases of a testsuite
+type testSuiteResources struct {
+	mutex     sync.Mutex
+	resources map[string]io.Closer
+	keys      []string
+	// vars are the variables set by the executors, available to the next steps and testcases
+	vars H
+}
+
+// withTestSuiteResources returns a context holding the resources of a testsuite, and the function closing them at the end of the testsuite
+func withTestSuiteResources(ctx context.Context) (context.Context, func(context.Context)) {
+	r := &testSuiteResources{resources: map[string]io.Closer{}, vars: H{}}
+	return context.WithValue(ctx, testSuiteResourcesKey, r), func(ctx context.Context) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		for i := len(r.keys) - 1; i >= 0; i-- {
+			if err := r.resources[r.keys[i]].Close(); err != nil {
+				Error(ctx, "unable to close testsuite resource %s: %v", r.keys[i], err)
+			}
+		}
+		r.resources = map[string]io.Closer{}
+		r.keys = nil
+	}
+}
+
+// TestSuiteResource returns the resource shared under key by the testcases of the running testsuite, calling create on first use.
+// The resource is closed at the end of the testsuite. It returns nil if ctx is not the context of a testsuite,
+// the caller has then to close the resource it creates.
+// Executors can use it in their Setup to keep connections opened from a testcase to another.
+func TestSuiteResource(ctx context.Context, key string, create func() io.Closer) io.Closer {
+	r, ok := ctx.Value(testSuiteResourcesKey).(*testSuiteResources)
+	if !ok {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if resource, ok := r.resources[key]; ok {
+		return resource
+	}
+	resource := create()
+	r.resources[key] = resource
+	r.keys = append(r.keys, key)
+	return resource
+}
+
+// SetTestSuiteVar sets a variable of the running testsuite, available to the next steps and testcases as {{.name}}.
+// It returns false if ctx is not the context of a testsuite.
+// Executors can use it to expose the address of a resource they started for the testsuite.
+func SetTestSuiteVar(ctx context.Context, name string, value interface{}) bool {
+	r, ok := ctx.Value(testSuiteResourcesKey).(*testSuiteResources)
+	if !ok {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.vars.Add(name, value)
+	return true
+}
+
+// testSuiteVars returns the variables set by the executors during the testsuite
+func testSuiteVars(ctx context.Context) H {
+	r, ok := ctx.Value(testSuiteResourcesKey).(*testSuiteResources)
+	if !ok {
+		return H{}
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.vars.Clone()
+}
+
 func GetExecutorResult(r interface{}) map[string]interface{} {
 	d, err := Dump(r)
 	if err != nil {
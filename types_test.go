@@ -1,6 +1,12 @@
 package venom
 
-import "testing"
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func Test_RemoveNotPrintableChar(t *testing.T) {
 	type args struct {
@@ -26,3 +32,41 @@ func Test_RemoveNotPrintableChar(t *testing.T) {
 		})
 	}
 }
+
+type closer struct{ closed int }
+
+func (c *closer) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestTestSuiteResource(t *testing.T) {
+	InitTestLogger(t)
+	assert.Nil(t, TestSuiteResource(context.Background(), "key", func() io.Closer { return &closer{} }))
+
+	ctx, closeResources := withTestSuiteResources(context.Background())
+	created := 0
+	create := func() io.Closer {
+		created++
+		return &closer{}
+	}
+	c := TestSuiteResource(ctx, "key", create)
+	assert.Same(t, c, TestSuiteResource(ctx, "key", create))
+	assert.Equal(t, 1, created)
+
+	closeResources(ctx)
+	assert.Equal(t, 1, c.(*closer).closed)
+}
+
+func TestSetTestSuiteVar(t *testing.T) {
+	assert.False(t, SetTestSuiteVar(context.Background(), "name", "value"))
+	assert.Empty(t, testSuiteVars(context.Background()))
+
+	ctx, _ := withTestSuiteResources(context.Background())
+	assert.True(t, SetTestSuiteVar(ctx, "server.port", 25))
+	vars := testSuiteVars(ctx)
+	assert.Equal(t, H{"server.port": 25}, vars)
+
+	vars.Add("other", "value")
+	assert.Len(t, testSuiteVars(ctx), 1)
+}
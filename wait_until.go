@@ -0,0 +1,109 @@
+package venom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultWaitUntilInterval = 100
+	defaultWaitUntilTimeout  = 10000
+)
+
+// ErrWaitUntilTimeout is wrapped by the error of Poll when the assertions are still failing at the timeout
+var ErrWaitUntilTimeout = errors.New("wait_until: timeout")
+
+// WaitUntil represents the polling of an executor until its result matches assertions.
+// Executors can embed it in a wait_until attribute.
+type WaitUntil struct {
+	Assertions []Assertion `json:"assertions" yaml:"assertions"`
+	// Interval between two polls. In Milliseconds. Default 100
+	Interval int `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// Backoff multiplies the interval after each poll. Default 1
+	Backoff float64 `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	// MaxInterval caps the interval increased by the backoff. In Milliseconds
+	MaxInterval int `json:"max_interval,omitempty" yaml:"max_interval,omitempty" mapstructure:"max_interval"`
+	// Timeout after which the polling fails. In Milliseconds. Default 10000
+	Timeout int `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// WaitUntilResult reports the polling of an executor
+type WaitUntilResult struct {
+	Polls       int     `json:"polls" yaml:"polls"`
+	TimeSeconds float64 `json:"timeseconds" yaml:"timeseconds"`
+}
+
+// Poll calls run until its result matches the assertions, or until the timeout expires.
+// It returns the last result, and an error if the assertions never passed or if run failed.
+// On timeout, the error wraps ErrWaitUntilTimeout: the executors can return the last result with an ErrorWithResult.
+func (w WaitUntil) Poll(ctx context.Context, run func() (interface{}, error)) (interface{}, WaitUntilResult, error) {
+	interval := time.Duration(w.Interval) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultWaitUntilInterval * time.Millisecond
+	}
+	timeout := time.Duration(w.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultWaitUntilTimeout * time.Millisecond
+	}
+	start := time.Now()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	var wr WaitUntilResult
+	for {
+		r, err := run()
+		wr.Polls++
+		wr.TimeSeconds = time.Since(start).Seconds()
+		if err != nil {
+			return r, wr, err
+		}
+		err = CheckAssertions(ctx, w.Assertions, r)
+		if err == nil {
+			Debug(ctx, "wait_until: assertions passed after %d poll(s)", wr.Polls)
+			return r, wr, nil
+		}
+		Debug(ctx, "wait_until: poll #%d failed: %v", wr.Polls, err)
+
+		select {
+		case <-time.After(interval):
+		case <-deadline.C:
+			wr.TimeSeconds = time.Since(start).Seconds()
+			return r, wr, fmt.Errorf("%w: assertions still failing after %d poll(s) in %.3fs: %v", ErrWaitUntilTimeout, wr.Polls, wr.TimeSeconds, err)
+		case <-ctx.Done():
+			return r, wr, fmt.Errorf("wait_until: %w", ctx.Err())
+		}
+
+		if w.Backoff > 1 {
+			interval = time.Duration(float64(interval) * w.Backoff)
+			if w.MaxInterval > 0 && interval > time.Duration(w.MaxInterval)*time.Millisecond {
+				interval = time.Duration(w.MaxInterval) * time.Millisecond
+			}
+		}
+	}
+}
+
+// CheckAssertions checks assertions against the result of an executor, such as "result.rows ShouldNotBeEmpty".
+// It returns the error of the first failing assertion.
+func CheckAssertions(ctx context.Context, assertions []Assertion, r interface{}) error {
+	executorResult := GetExecutorResult(r)
+	for _, a := range assertions {
+		s, ok := a.(string)
+		if !ok {
+			// logical operators
+			if failure := check(ctx, TestCase{}, 0, 0, a, executorResult); failure != nil {
+				return errors.New(failure.Value)
+			}
+			continue
+		}
+		assert, err := parseAssertions(ctx, s, executorResult)
+		if err != nil {
+			return fmt.Errorf("assertion %q: %w", s, err)
+		}
+		if err := assert.Func(assert.Actual, assert.Args...); err != nil {
+			return fmt.Errorf("assertion %q failed: %w", s, err)
+		}
+	}
+	return nil
+}
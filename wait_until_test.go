@@ -0,0 +1,51 @@
+package venom
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Result mimics the result of an executor, the assertions use its lowercased type name
+type Result struct {
+	Count int `json:"count"`
+}
+
+func TestWaitUntil_Poll(t *testing.T) {
+	InitTestLogger(t)
+
+	count := 0
+	run := func() (interface{}, error) {
+		count++
+		return Result{Count: count}, nil
+	}
+	w := WaitUntil{Assertions: []Assertion{"result.count ShouldEqual 3"}, Interval: 1, Backoff: 2, MaxInterval: 5}
+	r, wr, err := w.Poll(context.Background(), run)
+	require.NoError(t, err)
+	assert.Equal(t, 3, r.(Result).Count)
+	assert.Equal(t, 3, wr.Polls)
+
+	w = WaitUntil{Assertions: []Assertion{"result.count ShouldEqual 0"}, Interval: 10, Timeout: 50}
+	_, wr, err = w.Poll(context.Background(), run)
+	require.ErrorIs(t, err, ErrWaitUntilTimeout)
+	assert.Contains(t, err.Error(), "still failing")
+	assert.GreaterOrEqual(t, wr.TimeSeconds, 0.05)
+
+	_, wr, err = w.Poll(context.Background(), func() (interface{}, error) { return nil, errors.New("boom") })
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, 1, wr.Polls)
+}
+
+func TestCheckAssertions(t *testing.T) {
+	InitTestLogger(t)
+	r := Result{Count: 2}
+	assert.NoError(t, CheckAssertions(context.Background(), []Assertion{
+		"result.count ShouldNotEqual 1",
+		map[string]interface{}{"or": []interface{}{"result.count ShouldEqual 1", "result.count ShouldEqual 2"}},
+	}, r))
+	assert.Error(t, CheckAssertions(context.Background(), []Assertion{"result.count ShouldEqual 1"}, r))
+	assert.Error(t, CheckAssertions(context.Background(), []Assertion{"result.count"}, r))
+}